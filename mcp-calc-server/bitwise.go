@@ -0,0 +1,638 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// ビット演算ツールの既定値
+const (
+	defaultWordWidth = 64
+	maxBase          = 36
+)
+
+// wordWidths は対応するビット幅です
+var wordWidths = []int{8, 16, 32, 64}
+
+// wordArg は整数の引数を表します。JSON の整数と、0x・0b・0o の接頭辞つきの文字列を受け付け、float64 を経由しません
+type wordArg struct {
+	big.Int
+}
+
+// UnmarshalJSON は整数を数値または文字列から読み込みます。文字列では桁区切りの _ も使えます
+func (w *wordArg) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := parseBaseInteger(strings.TrimSpace(s), 0)
+	if err != nil {
+		return err
+	}
+	w.Set(v)
+	return nil
+}
+
+// parseBaseInteger は整数を base 進数として解析します。base が 0 の場合は接頭辞（0x、0b、0o）で判定し、なければ10進数です
+func parseBaseInteger(s string, base int) (*big.Int, error) {
+	t := strings.ReplaceAll(s, "_", "")
+	sign := ""
+	if strings.HasPrefix(t, "-") || strings.HasPrefix(t, "+") {
+		sign, t = t[:1], t[1:]
+	}
+	if base == 0 {
+		base = 10
+		if len(t) > 2 && t[0] == '0' {
+			switch t[1] {
+			case 'x', 'X':
+				base, t = 16, t[2:]
+			case 'b', 'B':
+				base, t = 2, t[2:]
+			case 'o', 'O':
+				base, t = 8, t[2:]
+			}
+		}
+	}
+	if len(t) > maxIntegerDigits {
+		return nil, fmt.Errorf("integers must not exceed %d digits", maxIntegerDigits)
+	}
+	// 先頭の符号は1つだけ許す
+	v, ok := new(big.Int).SetString(t, base)
+	if !ok || t == "" || t[0] == '+' || t[0] == '-' {
+		return nil, fmt.Errorf("invalid base-%d integer %q", base, s)
+	}
+	if sign == "-" {
+		v.Neg(v)
+	}
+	return v, nil
+}
+
+// WordOptions はビット幅と符号の有無を表します
+type WordOptions struct {
+	Width  int  `json:"width,omitempty"`
+	Signed bool `json:"signed,omitempty"`
+}
+
+// BitwiseParams は bit_and / bit_or / bit_xor / bit_not ツールのパラメータを表します
+type BitwiseParams struct {
+	A wordArg  `json:"a"`
+	B *wordArg `json:"b,omitempty"`
+	WordOptions
+}
+
+// ShiftParams は bit_shift / bit_rotate ツールのパラメータを表します
+type ShiftParams struct {
+	Value     wordArg `json:"value"`
+	Amount    int     `json:"amount"`
+	Direction string  `json:"direction,omitempty"` // left または right
+	WordOptions
+}
+
+// WordParams は popcount / twos_complement ツールのパラメータを表します
+type WordParams struct {
+	Value wordArg `json:"value"`
+	WordOptions
+}
+
+// ConvertBaseParams は convert_base ツールのパラメータを表します
+type ConvertBaseParams struct {
+	Value string `json:"value"`
+	From  int    `json:"from,omitempty"` // 省略時は接頭辞で判定する
+	To    int    `json:"to"`
+}
+
+// FloatBitsParams は float_bits ツールのパラメータを表します。value と bits のどちらか一方を指定します
+type FloatBitsParams struct {
+	Value     *Number `json:"value,omitempty"` // ビット列に分解する数値
+	Bits      string  `json:"bits,omitempty"`  // 数値に戻すビット列
+	Precision string  `json:"precision,omitempty"`
+}
+
+// word は幅 width のビット列です。符号つきの場合は2の補数で解釈します
+type word struct {
+	bits   uint64
+	width  int
+	signed bool
+}
+
+// mask は幅のビットがすべて立った値を返します
+func (w word) mask() uint64 {
+	if w.width == 64 {
+		return math.MaxUint64
+	}
+	return 1<<w.width - 1
+}
+
+// signedValue は2の補数として解釈した値を返します
+func (w word) signedValue() int64 {
+	shift := 64 - w.width
+	return int64(w.bits<<shift) >> shift
+}
+
+// String は符号の指定に従った10進表記を返します
+func (w word) String() string {
+	if w.signed {
+		return strconv.FormatInt(w.signedValue(), 10)
+	}
+	return strconv.FormatUint(w.bits, 10)
+}
+
+// hex は幅に合わせて0埋めした16進表記を返します
+func (w word) hex() string {
+	return fmt.Sprintf("0x%0*X", w.width/4, w.bits)
+}
+
+// binary は4ビットごとに _ で区切った2進表記を返します
+func (w word) binary() string {
+	s := fmt.Sprintf("%0*b", w.width, w.bits)
+	var b strings.Builder
+	b.WriteString("0b")
+	for i := 0; i < len(s); i += 4 {
+		if i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteString(s[i : i+4])
+	}
+	return b.String()
+}
+
+// result はビット列をツールの結果にします。text は「10進 = 16進 = 2進」です
+func (w word) result(extra map[string]any) *CallToolResult {
+	data := map[string]any{
+		"value":    w.String(),
+		"unsigned": strconv.FormatUint(w.bits, 10),
+		"signed":   strconv.FormatInt(w.signedValue(), 10),
+		"hex":      w.hex(),
+		"binary":   w.binary(),
+		"octal":    "0o" + strconv.FormatUint(w.bits, 8),
+		"width":    w.width,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	return newStructuredResult(fmt.Sprintf("%s = %s = %s", w, w.hex(), w.binary()), data)
+}
+
+// newWord は整数を幅 width のビット列にします。符号つきと符号なしのどちらかの範囲に収まる値を受け付けます
+func newWord(v *big.Int, opts WordOptions) (word, *Error) {
+	width := opts.Width
+	if width == 0 {
+		width = defaultWordWidth
+	}
+	valid := false
+	for _, w := range wordWidths {
+		valid = valid || w == width
+	}
+	if !valid {
+		return word{}, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unsupported width %d", width), Data: wordWidths}
+	}
+	min := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), uint(width-1)))
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(width)), big.NewInt(1))
+	if v.Cmp(min) < 0 || v.Cmp(max) > 0 {
+		return word{}, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("%s does not fit in %d bits", v, width),
+			Data:    fmt.Sprintf("values must be between %s and %s", min, max),
+		}
+	}
+	// 負の値は 2^width を足して2の補数のビット列にする
+	u := new(big.Int).Set(v)
+	if u.Sign() < 0 {
+		u.Add(u, new(big.Int).Lsh(big.NewInt(1), uint(width)))
+	}
+	return word{bits: u.Uint64(), width: width, signed: opts.Signed}, nil
+}
+
+// wordSchema はビット演算の整数の入力スキーマです
+func wordSchema(desc string) map[string]any {
+	return map[string]any{
+		"type":        []string{"integer", "string"},
+		"pattern":     `^\s*[+-]?(0[xX][0-9a-fA-F_]+|0[bB][01_]+|0[oO][0-7_]+|[0-9_]+)\s*$`,
+		"description": desc + ". Strings may use 0x, 0b or 0o prefixes and _ separators",
+	}
+}
+
+// wordToolSchema はビット幅と符号の指定を入力スキーマに加えます
+func wordToolSchema(properties map[string]any, required ...string) map[string]any {
+	properties["width"] = map[string]any{
+		"type":        "integer",
+		"enum":        wordWidths,
+		"description": fmt.Sprintf("Bit width (defaults to %d)", defaultWordWidth),
+	}
+	properties["signed"] = map[string]any{
+		"type":        "boolean",
+		"description": "Interpret the bits as a two's complement signed integer",
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// getBitwiseTools はビット演算と基数変換のツールの一覧を返します
+func getBitwiseTools() []toolDef {
+	wordOutput := func(extra map[string]any, required ...string) map[string]any {
+		props := map[string]any{
+			"value":    map[string]any{"type": "string", "description": "Decimal value, signed or unsigned as requested"},
+			"unsigned": map[string]any{"type": "string"},
+			"signed":   map[string]any{"type": "string"},
+			"hex":      map[string]any{"type": "string"},
+			"binary":   map[string]any{"type": "string"},
+			"octal":    map[string]any{"type": "string"},
+			"width":    map[string]any{"type": "integer"},
+		}
+		for k, v := range extra {
+			props[k] = v
+		}
+		return outputSchema(props, append([]string{"value", "unsigned", "signed", "hex", "binary", "octal", "width"}, required...)...)
+	}
+	binary := wordToolSchema(map[string]any{
+		"a": wordSchema("First operand"),
+		"b": wordSchema("Second operand"),
+	}, "a", "b")
+	shift := func(desc string) map[string]any {
+		return wordToolSchema(map[string]any{
+			"value":     wordSchema("Value to " + desc),
+			"amount":    map[string]any{"type": "integer", "minimum": 0, "maximum": 64, "description": "Number of bit positions"},
+			"direction": map[string]any{"type": "string", "enum": []string{"left", "right"}, "description": "Direction (defaults to left)"},
+		}, "value", "amount")
+	}
+
+	return bindTools([]Tool{
+		{
+			Name:         "bit_and",
+			Description:  "Bitwise AND of two integers of a fixed bit width",
+			InputSchema:  binary,
+			OutputSchema: wordOutput(nil),
+		},
+		{
+			Name:         "bit_or",
+			Description:  "Bitwise OR of two integers of a fixed bit width",
+			InputSchema:  binary,
+			OutputSchema: wordOutput(nil),
+		},
+		{
+			Name:         "bit_xor",
+			Description:  "Bitwise XOR of two integers of a fixed bit width",
+			InputSchema:  binary,
+			OutputSchema: wordOutput(nil),
+		},
+		{
+			Name:        "bit_not",
+			Description: "Bitwise NOT (one's complement) of an integer of a fixed bit width",
+			InputSchema: wordToolSchema(map[string]any{
+				"a": wordSchema("Operand"),
+			}, "a"),
+			OutputSchema: wordOutput(nil),
+		},
+		{
+			Name:         "bit_shift",
+			Description:  "Shift bits left or right. Right shifts are arithmetic for signed values and logical for unsigned values",
+			InputSchema:  shift("shift"),
+			OutputSchema: wordOutput(nil),
+		},
+		{
+			Name:         "bit_rotate",
+			Description:  "Rotate bits left or right within the bit width",
+			InputSchema:  shift("rotate"),
+			OutputSchema: wordOutput(nil),
+		},
+		{
+			Name:        "popcount",
+			Description: "Count set bits, leading and trailing zeros, and parity within the bit width",
+			InputSchema: wordToolSchema(map[string]any{
+				"value": wordSchema("Value"),
+			}, "value"),
+			OutputSchema: wordOutput(map[string]any{
+				"count":         map[string]any{"type": "integer"},
+				"leadingZeros":  map[string]any{"type": "integer"},
+				"trailingZeros": map[string]any{"type": "integer"},
+				"parity":        map[string]any{"type": "string", "enum": []string{"even", "odd"}},
+			}, "count", "leadingZeros", "trailingZeros", "parity"),
+		},
+		{
+			Name:        "twos_complement",
+			Description: "Signed and unsigned views of a bit pattern, and its two's complement negation",
+			InputSchema: wordToolSchema(map[string]any{
+				"value": wordSchema("Value or bit pattern, e.g. -1 or 0xFF"),
+			}, "value"),
+			OutputSchema: wordOutput(map[string]any{
+				"negated": map[string]any{"type": "string", "description": "Bit pattern of the two's complement negation"},
+			}, "negated"),
+		},
+		{
+			Name:        "convert_base",
+			Description: fmt.Sprintf("Convert an integer of any size between bases 2 and %d", maxBase),
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"value": map[string]any{"type": "string", "description": "Integer digits in the source base, e.g. ff, -101 or 0x1F"},
+					"from":  map[string]any{"type": "integer", "minimum": 2, "maximum": maxBase, "description": "Source base (defaults to 0x/0b/0o prefix detection, otherwise 10)"},
+					"to":    map[string]any{"type": "integer", "minimum": 2, "maximum": maxBase, "description": "Target base"},
+				},
+				"required": []string{"value", "to"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"result":  map[string]any{"type": "string", "description": "Digits in the target base (lowercase letters above 9)"},
+				"decimal": map[string]any{"type": "string"},
+			}, "result", "decimal"),
+		},
+		{
+			Name:        "float_bits",
+			Description: "IEEE-754 bit layout of a number (sign, exponent, fraction and exact stored value), or the number encoded by a bit pattern",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"value": map[string]any{
+						"type":        []string{"number", "string"},
+						"description": "Number to encode; strings such as \"0.1\", \"inf\" or \"nan\" are rounded once to the target precision",
+					},
+					"bits": map[string]any{
+						"type":        "string",
+						"pattern":     `^\s*(0[xX][0-9a-fA-F_]+|0[bB][01_]+)\s*$`,
+						"description": "Bit pattern to decode, e.g. 0x3FB999999999999A",
+					},
+					"precision": map[string]any{
+						"type":        "string",
+						"enum":        []string{"single", "double"},
+						"description": "binary32 (single) or binary64 (double, default)",
+					},
+				},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"bits":     map[string]any{"type": "string"},
+				"binary":   map[string]any{"type": "string", "description": "Sign, exponent and fraction fields separated by spaces"},
+				"sign":     map[string]any{"type": "integer"},
+				"exponent": map[string]any{"type": "integer", "description": "Unbiased exponent"},
+				"biased":   map[string]any{"type": "integer", "description": "Raw exponent field"},
+				"fraction": map[string]any{"type": "string", "description": "Raw fraction field in hex"},
+				"class":    map[string]any{"type": "string", "enum": []string{"zero", "subnormal", "normal", "infinity", "nan"}},
+				"exact":    map[string]any{"type": "string", "description": "Exact decimal value stored in the bits"},
+			}, "bits", "binary", "sign", "exponent", "biased", "fraction", "class", "exact"),
+		},
+	}, (*Server).handleBitwise)
+}
+
+// handleBitwise はビット演算ツールの呼び出しを処理します
+func (s *Server) handleBitwise(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	switch name {
+	case "bit_and", "bit_or", "bit_xor", "bit_not":
+		var params BitwiseParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return bitwise(name, params)
+	case "bit_shift", "bit_rotate":
+		var params ShiftParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return shiftBits(name, params)
+	case "popcount", "twos_complement":
+		var params WordParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		w, err := newWord(&params.Value.Int, params.WordOptions)
+		if err != nil {
+			return nil, err
+		}
+		if name == "popcount" {
+			return popcount(w), nil
+		}
+		negated := w
+		negated.bits = -w.bits & w.mask()
+		return w.result(map[string]any{"negated": negated.hex()}), nil
+	case "convert_base":
+		var params ConvertBaseParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return convertBase(params)
+	case "float_bits":
+		var params FloatBitsParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return floatBits(params)
+	default:
+		return nil, unknownTool(name)
+	}
+}
+
+// bitwise は AND、OR、XOR、NOT を計算します
+func bitwise(name string, params BitwiseParams) (*CallToolResult, *Error) {
+	a, err := newWord(&params.A.Int, params.WordOptions)
+	if err != nil {
+		return nil, err
+	}
+	if name == "bit_not" {
+		a.bits = ^a.bits & a.mask()
+		return a.result(nil), nil
+	}
+	if params.B == nil {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Missing operand b"}
+	}
+	b, err := newWord(&params.B.Int, params.WordOptions)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "bit_and":
+		a.bits &= b.bits
+	case "bit_or":
+		a.bits |= b.bits
+	case "bit_xor":
+		a.bits ^= b.bits
+	}
+	return a.result(nil), nil
+}
+
+// shiftBits はシフトまたはローテートを計算します
+func shiftBits(name string, params ShiftParams) (*CallToolResult, *Error) {
+	w, err := newWord(&params.Value.Int, params.WordOptions)
+	if err != nil {
+		return nil, err
+	}
+	if params.Amount < 0 || params.Amount > 64 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "amount must be between 0 and 64"}
+	}
+	left := true
+	switch params.Direction {
+	case "", "left":
+	case "right":
+		left = false
+	default:
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown direction '%s'", params.Direction)}
+	}
+
+	n := params.Amount
+	if name == "bit_rotate" {
+		n %= w.width
+		if !left {
+			n = (w.width - n) % w.width
+		}
+		w.bits = (w.bits<<n | w.bits>>(w.width-n)) & w.mask()
+		return w.result(nil), nil
+	}
+	switch {
+	case left:
+		// 幅以上のシフトは Go と同じくすべてのビットを押し出す
+		w.bits = (w.bits << n) & w.mask()
+	case w.signed:
+		w.bits = uint64(w.signedValue()>>n) & w.mask()
+	default:
+		w.bits >>= n
+	}
+	return w.result(nil), nil
+}
+
+// popcount は立っているビットを数えます
+func popcount(w word) *CallToolResult {
+	count := bits.OnesCount64(w.bits)
+	trailing := min(bits.TrailingZeros64(w.bits), w.width)
+	parity := "even"
+	if count%2 == 1 {
+		parity = "odd"
+	}
+	result := w.result(map[string]any{
+		"count":         count,
+		"leadingZeros":  bits.LeadingZeros64(w.bits) - (64 - w.width),
+		"trailingZeros": trailing,
+		"parity":        parity,
+	})
+	result.Content[0].Text = fmt.Sprintf("%d bits set in %s", count, w.binary())
+	return result
+}
+
+// convertBase は任意の大きさの整数の基数を変換します
+func convertBase(params ConvertBaseParams) (*CallToolResult, *Error) {
+	for _, b := range []int{params.From, params.To} {
+		if b != 0 && (b < 2 || b > maxBase) {
+			return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Bases must be between 2 and %d", maxBase)}
+		}
+	}
+	if params.To == 0 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Missing target base"}
+	}
+	v, err := parseBaseInteger(strings.TrimSpace(params.Value), params.From)
+	if err != nil {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Invalid arguments", Data: err.Error()}
+	}
+	text := v.Text(params.To)
+	// 10進以外のテキストを数値として読まないよう、10進の値を記録する
+	result := newStructuredResult(text, map[string]any{"result": text, "decimal": v.String()})
+	result.value = Number(v.String())
+	return result, nil
+}
+
+// floatLayout は IEEE-754 の2進形式のビット配置です
+type floatLayout struct {
+	width, exponentBits, fractionBits int
+}
+
+var (
+	binary32 = floatLayout{32, 8, 23}
+	binary64 = floatLayout{64, 11, 52}
+)
+
+// floatBits は数値を IEEE-754 のビット列に分解するか、ビット列を数値に戻します
+func floatBits(params FloatBitsParams) (*CallToolResult, *Error) {
+	layout := binary64
+	switch params.Precision {
+	case "", "double":
+	case "single":
+		layout = binary32
+	default:
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown precision '%s'", params.Precision)}
+	}
+
+	var raw uint64
+	switch {
+	case params.Value != nil && params.Bits != "":
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Specify either value or bits, not both"}
+	case params.Value != nil:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(*params.Value)), layout.width)
+		if err != nil && !strings.Contains(err.Error(), "value out of range") {
+			return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Invalid number '%s'", *params.Value)}
+		}
+		switch {
+		case math.IsNaN(f):
+			// ParseFloat が返す NaN のペイロードは実装依存なので、標準的な quiet NaN（指数部がすべて 1 で仮数部の最上位ビットだけが 1）にする
+			raw = (uint64(1)<<layout.exponentBits-1)<<layout.fractionBits | uint64(1)<<(layout.fractionBits-1)
+		case layout == binary32:
+			raw = uint64(math.Float32bits(float32(f)))
+		default:
+			raw = math.Float64bits(f)
+		}
+	case params.Bits != "":
+		v, err := parseBaseInteger(strings.TrimSpace(params.Bits), 0)
+		if err != nil || v.Sign() < 0 || v.BitLen() > layout.width {
+			return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("bits must be a %d-bit pattern", layout.width)}
+		}
+		raw = v.Uint64()
+	default:
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Specify value or bits"}
+	}
+
+	fractionMask := uint64(1)<<layout.fractionBits - 1
+	exponentMask := uint64(1)<<layout.exponentBits - 1
+	bias := int(exponentMask >> 1)
+	sign := int(raw >> (layout.width - 1))
+	biased := int(raw >> layout.fractionBits & exponentMask)
+	fraction := raw & fractionMask
+
+	var value float64
+	if layout == binary32 {
+		value = float64(math.Float32frombits(uint32(raw)))
+	} else {
+		value = math.Float64frombits(raw)
+	}
+	class, exponent := "normal", biased-bias
+	switch {
+	case biased == int(exponentMask) && fraction == 0:
+		class = "infinity"
+	case biased == int(exponentMask):
+		class = "nan"
+	case biased == 0 && fraction == 0:
+		class, exponent = "zero", 0
+	case biased == 0:
+		class, exponent = "subnormal", 1-bias
+	}
+
+	// 格納された値を丸めずに10進で表す
+	exact := strconv.FormatFloat(value, 'g', -1, 64)
+	if class != "infinity" && class != "nan" {
+		r := new(big.Rat).SetFloat64(value)
+		exact = formatRat(r)
+		if sign == 1 && r.Sign() == 0 {
+			exact = "-0"
+		}
+	}
+
+	b := fmt.Sprintf("%0*b", layout.width, raw)
+	fields := b[:1] + " " + b[1:1+layout.exponentBits] + " " + b[1+layout.exponentBits:]
+	hexBits := fmt.Sprintf("0x%0*X", layout.width/4, raw)
+	text := fmt.Sprintf("%s = %s (%s, exact %s)", hexBits, fields, class, exact)
+	return newStructuredResult(text, map[string]any{
+		"bits":     hexBits,
+		"binary":   fields,
+		"sign":     sign,
+		"exponent": exponent,
+		"biased":   biased,
+		"fraction": fmt.Sprintf("0x%X", fraction),
+		"class":    class,
+		"exact":    exact,
+	}), nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestBitwiseTools(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"bit_and", `{"a":"0xF0","b":"0x3C","width":8}`, "48 = 0x30 = 0b0011_0000"},
+		{"bit_or", `{"a":"0b1010","b":"0b0101","width":8}`, "15 = 0x0F = 0b0000_1111"},
+		{"bit_xor", `{"a":255,"b":15,"width":8}`, "240 = 0xF0 = 0b1111_0000"},
+		{"bit_not", `{"a":0,"width":8}`, "255 = 0xFF = 0b1111_1111"},
+		{"bit_not", `{"a":0,"width":8,"signed":true}`, "-1 = 0xFF = 0b1111_1111"},
+		// 負の値は2の補数のビット列として扱う
+		{"bit_and", `{"a":-1,"b":"0x1234","width":16}`, "4660 = 0x1234 = 0b0001_0010_0011_0100"},
+		{"bit_shift", `{"value":"0x81","amount":1,"width":8}`, "2 = 0x02 = 0b0000_0010"},
+		{"bit_shift", `{"value":1,"amount":8,"width":8}`, "0 = 0x00 = 0b0000_0000"},
+		// 右シフトは符号つきなら算術シフト、符号なしなら論理シフト
+		{"bit_shift", `{"value":-128,"amount":2,"direction":"right","width":8,"signed":true}`, "-32 = 0xE0 = 0b1110_0000"},
+		{"bit_shift", `{"value":"0x80","amount":2,"direction":"right","width":8}`, "32 = 0x20 = 0b0010_0000"},
+		{"bit_rotate", `{"value":"0x81","amount":1,"width":8}`, "3 = 0x03 = 0b0000_0011"},
+		{"bit_rotate", `{"value":"0x81","amount":1,"direction":"right","width":8}`, "192 = 0xC0 = 0b1100_0000"},
+		{"bit_rotate", `{"value":"0x1234","amount":20,"width":16}`, "9025 = 0x2341 = 0b0010_0011_0100_0001"},
+		{"bit_rotate", `{"value":"0x81","amount":0,"width":8}`, "129 = 0x81 = 0b1000_0001"},
+		{"popcount", `{"value":"0xF0","width":8}`, "4 bits set in 0b1111_0000"},
+		{"twos_complement", `{"value":-1,"width":8}`, "255 = 0xFF = 0b1111_1111"},
+		{"twos_complement", `{"value":"0xFF","width":8,"signed":true}`, "-1 = 0xFF = 0b1111_1111"},
+		{"convert_base", `{"value":"ff","from":16,"to":2}`, "11111111"},
+		{"convert_base", `{"value":"0x1F","to":10}`, "31"},
+		{"convert_base", `{"value":"-255","to":16}`, "-ff"},
+		{"convert_base", `{"value":"zz","from":36,"to":10}`, "1295"},
+		// float64 を経由しない任意の大きさの整数
+		{"convert_base", `{"value":"0x1_0000_0000_0000_0000_0000_0001","to":10}`, "79228162514264337593543950337"},
+		{"float_bits", `{"value":1,"precision":"single"}`, "0x3F800000 = 0 01111111 00000000000000000000000 (normal, exact 1)"},
+		{"float_bits", `{"bits":"0xC0490FDB","precision":"single"}`, "0xC0490FDB = 1 10000000 10010010000111111011011 (normal, exact -3.1415927410125732421875)"},
+		{"float_bits", `{"bits":"0x7F800000","precision":"single"}`, "0x7F800000 = 0 11111111 00000000000000000000000 (infinity, exact +Inf)"},
+	}
+
+	for _, tt := range tests {
+		if got := callText(t, s, tt.name, tt.args); got != tt.want {
+			t.Errorf("%s %s: expected %s, got %s", tt.name, tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestBitwiseStructured(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want map[string]any
+	}{
+		// 64ビットの符号なし整数も桁を落とさない
+		{"bit_not", `{"a":0}`, map[string]any{"value": "18446744073709551615", "signed": "-1", "hex": "0xFFFFFFFFFFFFFFFF", "width": 64}},
+		{"bit_xor", `{"a":18446744073709551615,"b":1}`, map[string]any{"value": "18446744073709551614", "octal": "0o1777777777777777777776"}},
+		{"popcount", `{"value":0,"width":16}`, map[string]any{"count": 0, "leadingZeros": 16, "trailingZeros": 16, "parity": "even"}},
+		{"popcount", `{"value":"0b1011_0000","width":8}`, map[string]any{"count": 3, "leadingZeros": 0, "trailingZeros": 4, "parity": "odd"}},
+		{"twos_complement", `{"value":5,"width":8}`, map[string]any{"unsigned": "5", "signed": "5", "negated": "0xFB"}},
+		{"twos_complement", `{"value":-128,"width":8,"signed":true}`, map[string]any{"value": "-128", "unsigned": "128", "negated": "0x80"}},
+		{"float_bits", `{"value":0.1}`, map[string]any{
+			"bits": "0x3FB999999999999A", "sign": 0, "exponent": -4, "biased": 1019, "fraction": "0x999999999999A", "class": "normal",
+			"exact": "0.1000000000000000055511151231257827021181583404541015625",
+		}},
+		// 文字列の値は1回だけ単精度に丸める
+		{"float_bits", `{"value":"0.1","precision":"single"}`, map[string]any{"bits": "0x3DCCCCCD", "exact": "0.100000001490116119384765625"}},
+		{"float_bits", `{"value":"-0"}`, map[string]any{"bits": "0x8000000000000000", "sign": 1, "class": "zero", "exact": "-0"}},
+		// NaN は標準的な quiet NaN のビット列にする
+		{"float_bits", `{"value":"nan"}`, map[string]any{"class": "nan", "bits": "0x7FF8000000000000", "fraction": "0x8000000000000"}},
+		{"float_bits", `{"value":"NaN","precision":"single"}`, map[string]any{"class": "nan", "bits": "0x7FC00000"}},
+		{"float_bits", `{"bits":"0x0000000000000001"}`, map[string]any{"class": "subnormal", "exponent": -1022, "biased": 0, "fraction": "0x1"}},
+	}
+
+	for _, tt := range tests {
+		result, err := s.callTool(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err != nil {
+			t.Fatalf("%s %s: unexpected error: %v", tt.name, tt.args, err)
+		}
+		data := result.StructuredContent.(map[string]any)
+		for k, want := range tt.want {
+			if data[k] != want {
+				t.Errorf("%s %s: expected %s %v, got %v", tt.name, tt.args, k, want, data[k])
+			}
+		}
+	}
+}
+
+func TestBitwiseReferences(t *testing.T) {
+	s := newTestServer()
+	// 16進表記の結果も10進の値として参照できる
+	callText(t, s, "bit_and", `{"a":"0xF0","b":"0x3C","width":8}`)
+	if got := callText(t, s, "bit_or", `{"a":"$1","b":1,"width":8}`); got != "49 = 0x31 = 0b0011_0001" {
+		t.Errorf("expected 49 = 0x31 = 0b0011_0001, got %s", got)
+	}
+}
+
+func TestBitwiseErrors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+	}{
+		{"bit_and", `{"a":1}`},
+		{"bit_and", `{"a":1,"b":2,"width":12}`},
+		// 幅に収まらない値
+		{"bit_and", `{"a":256,"b":1,"width":8}`},
+		{"bit_not", `{"a":-129,"width":8}`},
+		{"bit_not", `{"a":"0x1G"}`},
+		{"bit_not", `{"a":1.5}`},
+		{"bit_shift", `{"value":1,"amount":65}`},
+		{"bit_shift", `{"value":1,"amount":1,"direction":"up"}`},
+		{"convert_base", `{"value":"12","from":2,"to":10}`},
+		{"convert_base", `{"value":"10","to":37}`},
+		{"convert_base", `{"value":"--1","to":2}`},
+		{"float_bits", `{}`},
+		{"float_bits", `{"value":1,"bits":"0x0"}`},
+		{"float_bits", `{"bits":"0x1FFFFFFFF","precision":"single"}`},
+		{"float_bits", `{"value":"abc"}`},
+	}
+
+	for _, tt := range tests {
+		_, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err == nil || err.Code != ErrorInvalidParams {
+			t.Errorf("%s %s: expected error code %d, got %v", tt.name, tt.args, ErrorInvalidParams, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CancelledParams は notifications/cancelled のパラメータを表します
+type CancelledParams struct {
+	RequestID json.RawMessage `json:"requestId"`
+	Reason    string          `json:"reason,omitempty"`
+}
+
+// withMaxConcurrency は同時に実行できるツールの呼び出し数を設定します
+func withMaxConcurrency(n int) serverOption {
+	return func(s *Server) error {
+		if n < 1 {
+			return fmt.Errorf("max concurrency must be at least 1, got %d", n)
+		}
+		s.maxConcurrency = n
+		return nil
+	}
+}
+
+// requestKey はリクエスト ID を比較できる形に正規化します
+func requestKey(id json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, id); err != nil {
+		return string(id)
+	}
+	return buf.String()
+}
+
+// startRequest は ctx からキャンセルできるコンテキストを作り、リクエスト ID に登録します。
+// 返された関数は処理の完了時に呼び出して登録を解除します
+func (s *Server) startRequest(ctx context.Context, id json.RawMessage) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	key := requestKey(id)
+
+	s.mu.Lock()
+	s.inflight[key] = cancel
+	s.mu.Unlock()
+
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.inflight, key)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelRequest は実行中のリクエストをキャンセルします。
+// 既に完了しているか未知の ID の場合は何もせず false を返します
+func (s *Server) cancelRequest(id json.RawMessage) bool {
+	s.mu.Lock()
+	cancel, ok := s.inflight[requestKey(id)]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// handleCancelled は notifications/cancelled を処理します
+func (s *Server) handleCancelled(raw json.RawMessage) {
+	var params CancelledParams
+	if err := json.Unmarshal(raw, &params); err != nil || len(params.RequestID) == 0 {
+		s.log(LevelWarning, "server", "Ignoring malformed notifications/cancelled", nil)
+		return
+	}
+	if !s.cancelRequest(params.RequestID) {
+		// 応答済みのリクエストに対するキャンセルは仕様上起こり得るので無視する
+		s.log(LevelDebug, "server", "No request to cancel", map[string]any{"requestId": string(params.RequestID)})
+		return
+	}
+	s.log(LevelInfo, "server", "Cancelled request", map[string]any{
+		"requestId": string(params.RequestID),
+		"reason":    params.Reason,
+	})
+}
+
+// acquireSlot はツールを実行する枠が空くまで待ちます。待っている間にキャンセルされるとエラーを返します
+func (s *Server) acquireSlot(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) releaseSlot() {
+	<-s.slots
+}
+
+// requestCancelled はキャンセルされたリクエストのエラーを返します
+func requestCancelled(err error) *Error {
+	return &Error{
+		Code:    ErrorRequestCancelled,
+		Message: "Request cancelled",
+		Data:    err.Error(),
+	}
+}
+
+// currentState はライフサイクルの状態を返します
+func (s *Server) currentState() lifecycleState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
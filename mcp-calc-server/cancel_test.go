@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBusyServer は同時実行数が1で、その枠が埋まっている初期化済みのサーバーを返します
+func newBusyServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := newServer(withMaxConcurrency(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	roundTrip(t, s, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	s.slots <- struct{}{}
+	return s
+}
+
+// waitInflight はリクエストが実行中として登録されるまで待ちます
+func waitInflight(t *testing.T, s *Server, key string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		_, ok := s.inflight[key]
+		s.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("request %s was never registered", key)
+}
+
+func TestToolCallDoesNotBlockOtherRequests(t *testing.T) {
+	s := newBusyServer(t)
+
+	done := make(chan string)
+	go func() {
+		done <- roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}}`)
+	}()
+	waitInflight(t, s, "1")
+
+	// 実行待ちのツール呼び出しがあっても他のリクエストには応答する
+	if out := roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); !strings.Contains(out, `"name":"add"`) {
+		t.Errorf("expected tools/list to respond, got %s", out)
+	}
+
+	<-s.slots
+	if out := <-done; !strings.Contains(out, `"text":"3.000000"`) {
+		t.Errorf("expected result once a slot is free, got %s", out)
+	}
+}
+
+func TestCancelledToolCall(t *testing.T) {
+	s := newBusyServer(t)
+
+	done := make(chan string)
+	go func() {
+		done <- roundTrip(t, s, `{"jsonrpc":"2.0","id":"req-1","method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}}`)
+	}()
+	waitInflight(t, s, `"req-1"`)
+
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"req-1","reason":"user abort"}}`)
+	if out != "" {
+		t.Errorf("expected no reply to notification, got %s", out)
+	}
+	// キャンセルされたリクエストには応答しない
+	select {
+	case out := <-done:
+		if out != "" {
+			t.Errorf("expected no response for cancelled request, got %s", out)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelled request did not finish")
+	}
+
+	s.mu.Lock()
+	remaining := len(s.inflight)
+	s.mu.Unlock()
+	if remaining != 0 {
+		t.Errorf("expected no inflight requests, got %d", remaining)
+	}
+
+	// 未知の ID や完了済みのリクエストへのキャンセルは無視する
+	roundTrip(t, s, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"req-1"}}`)
+	roundTrip(t, s, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{}}`)
+}
+
+func TestCancelRightAfterToolCallOverStdio(t *testing.T) {
+	s := newBusyServer(t)
+	// 呼び出しの直後に届いたキャンセルも、実行を待っているリクエストに届く
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}}
+{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}
+`)
+	var out bytes.Buffer
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.serveStdio(in, &out)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancelled request did not finish")
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no response for cancelled request, got %s", out.String())
+	}
+}
+
+func TestCancelToolCallInBatchOverStdio(t *testing.T) {
+	s := newBusyServer(t)
+	// バッチ内の tools/call を待っている間も次の行を読み、キャンセルを受け付ける
+	in := strings.NewReader(`[{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}},{"jsonrpc":"2.0","id":2,"method":"ping"}]
+{"jsonrpc":"2.0","id":3,"method":"ping"}
+{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}
+`)
+	var out bytes.Buffer
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.serveStdio(in, &out)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancelled request in batch did not finish")
+	}
+
+	// キャンセルされた呼び出しの応答はバッチの結果に含めない
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		`{"jsonrpc":"2.0","result":{},"id":3}`,
+		`[{"jsonrpc":"2.0","result":{},"id":2}]`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestMaxConcurrencyOption(t *testing.T) {
+	s, err := newServer(withMaxConcurrency(3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cap(s.slots) != 3 {
+		t.Errorf("expected 3 slots, got %d", cap(s.slots))
+	}
+	if _, err := newServer(withMaxConcurrency(0)); err == nil {
+		t.Error("expected error for zero concurrency")
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+// complexArg は複素数の引数を表します。{"re": 3, "im": 4}、"3+4i"、数値のいずれでも受け付けます
+type complexArg complex128
+
+// UnmarshalJSON は複素数をオブジェクト、文字列、数値から読み込みます
+func (c *complexArg) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) > 0 && data[0] == '{':
+		var v struct {
+			Re *float64 `json:"re"`
+			Im *float64 `json:"im"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if v.Re == nil && v.Im == nil {
+			return fmt.Errorf("complex number object needs re or im")
+		}
+		var re, im float64
+		if v.Re != nil {
+			re = *v.Re
+		}
+		if v.Im != nil {
+			im = *v.Im
+		}
+		*c = complexArg(complex(re, im))
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		z, err := parseComplex(s)
+		if err != nil {
+			return err
+		}
+		*c = complexArg(z)
+	default:
+		var f float64
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("expected a complex number, got %s", data)
+		}
+		*c = complexArg(complex(f, 0))
+	}
+	return nil
+}
+
+// parseComplex は "3+4i" や "2-j" のような表記を解析します。虚数単位には i と j の両方を使えます
+func parseComplex(s string) (complex128, error) {
+	t := strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	t = strings.TrimSuffix(strings.TrimPrefix(t, "("), ")")
+	if strings.HasSuffix(t, "j") {
+		t = strings.TrimSuffix(t, "j") + "i"
+	}
+	// 係数を省略した虚数単位（i、3-i など）は 1 を補う
+	if strings.HasSuffix(t, "i") {
+		head := t[:len(t)-1]
+		if head == "" || strings.HasSuffix(head, "+") || strings.HasSuffix(head, "-") {
+			t = head + "1i"
+		}
+	}
+	z, err := strconv.ParseComplex(t, 128)
+	if err != nil || cmplx.IsNaN(z) || cmplx.IsInf(z) {
+		return 0, fmt.Errorf("invalid complex number %q", s)
+	}
+	return z, nil
+}
+
+// ComplexParams は複素数ツールのパラメータを表します
+type ComplexParams struct {
+	A       complexArg `json:"a"`
+	B       complexArg `json:"b"`
+	Degrees bool       `json:"degrees,omitempty"` // 偏角を度で扱う
+}
+
+// PolarParams は complex_from_polar ツールのパラメータを表します
+type PolarParams struct {
+	Modulus  float64 `json:"modulus"`
+	Argument float64 `json:"argument"`
+	Degrees  bool    `json:"degrees,omitempty"`
+}
+
+// complexSchema は複素数の引数の入力スキーマです
+func complexSchema(description string) map[string]any {
+	return map[string]any{
+		"type": []string{"object", "string", "number"},
+		"properties": map[string]any{
+			"re": map[string]any{"type": "number", "description": "Real part"},
+			"im": map[string]any{"type": "number", "description": "Imaginary part"},
+		},
+		"description": description + ` as {"re": 3, "im": 4} or a string such as "3+4i" (j is also accepted)`,
+	}
+}
+
+// getComplexTools は複素数ツールの一覧を返します
+func getComplexTools() []toolDef {
+	degrees := map[string]any{
+		"type":        "boolean",
+		"description": "Express the argument in degrees instead of radians",
+	}
+	unary := func(extra map[string]any) map[string]any {
+		properties := map[string]any{
+			"a":      complexSchema("Complex number"),
+			"format": formatSchema,
+		}
+		for k, v := range extra {
+			properties[k] = v
+		}
+		return map[string]any{"type": "object", "properties": properties, "required": []string{"a"}}
+	}
+	binary := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"a":      complexSchema("First operand"),
+			"b":      complexSchema("Second operand"),
+			"format": formatSchema,
+		},
+		"required": []string{"a", "b"},
+	}
+	complexOutput := outputSchema(map[string]any{
+		"re": map[string]any{"type": "number"},
+		"im": map[string]any{"type": "number"},
+	}, "re", "im")
+	polarOutput := outputSchema(map[string]any{
+		"modulus":  map[string]any{"type": "number"},
+		"argument": map[string]any{"type": "number"},
+	}, "modulus", "argument")
+
+	return bindTools([]Tool{
+		{Name: "complex_add", Description: "Add two complex numbers", InputSchema: binary, OutputSchema: complexOutput},
+		{Name: "complex_subtract", Description: "Subtract the second complex number from the first", InputSchema: binary, OutputSchema: complexOutput},
+		{Name: "complex_multiply", Description: "Multiply two complex numbers", InputSchema: binary, OutputSchema: complexOutput},
+		{Name: "complex_divide", Description: "Divide the first complex number by the second", InputSchema: binary, OutputSchema: complexOutput},
+		{Name: "complex_modulus", Description: "Modulus |z| of a complex number", InputSchema: unary(nil), OutputSchema: valueOutputSchema},
+		{Name: "complex_argument", Description: "Argument (phase angle) of a complex number in (-π, π]", InputSchema: unary(map[string]any{"degrees": degrees}), OutputSchema: valueOutputSchema},
+		{Name: "complex_conjugate", Description: "Complex conjugate of a complex number", InputSchema: unary(nil), OutputSchema: complexOutput},
+		{Name: "complex_to_polar", Description: "Convert a complex number to polar form (modulus and argument)", InputSchema: unary(map[string]any{"degrees": degrees}), OutputSchema: polarOutput},
+		{
+			Name:        "complex_from_polar",
+			Description: "Convert polar form (modulus and argument) to a complex number",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"modulus":  map[string]any{"type": "number", "minimum": 0},
+					"argument": map[string]any{"type": "number", "description": "Argument in radians, or degrees when degrees is true"},
+					"degrees":  degrees,
+					"format":   formatSchema,
+				},
+				"required": []string{"modulus", "argument"},
+			},
+			OutputSchema: complexOutput,
+		},
+		{Name: "complex_sqrt", Description: "Principal square root of a complex number, e.g. sqrt(-1) = i", InputSchema: unary(nil), OutputSchema: complexOutput},
+		{Name: "complex_exp", Description: "Complex exponential e^z", InputSchema: unary(nil), OutputSchema: complexOutput},
+		{Name: "complex_log", Description: "Principal natural logarithm of a non-zero complex number", InputSchema: unary(nil), OutputSchema: complexOutput},
+	}, (*Server).handleComplex)
+}
+
+// handleComplex は複素数ツールの呼び出しを処理します
+func (s *Server) handleComplex(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	if name == "complex_from_polar" {
+		var params PolarParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		theta := params.Argument
+		if params.Degrees {
+			theta = theta * math.Pi / 180
+		}
+		return complexResult(ctx, name, cmplx.Rect(params.Modulus, theta))
+	}
+
+	var params ComplexParams
+	if err := unmarshalArgs(args, &params); err != nil {
+		return nil, err
+	}
+	a, b := complex128(params.A), complex128(params.B)
+
+	switch name {
+	case "complex_add":
+		return complexResult(ctx, name, a+b)
+	case "complex_subtract":
+		return complexResult(ctx, name, a-b)
+	case "complex_multiply":
+		return complexResult(ctx, name, a*b)
+	case "complex_divide":
+		if b == 0 {
+			return nil, &Error{Code: ErrorDivideByZero, Message: "Division by zero"}
+		}
+		return complexResult(ctx, name, a/b)
+	case "complex_modulus":
+		// 実部と虚部が有限でも |z| は float64 の範囲を超えることがある
+		value := cmplx.Abs(a)
+		if !finite(value) {
+			return nil, outOfRange(name)
+		}
+		return newStructuredResult(formatNumber(ctx, value), map[string]any{"value": value}), nil
+	case "complex_argument":
+		value := angle(cmplx.Phase(a), params.Degrees)
+		return newStructuredResult(formatNumber(ctx, value), map[string]any{"value": value}), nil
+	case "complex_conjugate":
+		return complexResult(ctx, name, cmplx.Conj(a))
+	case "complex_to_polar":
+		r, theta := cmplx.Polar(a)
+		if !finite(r) {
+			return nil, outOfRange(name)
+		}
+		theta = angle(theta, params.Degrees)
+		unit := ""
+		if params.Degrees {
+			unit = "°"
+		}
+		text := fmt.Sprintf("%s ∠ %s%s", formatNumber(ctx, r), formatNumber(ctx, theta), unit)
+		return newStructuredResult(text, map[string]any{"modulus": r, "argument": theta}), nil
+	case "complex_sqrt":
+		return complexResult(ctx, name, cmplx.Sqrt(a))
+	case "complex_exp":
+		return complexResult(ctx, name, cmplx.Exp(a))
+	case "complex_log":
+		if a == 0 {
+			return nil, &Error{Code: ErrorMathDomain, Message: "Logarithm of zero is undefined"}
+		}
+		return complexResult(ctx, name, cmplx.Log(a))
+	default:
+		return nil, unknownTool(name)
+	}
+}
+
+// complexResult は複素数の結果を a + bi のテキストと {re, im} の構造化データで返します
+func complexResult(ctx context.Context, name string, z complex128) (*CallToolResult, *Error) {
+	if cmplx.IsNaN(z) || cmplx.IsInf(z) {
+		return nil, &Error{
+			Code:    ErrorMathDomain,
+			Message: fmt.Sprintf("Result of %s is out of range", name),
+		}
+	}
+	// -0 の虚部は 0 として表示する
+	c := complexValue{Re: real(z) + 0, Im: imag(z) + 0}
+	result := newStructuredResult(c.format(numberFormat(ctx)), map[string]any{"re": c.Re, "im": c.Im})
+	// 実数になった結果は履歴から参照できるようにする
+	if c.Im == 0 {
+		result.value = floatNumber(c.Re)
+	}
+	return result, nil
+}
+
+// angle はラジアンの角度を必要に応じて度に変換します
+func angle(theta float64, degrees bool) float64 {
+	if degrees {
+		return theta * 180 / math.Pi
+	}
+	return theta
+}
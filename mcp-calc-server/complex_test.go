@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseComplex(t *testing.T) {
+	tests := []struct {
+		input string
+		want  complex128
+	}{
+		{"3+4i", complex(3, 4)},
+		{"3 - 4i", complex(3, -4)},
+		{"i", complex(0, 1)},
+		{"-i", complex(0, -1)},
+		{"2-j", complex(2, -1)},
+		{"(1.5e3+2.5j)", complex(1500, 2.5)},
+		{"-7", complex(-7, 0)},
+	}
+	for _, tt := range tests {
+		got, err := parseComplex(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("%q: expected %v, got %v (%v)", tt.input, tt.want, got, err)
+		}
+	}
+	for _, input := range []string{"", "3+4k", "inf", "1+NaNi"} {
+		if _, err := parseComplex(input); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+}
+
+func TestComplexTools(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"complex_add", `{"a":{"re":1,"im":2},"b":"3-4i"}`, "4.000000 - 2.000000i"},
+		{"complex_subtract", `{"a":"3+4i","b":{"im":4}}`, "3.000000"},
+		{"complex_multiply", `{"a":"1+2i","b":"3+4i"}`, "-5.000000 + 10.000000i"},
+		{"complex_divide", `{"a":"-5+10i","b":"3+4i"}`, "1.000000 + 2.000000i"},
+		{"complex_modulus", `{"a":"3+4i"}`, "5.000000"},
+		{"complex_argument", `{"a":"i","degrees":true}`, "90.000000"},
+		{"complex_conjugate", `{"a":"3+4i"}`, "3.000000 - 4.000000i"},
+		{"complex_to_polar", `{"a":"1+i","degrees":true}`, "1.414214 ∠ 45.000000°"},
+		{"complex_from_polar", `{"modulus":2,"argument":90,"degrees":true}`, "0.000000 + 2.000000i"},
+		// sqrt(-1) = i
+		{"complex_sqrt", `{"a":-1}`, "0.000000 + 1.000000i"},
+		{"complex_exp", `{"a":{"im":3.141592653589793}}`, "-1.000000 + 0.000000i"},
+		{"complex_log", `{"a":-1}`, "0.000000 + 3.141593i"},
+		{"complex_modulus", `{"a":"3+4i","format":{"notation":"significant","digits":2}}`, "5.0"},
+	}
+
+	for _, tt := range tests {
+		if got := callText(t, s, tt.name, tt.args); got != tt.want {
+			t.Errorf("%s %s: expected %s, got %s", tt.name, tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestComplexToolErrors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		code int
+	}{
+		{"complex_divide", `{"a":"1+i","b":"0"}`, ErrorDivideByZero},
+		{"complex_log", `{"a":{"re":0,"im":0}}`, ErrorMathDomain},
+		{"complex_add", `{"a":"3+4k","b":1}`, ErrorInvalidParams},
+		{"complex_add", `{"a":{},"b":1}`, ErrorInvalidParams},
+		{"complex_add", `{"a":true,"b":1}`, ErrorInvalidParams},
+		{"complex_exp", `{"a":1000}`, ErrorMathDomain},
+		{"complex_modulus", `{"a":{"re":1.5e308,"im":1.5e308}}`, ErrorMathDomain},
+		{"complex_to_polar", `{"a":{"re":1.5e308,"im":1.5e308}}`, ErrorMathDomain},
+	}
+
+	for _, tt := range tests {
+		_, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err == nil || err.Code != tt.code {
+			t.Errorf("%s %s: expected error code %d, got %v", tt.name, tt.args, tt.code, err)
+		}
+	}
+}
+
+func TestComplexStructuredResult(t *testing.T) {
+	s := newTestServer()
+	callText(t, s, "complex_modulus", `{"a":"3+4i"}`)
+
+	// 実数の結果は $1 として複素数の引数に使える
+	result, err := s.handleToolCall(context.Background(), "complex_multiply", json.RawMessage(`{"a":"$1","b":"i"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := result.StructuredContent.(map[string]any)
+	if data["re"] != 0.0 || data["im"] != 5.0 {
+		t.Errorf("expected {re: 0, im: 5}, got %v", data)
+	}
+}
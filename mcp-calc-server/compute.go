@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// 長時間の計算ツールの制限
+const (
+	maxFactorialN               = 20000
+	maxSieveLimit               = 100_000_000
+	maxListedPrimes             = 10000
+	defaultIntervals            = 1000
+	maxIntervals                = 10_000_000
+	defaultSamples              = 100_000
+	maxSamples                  = 100_000_000
+	sieveSegmentSize            = 1 << 16
+	defaultIntegrationTolerance = 1e-10
+)
+
+// FactorialParams は factorial ツールのパラメータを表します
+type FactorialParams struct {
+	N int `json:"n"`
+}
+
+// PrimeSieveParams は prime_sieve ツールのパラメータを表します
+type PrimeSieveParams struct {
+	Limit int  `json:"limit"`
+	List  bool `json:"list,omitempty"` // 素数の一覧も返す
+}
+
+// IntegrateParams は integrate / monte_carlo ツールのパラメータを表します
+type IntegrateParams struct {
+	Expression string  `json:"expression"`
+	Variable   string  `json:"variable,omitempty"`
+	A          float64 `json:"a"`
+	B          float64 `json:"b"`
+	Method     string  `json:"method,omitempty"`    // integrate の積分法（simpson または adaptive）
+	Intervals  int     `json:"intervals,omitempty"` // integrate の分割数
+	Tolerance  float64 `json:"tolerance,omitempty"` // integrate の許容誤差
+	Samples    int     `json:"samples,omitempty"`   // monte_carlo の標本数
+	Seed       *uint64 `json:"seed,omitempty"`      // monte_carlo の乱数のシード
+}
+
+// functionSchema は1変数関数の式と区間を受け取る入力スキーマを生成します
+func functionSchema(extra map[string]any) map[string]any {
+	properties := map[string]any{
+		"expression": map[string]any{
+			"type":        "string",
+			"description": "Integrand as an expression in the variable, e.g. sin(x)^2",
+		},
+		"variable": map[string]any{
+			"type":        "string",
+			"description": "Name of the integration variable (defaults to x)",
+		},
+		"a":      map[string]any{"type": "number", "description": "Lower bound of the interval"},
+		"b":      map[string]any{"type": "number", "description": "Upper bound of the interval"},
+		"format": formatSchema,
+	}
+	for k, v := range extra {
+		properties[k] = v
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{"expression", "a", "b"},
+	}
+}
+
+// getComputeTools は時間のかかる計算ツールの一覧を返します。
+// これらのツールは _meta.progressToken が指定されると進捗を通知します
+func getComputeTools() []toolDef {
+	return bindTools([]Tool{
+		{
+			Name:        "factorial",
+			Description: "Exact factorial n! of a non-negative integer",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"n": map[string]any{"type": "integer", "minimum": 0, "maximum": maxFactorialN},
+				},
+				"required": []string{"n"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"value":  map[string]any{"type": "string", "description": "Exact decimal digits of n!"},
+				"digits": map[string]any{"type": "integer"},
+			}, "value", "digits"),
+		},
+		{
+			Name:        "prime_sieve",
+			Description: "Count the primes up to a limit with a segmented sieve of Eratosthenes",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"limit": map[string]any{"type": "integer", "minimum": 2, "maximum": maxSieveLimit},
+					"list": map[string]any{
+						"type":        "boolean",
+						"description": fmt.Sprintf("Also return the primes (at most %d)", maxListedPrimes),
+					},
+				},
+				"required": []string{"limit"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"count":   map[string]any{"type": "integer"},
+				"largest": map[string]any{"type": "integer"},
+				"primes":  map[string]any{"type": "array", "items": map[string]any{"type": "integer"}},
+			}, "count", "largest"),
+		},
+		{
+			Name:        "integrate",
+			Description: "Definite integral of an expression over [a, b] using the composite or adaptive Simpson rule, with an error estimate",
+			InputSchema: functionSchema(map[string]any{
+				"method": map[string]any{
+					"type":        "string",
+					"enum":        []string{"simpson", "adaptive"},
+					"description": "simpson uses a fixed number of subintervals, adaptive refines them until the tolerance is met (defaults to simpson)",
+				},
+				"intervals": map[string]any{
+					"type":        "integer",
+					"minimum":     2,
+					"maximum":     maxIntervals,
+					"description": fmt.Sprintf("Number of subintervals for simpson, rounded up to a multiple of 4 (defaults to %d)", defaultIntervals),
+				},
+				"tolerance": map[string]any{
+					"type":        "number",
+					"minimum":     0,
+					"description": fmt.Sprintf("Absolute error tolerance (defaults to %g)", defaultIntegrationTolerance),
+				},
+			}),
+			OutputSchema: outputSchema(map[string]any{
+				"value":         map[string]any{"type": "number"},
+				"method":        map[string]any{"type": "string"},
+				"intervals":     map[string]any{"type": "integer"},
+				"evaluations":   map[string]any{"type": "integer"},
+				"converged":     map[string]any{"type": "boolean", "description": "Whether the error estimate is within the tolerance"},
+				"errorEstimate": map[string]any{"type": "number"},
+			}, "value", "method", "intervals", "evaluations", "converged", "errorEstimate"),
+		},
+		{
+			Name:        "monte_carlo",
+			Description: "Monte Carlo estimate of the integral of an expression over [a, b] with its standard error",
+			// シードを指定しない場合は呼び出しごとに推定値が変わる
+			Annotations: &ToolAnnotations{ReadOnlyHint: true},
+			InputSchema: functionSchema(map[string]any{
+				"samples": map[string]any{
+					"type":        "integer",
+					"minimum":     2,
+					"maximum":     maxSamples,
+					"description": fmt.Sprintf("Number of random samples (defaults to %d)", defaultSamples),
+				},
+				"seed": map[string]any{
+					"type":        "integer",
+					"minimum":     0,
+					"description": "Seed for a reproducible estimate",
+				},
+			}),
+			OutputSchema: outputSchema(map[string]any{
+				"value":         map[string]any{"type": "number"},
+				"standardError": map[string]any{"type": "number"},
+				"samples":       map[string]any{"type": "integer"},
+			}, "value", "standardError", "samples"),
+		},
+	}, (*Server).handleCompute)
+}
+
+// handleCompute は時間のかかる計算ツールの呼び出しを処理します
+func (s *Server) handleCompute(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	switch name {
+	case "factorial":
+		var params FactorialParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return factorial(ctx, params.N)
+	case "prime_sieve":
+		var params PrimeSieveParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return primeSieve(ctx, params)
+	case "integrate", "monte_carlo":
+		var params IntegrateParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		f, err := compileFunction(params.Expression, params.Variable, s.memory.env())
+		if err != nil {
+			return nil, err
+		}
+		if math.IsInf(params.A, 0) || math.IsInf(params.B, 0) {
+			return nil, &Error{Code: ErrorInvalidParams, Message: "a and b must be finite"}
+		}
+		if name == "monte_carlo" {
+			return monteCarlo(ctx, f, params)
+		}
+		if params.Tolerance == 0 {
+			params.Tolerance = defaultIntegrationTolerance
+		}
+		switch params.Method {
+		case "", "simpson":
+			return simpson(ctx, f, params)
+		case "adaptive":
+			return adaptiveSimpson(ctx, f, params)
+		default:
+			return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown method '%s'", params.Method)}
+		}
+	default:
+		return nil, unknownTool(name)
+	}
+}
+
+// unmarshalArgs はツールの引数を params に読み込みます
+func unmarshalArgs(args json.RawMessage, params any) *Error {
+	if err := json.Unmarshal(args, params); err != nil {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+	return nil
+}
+
+// factorial は n! を正確に計算します
+func factorial(ctx context.Context, n int) (*CallToolResult, *Error) {
+	if n < 0 || n > maxFactorialN {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("n must be between 0 and %d", maxFactorialN),
+		}
+	}
+	result := big.NewInt(1)
+	step := progressInterval(n)
+	var k big.Int
+	for i := 2; i <= n; i++ {
+		result.Mul(result, k.SetInt64(int64(i)))
+		if i%step == 0 {
+			if err := checkpoint(ctx, i, n); err != nil {
+				return nil, err
+			}
+		}
+	}
+	text := result.String()
+	return newStructuredResult(text, map[string]any{"value": text, "digits": len(text)}), nil
+}
+
+// primeSieve は区分篩で limit 以下の素数を数えます
+func primeSieve(ctx context.Context, params PrimeSieveParams) (*CallToolResult, *Error) {
+	limit := params.Limit
+	if limit < 2 || limit > maxSieveLimit {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("limit must be between 2 and %d", maxSieveLimit),
+		}
+	}
+
+	// √limit 以下の素数で各区間をふるう
+	root := int(math.Sqrt(float64(limit)))
+	for (root+1)*(root+1) <= limit {
+		root++
+	}
+	small := make([]bool, root+1)
+	var base []int
+	for i := 2; i <= root; i++ {
+		if small[i] {
+			continue
+		}
+		base = append(base, i)
+		for j := i * i; j <= root; j += i {
+			small[j] = true
+		}
+	}
+
+	var count, largest int
+	var primes []int
+	composite := make([]bool, sieveSegmentSize)
+	segments := (limit + sieveSegmentSize) / sieveSegmentSize
+	step := progressInterval(segments)
+	for seg := 0; seg < segments; seg++ {
+		low := seg * sieveSegmentSize
+		high := min(low+sieveSegmentSize-1, limit)
+		clear(composite)
+		for _, p := range base {
+			start := max(p*p, (low+p-1)/p*p)
+			for j := start; j <= high; j += p {
+				composite[j-low] = true
+			}
+		}
+		for i := max(low, 2); i <= high; i++ {
+			if composite[i-low] {
+				continue
+			}
+			count++
+			largest = i
+			if params.List && len(primes) < maxListedPrimes {
+				primes = append(primes, i)
+			}
+		}
+		if (seg+1)%step == 0 || seg+1 == segments {
+			if err := checkpoint(ctx, seg+1, segments); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	structured := map[string]any{"count": count, "largest": largest}
+	text := fmt.Sprintf("%d primes up to %d (largest %d)", count, limit, largest)
+	if params.List {
+		if count > maxListedPrimes {
+			return nil, &Error{
+				Code:    ErrorInvalidParams,
+				Message: fmt.Sprintf("Too many primes to list: %d exceeds %d", count, maxListedPrimes),
+			}
+		}
+		parts := make([]string, len(primes))
+		for i, p := range primes {
+			parts[i] = strconv.Itoa(p)
+		}
+		structured["primes"] = primes
+		text = strings.Join(parts, ", ")
+	}
+	return newStructuredResult(text, structured), nil
+}
+
+// compileFunction は1変数の式を一度だけ解析し、値を評価する関数を返します
+func compileFunction(expression, variable string, env *evalEnv) (func(x float64) (float64, *exprError), *Error) {
+	if variable == "" {
+		variable = "x"
+	}
+	node, err := parseExpression(expression)
+	if err != nil {
+		return nil, err.toRPCError(expression)
+	}
+	return func(x float64) (float64, *exprError) {
+		env.vars[variable] = x
+		return node.eval(env)
+	}, nil
+}
+
+// evalAt は x における関数の値を評価し、エラーには評価した点を含めます
+func evalAt(f func(float64) (float64, *exprError), x float64, expression string) (float64, *Error) {
+	v, err := f(x)
+	if err != nil {
+		rpcErr := err.toRPCError(expression)
+		rpcErr.Data.(map[string]any)["x"] = x
+		return 0, rpcErr
+	}
+	return v, nil
+}
+
+// simpson は合成シンプソン則で定積分を計算します。
+// 同じ標本点から分割数が半分のときの値も求め、その差から誤差を見積もります
+func simpson(ctx context.Context, f func(float64) (float64, *exprError), params IntegrateParams) (*CallToolResult, *Error) {
+	n := params.Intervals
+	if n == 0 {
+		n = defaultIntervals
+	}
+	if n < 2 || n > maxIntervals {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("intervals must be between 2 and %d", maxIntervals),
+		}
+	}
+	n = (n + 3) / 4 * 4
+
+	h := (params.B - params.A) / float64(n)
+	step := progressInterval(n)
+	var total, coarse float64
+	for i := 0; i <= n; i++ {
+		v, err := evalAt(f, params.A+float64(i)*h, params.Expression)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case i == 0 || i == n:
+			total += v
+			coarse += v
+		case i%2 == 1:
+			total += 4 * v
+		case i%4 == 2:
+			total += 2 * v
+			coarse += 4 * v
+		default:
+			total += 2 * v
+			coarse += 2 * v
+		}
+		if i%step == 0 {
+			if err := checkpoint(ctx, i, n); err != nil {
+				return nil, err
+			}
+		}
+	}
+	value := total * h / 3
+	// シンプソン則の誤差は h⁴ に比例するので、刻みを倍にした値との差の 1/15 が誤差の目安になる
+	estimate := math.Abs(value-coarse*2*h/3) / 15
+	return integrationResult(ctx, "simpson", value, estimate, params.Tolerance, n, n+1)
+}
+
+// adaptiveSimpson は誤差の大きい部分区間だけを再帰的に分割する適応シンプソン則で定積分を計算します
+func adaptiveSimpson(ctx context.Context, f func(float64) (float64, *exprError), params IntegrateParams) (*CallToolResult, *Error) {
+	const maxDepth = 50
+	eval := func(x float64) (float64, *Error) { return evalAt(f, x, params.Expression) }
+	width := params.B - params.A
+	evaluations, intervals := 0, 0
+	var covered, estimate float64
+
+	// whole は [a, b] のシンプソン則の値、fa, fm, fb は両端と中点の値
+	var integrate func(a, b, fa, fm, fb, whole, tol float64, depth int) (float64, *Error)
+	integrate = func(a, b, fa, fm, fb, whole, tol float64, depth int) (float64, *Error) {
+		m := a + (b-a)/2
+		lm, rm := a+(m-a)/2, m+(b-m)/2
+		flm, err := eval(lm)
+		if err != nil {
+			return 0, err
+		}
+		frm, err := eval(rm)
+		if err != nil {
+			return 0, err
+		}
+		evaluations += 2
+		left := (m - a) / 6 * (fa + 4*flm + fm)
+		right := (b - m) / 6 * (fm + 4*frm + fb)
+		diff := left + right - whole
+		if math.Abs(diff) <= 15*tol || depth >= maxDepth || evaluations >= maxIntervals {
+			// 打ち切った区間も誤差の見積もりに含める
+			intervals += 2
+			estimate += math.Abs(diff) / 15
+			covered += b - a
+			if err := checkpoint(ctx, int(covered/width*1000), 1000); err != nil {
+				return 0, err
+			}
+			return left + right + diff/15, nil
+		}
+		l, err := integrate(a, m, fa, flm, fm, left, tol/2, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		r, err := integrate(m, b, fm, frm, fb, right, tol/2, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		return l + r, nil
+	}
+
+	a, b := params.A, params.B
+	fa, err := eval(a)
+	if err != nil {
+		return nil, err
+	}
+	fm, err := eval(a + width/2)
+	if err != nil {
+		return nil, err
+	}
+	fb, err := eval(b)
+	if err != nil {
+		return nil, err
+	}
+	evaluations = 3
+	value, err := integrate(a, b, fa, fm, fb, width/6*(fa+4*fm+fb), params.Tolerance, 0)
+	if err != nil {
+		return nil, err
+	}
+	return integrationResult(ctx, "adaptive", value, estimate, params.Tolerance, intervals, evaluations)
+}
+
+// integrationResult は integrate の結果を組み立てます
+func integrationResult(ctx context.Context, method string, value, estimate, tolerance float64, intervals, evaluations int) (*CallToolResult, *Error) {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return nil, &Error{Code: ErrorMathDomain, Message: "Result of integrate is out of range"}
+	}
+	text := fmt.Sprintf("%s (error estimate %s)", formatNumber(ctx, value), formatEstimate(estimate))
+	return newStructuredResult(text, map[string]any{
+		"value":         value,
+		"method":        method,
+		"intervals":     intervals,
+		"evaluations":   evaluations,
+		"converged":     estimate <= tolerance,
+		"errorEstimate": estimate,
+	}), nil
+}
+
+// monteCarlo は一様な乱数の標本で定積分を推定します
+func monteCarlo(ctx context.Context, f func(float64) (float64, *exprError), params IntegrateParams) (*CallToolResult, *Error) {
+	n := params.Samples
+	if n == 0 {
+		n = defaultSamples
+	}
+	if n < 2 || n > maxSamples {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("samples must be between 2 and %d", maxSamples),
+		}
+	}
+	seed := rand.Uint64()
+	if params.Seed != nil {
+		seed = *params.Seed
+	}
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	// 平均と分散は Welford 法で逐次的に求める
+	width := params.B - params.A
+	step := progressInterval(n)
+	var mean, m2 float64
+	for i := 1; i <= n; i++ {
+		v, err := evalAt(f, params.A+rng.Float64()*width, params.Expression)
+		if err != nil {
+			return nil, err
+		}
+		delta := v - mean
+		mean += delta / float64(i)
+		m2 += delta * (v - mean)
+		if i%step == 0 {
+			if err := checkpoint(ctx, i, n); err != nil {
+				return nil, err
+			}
+		}
+	}
+	value := mean * width
+	stderr := math.Abs(width) * math.Sqrt(m2/float64(n-1)/float64(n))
+	// 平均が範囲内でも分散の計算は桁あふれすることがある
+	if !finite(value) || !finite(stderr) {
+		return nil, outOfRange("monte_carlo")
+	}
+	return newStructuredResult(formatNumber(ctx, value)+" ± "+formatNumber(ctx, stderr), map[string]any{
+		"value":         value,
+		"standardError": stderr,
+		"samples":       n,
+	}), nil
+}
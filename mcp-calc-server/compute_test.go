@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestComputeTools(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"factorial", `{"n":0}`, "1"},
+		{"factorial", `{"n":25}`, "15511210043330985984000000"},
+		{"prime_sieve", `{"limit":100}`, "25 primes up to 100 (largest 97)"},
+		{"prime_sieve", `{"limit":30,"list":true}`, "2, 3, 5, 7, 11, 13, 17, 19, 23, 29"},
+		// 区間の境界をまたぐ場合
+		{"prime_sieve", `{"limit":1000000}`, "78498 primes up to 1000000 (largest 999983)"},
+		// 分割数は4の倍数に切り上げられる
+		{"integrate", `{"expression":"x^4","a":0,"b":3,"intervals":3}`, "48.726562 (error estimate 0.13)"},
+		{"integrate", `{"expression":"sin(t)","variable":"t","a":0,"b":"$1","intervals":101}`, "2.000000 (error estimate 9.3e-09)"},
+		{"integrate", `{"expression":"exp(x)","a":0,"b":1,"method":"adaptive"}`, "1.718282 (error estimate 3.6e-11)"},
+	}
+
+	callText(t, s, "evaluate", `{"expression":"pi"}`)
+	for _, tt := range tests {
+		if got := callText(t, s, tt.name, tt.args); got != tt.want {
+			t.Errorf("%s %s: expected %s, got %s", tt.name, tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestComputeToolErrors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		code int
+	}{
+		{"factorial", `{"n":-1}`, ErrorInvalidParams},
+		{"factorial", `{"n":20001}`, ErrorInvalidParams},
+		{"prime_sieve", `{"limit":1}`, ErrorInvalidParams},
+		{"prime_sieve", `{"limit":1000000,"list":true}`, ErrorInvalidParams},
+		{"integrate", `{"expression":"1/x","a":0,"b":1}`, ErrorDivideByZero},
+		{"integrate", `{"expression":"y","a":0,"b":1}`, ErrorInvalidParams},
+		{"monte_carlo", `{"expression":"x","a":0,"b":1,"samples":1}`, ErrorInvalidParams},
+		// 平均は範囲内でも標準誤差が桁あふれする
+		{"monte_carlo", `{"expression":"1e200*x","a":0,"b":1,"samples":100,"seed":1}`, ErrorMathDomain},
+	}
+
+	for _, tt := range tests {
+		_, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err == nil || err.Code != tt.code {
+			t.Errorf("%s %s: expected error code %d, got %v", tt.name, tt.args, tt.code, err)
+		}
+	}
+}
+
+func TestMonteCarloSeed(t *testing.T) {
+	s := newTestServer()
+	args := `{"expression":"4*sqrt(1-x^2)","a":0,"b":1,"samples":200000,"seed":42}`
+
+	first, err := s.callTool(context.Background(), "monte_carlo", json.RawMessage(args))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, _ := s.callTool(context.Background(), "monte_carlo", json.RawMessage(args))
+	// 同じシードなら同じ推定値になる
+	if first.Content[0].Text != second.Content[0].Text {
+		t.Errorf("expected reproducible estimate, got %s and %s", first.Content[0].Text, second.Content[0].Text)
+	}
+	data := first.StructuredContent.(map[string]any)
+	value, stderr := data["value"].(float64), data["standardError"].(float64)
+	if stderr <= 0 || stderr > 0.01 || value < 3.14159-4*stderr || value > 3.14159+4*stderr {
+		t.Errorf("unexpected estimate of pi: %v ± %v", value, stderr)
+	}
+}
+
+func TestProgressNotifications(t *testing.T) {
+	s := newReadyServer(t)
+	sent := captureNotifications(s)
+
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"factorial","arguments":{"n":1000},"_meta":{"progressToken":"fact-1"}}}`)
+	if !strings.Contains(out, `"text":"402387260077`) {
+		t.Fatalf("unexpected factorial response: %s", out)
+	}
+	if len(*sent) < 2 {
+		t.Fatalf("expected progress notifications, got %v", *sent)
+	}
+	last := -1.0
+	for _, n := range *sent {
+		p := n.Params.(ProgressParams)
+		if n.Method != "notifications/progress" || string(p.ProgressToken) != `"fact-1"` || p.Total != 1000 {
+			t.Fatalf("unexpected notification: %+v", n)
+		}
+		// progress は単調に増加する
+		if p.Progress <= last {
+			t.Errorf("progress did not increase: %v after %v", p.Progress, last)
+		}
+		last = p.Progress
+	}
+	if last != 1000 {
+		t.Errorf("expected final progress 1000, got %v", last)
+	}
+
+	// プログレストークンがなければ通知しない
+	*sent = nil
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"prime_sieve","arguments":{"limit":100000}}}`)
+	if len(*sent) != 0 {
+		t.Errorf("expected no notifications without a progress token, got %v", *sent)
+	}
+}
+
+func TestComputeCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := primeSieve(ctx, PrimeSieveParams{Limit: 1000000}); err == nil || err.Code != ErrorRequestCancelled {
+		t.Errorf("expected cancellation error, got %v", err)
+	}
+}
@@ -0,0 +1,903 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // タイムゾーンのデータをバイナリに埋め込み、OS のデータがなくても動くようにする
+)
+
+// holidaysCSV は日本の国民の祝日と休日の表です（日付,名称）。
+// 内閣府の「国民の祝日」の規定（振替休日と国民の休日を含む）に基づきます。
+// 春分の日と秋分の日は前年に官報で公表されるため、公表前の年は計算による予定日です
+//
+//go:embed holidays_jp.csv
+var holidaysCSV string
+
+// 日付ツールの制限
+const (
+	firstHolidayYear = 2000
+	lastHolidayYear  = 2030
+	maxDateSpanDays  = 100 * 366 // business_days で数える日数の上限
+	maxDateOffset    = 10000     // date_add で足せる年数の上限
+	dateLayout       = "2006-01-02"
+)
+
+// HolidayCalendar は営業日の計算で休みとする祝日の表を表します
+type HolidayCalendar string
+
+const (
+	HolidaysJapan HolidayCalendar = "jp"
+	HolidaysNone  HolidayCalendar = "none"
+)
+
+// japaneseHolidays は埋め込みの祝日表を日付から名称への対応に変換したものです
+var japaneseHolidays = sync.OnceValue(func() map[string]string {
+	holidays := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(holidaysCSV), "\n") {
+		if date, name, ok := strings.Cut(line, ","); ok {
+			holidays[date] = name
+		}
+	}
+	return holidays
+})
+
+// dateLayouts は日付と日時として受け付ける書式です。オフセットのない日時は timezone の時刻として扱います
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	dateLayout,
+}
+
+// DateAddParams は date_add ツールのパラメータを表します
+type DateAddParams struct {
+	Date     string  `json:"date"`
+	Duration string  `json:"duration,omitempty"` // P1Y2M3DT4H のような ISO 8601 の期間
+	Years    int     `json:"years,omitempty"`
+	Months   int     `json:"months,omitempty"`
+	Weeks    int     `json:"weeks,omitempty"`
+	Days     int     `json:"days,omitempty"`
+	Hours    int     `json:"hours,omitempty"`
+	Minutes  int     `json:"minutes,omitempty"`
+	Seconds  float64 `json:"seconds,omitempty"`
+	TimeZone string  `json:"timezone,omitempty"`
+}
+
+// DateDiffParams は date_diff ツールのパラメータを表します
+type DateDiffParams struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	TimeZone string `json:"timezone,omitempty"`
+}
+
+// BusinessDaysParams は business_days / add_business_days ツールのパラメータを表します
+type BusinessDaysParams struct {
+	Start         string          `json:"start"`
+	End           string          `json:"end,omitempty"`  // business_days の終了日
+	Days          int             `json:"days,omitempty"` // add_business_days で進める営業日数
+	Holidays      HolidayCalendar `json:"holidays,omitempty"`
+	ExtraHolidays []string        `json:"extraHolidays,omitempty"` // 会社の休業日など
+}
+
+// ISOWeekParams は iso_week ツールのパラメータを表します
+type ISOWeekParams struct {
+	Date string `json:"date"`
+}
+
+// ConvertTimeZoneParams は convert_timezone ツールのパラメータを表します
+type ConvertTimeZoneParams struct {
+	DateTime string `json:"datetime"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to"`
+}
+
+// JapaneseEraParams は japanese_era ツールのパラメータを表します。date と eraDate のどちらか一方を指定します
+type JapaneseEraParams struct {
+	Date    string `json:"date,omitempty"`    // 西暦の日付
+	EraDate string `json:"eraDate,omitempty"` // 令和7年10月16日 や R7.10.16 のような和暦の日付
+}
+
+// calendarDuration は年月日と時刻の部分に分けた期間です。年月日は暦の上で、時刻は経過時間として足します
+type calendarDuration struct {
+	years, months, days int
+	clock               time.Duration
+}
+
+// holidayEntry は範囲内の祝日です
+type holidayEntry struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// japaneseEra は元号とその開始日です
+type japaneseEra struct {
+	name   string
+	romaji string
+	start  time.Time
+}
+
+// japaneseEras はグレゴリオ暦を採用した明治6年以降の元号です
+var japaneseEras = []japaneseEra{
+	{"明治", "Meiji", time.Date(1868, 10, 23, 0, 0, 0, 0, time.UTC)},
+	{"大正", "Taisho", time.Date(1912, 7, 30, 0, 0, 0, 0, time.UTC)},
+	{"昭和", "Showa", time.Date(1926, 12, 25, 0, 0, 0, 0, time.UTC)},
+	{"平成", "Heisei", time.Date(1989, 1, 8, 0, 0, 0, 0, time.UTC)},
+	{"令和", "Reiwa", time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)},
+}
+
+// gregorianAdoption は日本でグレゴリオ暦が使われ始めた日です。これより前は旧暦のため変換しません
+var gregorianAdoption = time.Date(1873, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	isoDurationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+	eraDatePattern     = regexp.MustCompile(`(?i)^(明治|大正|昭和|平成|令和|meiji|taisho|showa|heisei|reiwa|[mtshr])\s*(元|\d+)\s*(?:年|[./-]|\s)\s*(\d+)\s*(?:月|[./-])\s*(\d+)\s*日?$`)
+)
+
+// getDateTools は日付と時刻のツールの一覧を返します
+func getDateTools() []toolDef {
+	timezone := map[string]any{
+		"type":        "string",
+		"description": "IANA time zone such as Asia/Tokyo, or an offset such as +09:00 (defaults to UTC). Used for dates without an offset",
+	}
+	holidays := map[string]any{
+		"type":        "string",
+		"enum":        []HolidayCalendar{HolidaysJapan, HolidaysNone},
+		"description": fmt.Sprintf("Public holidays to skip: jp uses the embedded Japanese holiday table for %d-%d (defaults to jp)", firstHolidayYear, lastHolidayYear),
+	}
+	extraHolidays := map[string]any{
+		"type":        "array",
+		"items":       map[string]any{"type": "string", "pattern": `^\d{4}-\d{2}-\d{2}$`},
+		"description": "Additional non-working dates (YYYY-MM-DD), e.g. company holidays",
+	}
+	dateOutput := func(extra map[string]any, required ...string) map[string]any {
+		props := map[string]any{
+			"result":  map[string]any{"type": "string", "description": "YYYY-MM-DD for dates, RFC 3339 for date-times"},
+			"weekday": map[string]any{"type": "string"},
+		}
+		for k, v := range extra {
+			props[k] = v
+		}
+		return outputSchema(props, append([]string{"result", "weekday"}, required...)...)
+	}
+	holidayList := map[string]any{
+		"type": "array",
+		"items": outputSchema(map[string]any{
+			"date": map[string]any{"type": "string"},
+			"name": map[string]any{"type": "string"},
+		}, "date", "name"),
+	}
+
+	return bindTools([]Tool{
+		{
+			Name:        "date_add",
+			Description: "Add or subtract a duration to a date or date-time. Years and months keep the day of month, clamped to the end of shorter months",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"date":     map[string]any{"type": "string", "description": "Date (YYYY-MM-DD) or date-time (RFC 3339, or YYYY-MM-DDTHH:MM in the time zone)"},
+					"duration": map[string]any{"type": "string", "description": "ISO 8601 duration such as P1Y2M10DT2H30M or -P3W. Added together with the individual fields"},
+					"years":    map[string]any{"type": "integer", "minimum": -maxDateOffset, "maximum": maxDateOffset},
+					"months":   map[string]any{"type": "integer"},
+					"weeks":    map[string]any{"type": "integer"},
+					"days":     map[string]any{"type": "integer"},
+					"hours":    map[string]any{"type": "integer"},
+					"minutes":  map[string]any{"type": "integer"},
+					"seconds":  map[string]any{"type": "number"},
+					"timezone": timezone,
+				},
+				"required": []string{"date"},
+			},
+			OutputSchema: dateOutput(nil),
+		},
+		{
+			Name:        "date_diff",
+			Description: "Difference between two dates or date-times in years, months and days, and in total days",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"from":     map[string]any{"type": "string", "description": "Start date or date-time"},
+					"to":       map[string]any{"type": "string", "description": "End date or date-time"},
+					"timezone": timezone,
+				},
+				"required": []string{"from", "to"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"years":        map[string]any{"type": "integer"},
+				"months":       map[string]any{"type": "integer"},
+				"days":         map[string]any{"type": "integer"},
+				"time":         map[string]any{"type": "string", "description": "Remaining time of day as a duration such as 4h30m0s"},
+				"totalDays":    map[string]any{"type": "number"},
+				"totalSeconds": map[string]any{"type": "number"},
+			}, "years", "months", "days", "totalDays", "totalSeconds"),
+		},
+		{
+			Name:        "business_days",
+			Description: "Count business days from start to end inclusive, skipping weekends and public holidays. The count is negative when end is before start",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"start":         map[string]any{"type": "string", "description": "Start date (YYYY-MM-DD)"},
+					"end":           map[string]any{"type": "string", "description": "End date (YYYY-MM-DD)"},
+					"holidays":      holidays,
+					"extraHolidays": extraHolidays,
+				},
+				"required": []string{"start", "end"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"businessDays": map[string]any{"type": "integer"},
+				"calendarDays": map[string]any{"type": "integer"},
+				"weekendDays":  map[string]any{"type": "integer"},
+				"holidays":     holidayList,
+			}, "businessDays", "calendarDays", "weekendDays", "holidays"),
+		},
+		{
+			Name:        "add_business_days",
+			Description: "Move a date forward or backward by a number of business days, skipping weekends and public holidays",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"start":         map[string]any{"type": "string", "description": "Start date (YYYY-MM-DD)"},
+					"days":          map[string]any{"type": "integer", "minimum": -maxDateSpanDays, "maximum": maxDateSpanDays, "description": "Business days to add (negative to go back)"},
+					"holidays":      holidays,
+					"extraHolidays": extraHolidays,
+				},
+				"required": []string{"start", "days"},
+			},
+			OutputSchema: dateOutput(map[string]any{"skippedHolidays": holidayList}, "skippedHolidays"),
+		},
+		{
+			Name:        "iso_week",
+			Description: "ISO 8601 week-numbering year, week number and weekday of a date",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"date": map[string]any{"type": "string", "description": "Date (YYYY-MM-DD)"},
+				},
+				"required": []string{"date"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"year":      map[string]any{"type": "integer"},
+				"week":      map[string]any{"type": "integer"},
+				"weekday":   map[string]any{"type": "integer", "description": "1 = Monday … 7 = Sunday"},
+				"dayOfYear": map[string]any{"type": "integer"},
+				"weekDate":  map[string]any{"type": "string", "description": "ISO week date such as 2026-W42-5"},
+			}, "year", "week", "weekday", "dayOfYear", "weekDate"),
+		},
+		{
+			Name:        "convert_timezone",
+			Description: "Convert a date-time between time zones using the embedded time zone database",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"datetime": map[string]any{"type": "string", "description": "Date-time in RFC 3339, or without an offset in the from time zone"},
+					"from":     map[string]any{"type": "string", "description": "Source time zone for date-times without an offset (defaults to UTC)"},
+					"to":       map[string]any{"type": "string", "description": "Target IANA time zone or offset"},
+				},
+				"required": []string{"datetime", "to"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"result":       map[string]any{"type": "string"},
+				"timezone":     map[string]any{"type": "string"},
+				"abbreviation": map[string]any{"type": "string"},
+				"offset":       map[string]any{"type": "string"},
+				"dst":          map[string]any{"type": "boolean"},
+			}, "result", "timezone", "abbreviation", "offset", "dst"),
+		},
+		{
+			Name:        "japanese_era",
+			Description: "Convert between Gregorian dates and the Japanese era calendar (明治, 大正, 昭和, 平成, 令和) from 1873",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"date":    map[string]any{"type": "string", "description": "Gregorian date (YYYY-MM-DD) to convert to the era calendar"},
+					"eraDate": map[string]any{"type": "string", "description": "Era date such as 令和7年10月16日, 令和元年5月1日, R7.10.16 or Reiwa 7-10-16 to convert to the Gregorian calendar"},
+				},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"date":    map[string]any{"type": "string"},
+				"era":     map[string]any{"type": "string"},
+				"romaji":  map[string]any{"type": "string"},
+				"year":    map[string]any{"type": "integer", "description": "Year of the era (1 for 元年)"},
+				"eraDate": map[string]any{"type": "string"},
+			}, "date", "era", "romaji", "year", "eraDate"),
+		},
+	}, (*Server).handleDate)
+}
+
+// handleDate は日付ツールの呼び出しを処理します
+func (s *Server) handleDate(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	switch name {
+	case "date_add":
+		var params DateAddParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return dateAdd(params)
+	case "date_diff":
+		var params DateDiffParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return dateDiff(params)
+	case "business_days", "add_business_days":
+		var params BusinessDaysParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		calendar, err := newHolidayCalendar(params.Holidays, params.ExtraHolidays)
+		if err != nil {
+			return nil, err
+		}
+		if name == "business_days" {
+			return countBusinessDays(calendar, params.Start, params.End)
+		}
+		return addBusinessDays(calendar, params.Start, params.Days)
+	case "iso_week":
+		var params ISOWeekParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return isoWeek(params.Date)
+	case "convert_timezone":
+		var params ConvertTimeZoneParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return convertTimeZone(params)
+	case "japanese_era":
+		var params JapaneseEraParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return convertJapaneseEra(params)
+	default:
+		return nil, unknownTool(name)
+	}
+}
+
+// loadLocation はタイムゾーン名か +09:00 のようなオフセットから場所を返します。空の場合は UTC です
+func loadLocation(name string) (*time.Location, *Error) {
+	switch name {
+	case "", "UTC", "Z":
+		return time.UTC, nil
+	case "Local":
+		// サーバーの設定に依存する結果は返さない
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Time zone 'Local' is not supported; use an IANA name such as Asia/Tokyo"}
+	}
+	if t, err := time.Parse("Z07:00", name); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(name, offset), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown time zone '%s'", name)}
+	}
+	return loc, nil
+}
+
+// parseDate は日付か日時を解析します。オフセットのない値は loc の時刻として扱い、日付だけの場合は dateOnly を返します
+func parseDate(s string, loc *time.Location) (t time.Time, dateOnly bool, rpcErr *Error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, layout == dateLayout, nil
+		}
+	}
+	return time.Time{}, false, &Error{
+		Code:    ErrorInvalidParams,
+		Message: fmt.Sprintf("Invalid date '%s'", s),
+		Data:    "expected YYYY-MM-DD or an RFC 3339 date-time",
+	}
+}
+
+// parseDay は YYYY-MM-DD の日付を UTC の0時として解析します
+func parseDay(s string) (time.Time, *Error) {
+	t, err := time.Parse(dateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("Invalid date '%s'", s),
+			Data:    "expected YYYY-MM-DD",
+		}
+	}
+	return t, nil
+}
+
+// formatDate は日付だけの値を YYYY-MM-DD、日時を RFC 3339 で表します
+func formatDate(t time.Time, dateOnly bool) string {
+	if dateOnly {
+		return t.Format(dateLayout)
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// dateResult は日付を返すツールの結果を組み立てます
+func dateResult(t time.Time, dateOnly bool, extra map[string]any) *CallToolResult {
+	result := formatDate(t, dateOnly)
+	data := map[string]any{"result": result, "weekday": t.Weekday().String()}
+	for k, v := range extra {
+		data[k] = v
+	}
+	return newStructuredResult(fmt.Sprintf("%s (%s)", result, t.Weekday()), data)
+}
+
+// parseISODuration は P1Y2M3DT4H5M6.5S のような ISO 8601 の期間を解析します
+func parseISODuration(s string) (calendarDuration, *Error) {
+	invalid := &Error{
+		Code:    ErrorInvalidParams,
+		Message: fmt.Sprintf("Invalid duration '%s'", s),
+		Data:    "expected an ISO 8601 duration such as P1Y2M10DT2H30M",
+	}
+	m := isoDurationPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil || strings.HasSuffix(m[0], "P") || strings.HasSuffix(m[0], "T") {
+		return calendarDuration{}, invalid
+	}
+	field := func(i int) int {
+		n, _ := strconv.Atoi(m[i])
+		return n
+	}
+	d := calendarDuration{
+		years:  field(2),
+		months: field(3),
+		days:   7*field(4) + field(5),
+		clock:  time.Duration(field(6))*time.Hour + time.Duration(field(7))*time.Minute,
+	}
+	if m[8] != "" {
+		sec, err := strconv.ParseFloat(strings.ReplaceAll(m[8], ",", "."), 64)
+		if err != nil {
+			return calendarDuration{}, invalid
+		}
+		d.clock += time.Duration(sec * float64(time.Second))
+	}
+	if m[1] == "-" {
+		d = calendarDuration{years: -d.years, months: -d.months, days: -d.days, clock: -d.clock}
+	}
+	return d, nil
+}
+
+// addCalendar は年月日を暦の上で足します。月末を超える日は移動先の月末にそろえます（1月31日の1か月後は2月28日か29日）
+func addCalendar(t time.Time, years, months, days int) time.Time {
+	y, m, d := t.Date()
+	total := int(m) - 1 + months + 12*years
+	y += total / 12
+	if total %= 12; total < 0 {
+		total += 12
+		y--
+	}
+	month := time.Month(total + 1)
+	if last := daysIn(y, month); d > last {
+		d = last
+	}
+	hh, mm, ss := t.Clock()
+	return time.Date(y, month, d+days, hh, mm, ss, t.Nanosecond(), t.Location())
+}
+
+// daysIn は月の日数を返します
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// dateAdd は日付に期間を足します
+func dateAdd(params DateAddParams) (*CallToolResult, *Error) {
+	loc, err := loadLocation(params.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	t, dateOnly, err := parseDate(params.Date, loc)
+	if err != nil {
+		return nil, err
+	}
+	if params.TimeZone != "" {
+		t = t.In(loc)
+	}
+
+	// 時刻の部分は time.Duration のあふれを避けるため先に大きさを確かめる
+	clockDays := math.Abs(float64(params.Hours))/24 + math.Abs(float64(params.Minutes))/1440 + math.Abs(params.Seconds)/86400
+	if clockDays > maxDateOffset*366 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Durations must not exceed %d years", maxDateOffset)}
+	}
+	d := calendarDuration{
+		years:  params.Years,
+		months: params.Months,
+		days:   7*params.Weeks + params.Days,
+		clock: time.Duration(params.Hours)*time.Hour + time.Duration(params.Minutes)*time.Minute +
+			time.Duration(params.Seconds*float64(time.Second)),
+	}
+	if params.Duration != "" {
+		iso, err := parseISODuration(params.Duration)
+		if err != nil {
+			return nil, err
+		}
+		d.years += iso.years
+		d.months += iso.months
+		d.days += iso.days
+		d.clock += iso.clock
+	}
+	if absInt(d.years)+absInt(d.months)/12+absInt(d.days)/366 > maxDateOffset {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Durations must not exceed %d years", maxDateOffset)}
+	}
+
+	result := addCalendar(t, d.years, d.months, d.days).Add(d.clock)
+	if y := result.Year(); y < 1 || y > 9999 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Result must be between years 1 and 9999"}
+	}
+	// 日付に時刻の期間を足した場合は日時で返す
+	return dateResult(result, dateOnly && d.clock == 0, nil), nil
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// dateDiff は2つの日付の差を年月日と合計の日数で求めます
+func dateDiff(params DateDiffParams) (*CallToolResult, *Error) {
+	loc, err := loadLocation(params.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	from, _, err := parseDate(params.From, loc)
+	if err != nil {
+		return nil, err
+	}
+	to, _, err := parseDate(params.To, loc)
+	if err != nil {
+		return nil, err
+	}
+	// 年月日は from の時刻の暦で数える
+	to = to.In(from.Location())
+
+	sign := 1
+	start, end := from, to
+	if end.Before(start) {
+		sign, start, end = -1, to, from
+	}
+	months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month())
+	for months > 0 && addCalendar(start, 0, months, 0).After(end) {
+		months--
+	}
+	anchor := addCalendar(start, 0, months, 0)
+	days := 0
+	for !addCalendar(anchor, 0, 0, days+1).After(end) {
+		days++
+	}
+	rest := end.Sub(addCalendar(anchor, 0, 0, days))
+
+	elapsed := to.Sub(from)
+	data := map[string]any{
+		"years":        sign * (months / 12),
+		"months":       sign * (months % 12),
+		"days":         sign * days,
+		"totalDays":    elapsed.Hours() / 24,
+		"totalSeconds": elapsed.Seconds(),
+	}
+	parts := []string{
+		plural(months/12, "year"),
+		plural(months%12, "month"),
+		plural(days, "day"),
+	}
+	if rest != 0 {
+		data["time"] = (time.Duration(sign) * rest).String()
+		parts = append(parts, rest.String())
+	}
+	text := strings.Join(parts, ", ")
+	if sign < 0 {
+		text = "-(" + text + ")"
+	}
+	text += fmt.Sprintf(" (%s days)", strconv.FormatFloat(elapsed.Hours()/24, 'f', -1, 64))
+	return newStructuredResult(text, data), nil
+}
+
+// plural は数と単位を英語の単数形か複数形で表します
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// holidayCalendar は休日の判定に使う祝日の表です
+type holidayCalendar struct {
+	japan bool
+	extra map[string]bool
+}
+
+// newHolidayCalendar は営業日の計算に使う祝日の表を用意します
+func newHolidayCalendar(holidays HolidayCalendar, extra []string) (*holidayCalendar, *Error) {
+	c := &holidayCalendar{extra: make(map[string]bool)}
+	switch holidays {
+	case "", HolidaysJapan:
+		c.japan = true
+	case HolidaysNone:
+	default:
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown holiday calendar '%s'", holidays)}
+	}
+	for _, s := range extra {
+		t, err := parseDay(s)
+		if err != nil {
+			return nil, err
+		}
+		c.extra[t.Format(dateLayout)] = true
+	}
+	return c, nil
+}
+
+// holiday は日付が休日であればその名称を返します
+func (c *holidayCalendar) holiday(t time.Time) (string, bool, *Error) {
+	key := t.Format(dateLayout)
+	if c.extra[key] {
+		return "extra holiday", true, nil
+	}
+	if !c.japan {
+		return "", false, nil
+	}
+	if y := t.Year(); y < firstHolidayYear || y > lastHolidayYear {
+		return "", false, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("Japanese holidays are only available from %d to %d", firstHolidayYear, lastHolidayYear),
+			Data:    "set holidays to none to skip weekends only",
+		}
+	}
+	name, ok := japaneseHolidays()[key]
+	return name, ok, nil
+}
+
+// isWeekend は土曜日か日曜日かを返します
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// countBusinessDays は start から end まで（両端を含む）の営業日を数えます
+func countBusinessDays(c *holidayCalendar, startDate, endDate string) (*CallToolResult, *Error) {
+	start, err := parseDay(startDate)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseDay(endDate)
+	if err != nil {
+		return nil, err
+	}
+	sign := 1
+	if end.Before(start) {
+		sign, start, end = -1, end, start
+	}
+	calendarDays := int(end.Sub(start).Hours()/24) + 1
+	if calendarDays > maxDateSpanDays {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Date ranges must not exceed %d days", maxDateSpanDays)}
+	}
+
+	business, weekend := 0, 0
+	holidays := []holidayEntry{}
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if isWeekend(d) {
+			weekend++
+			continue
+		}
+		name, ok, err := c.holiday(d)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			holidays = append(holidays, holidayEntry{Date: d.Format(dateLayout), Name: name})
+			continue
+		}
+		business++
+	}
+	return newStructuredResult(plural(sign*business, "business day"), map[string]any{
+		"businessDays": sign * business,
+		"calendarDays": sign * calendarDays,
+		"weekendDays":  weekend,
+		"holidays":     holidays,
+	}), nil
+}
+
+// addBusinessDays は営業日を days 日進めた日付を求めます。days が 0 で start が休日の場合は次の営業日です
+func addBusinessDays(c *holidayCalendar, startDate string, days int) (*CallToolResult, *Error) {
+	d, err := parseDay(startDate)
+	if err != nil {
+		return nil, err
+	}
+	step, remaining := 1, days
+	if days < 0 {
+		step, remaining = -1, -days
+	}
+	skipped := []holidayEntry{}
+	isBusinessDay := func(t time.Time) (bool, *Error) {
+		if isWeekend(t) {
+			return false, nil
+		}
+		name, ok, err := c.holiday(t)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			skipped = append(skipped, holidayEntry{Date: t.Format(dateLayout), Name: name})
+		}
+		return !ok, nil
+	}
+	for remaining > 0 {
+		d = d.AddDate(0, 0, step)
+		ok, err := isBusinessDay(d)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			remaining--
+		}
+	}
+	// 0 日の場合は start 以降の最初の営業日
+	for days == 0 {
+		ok, err := isBusinessDay(d)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		d = d.AddDate(0, 0, 1)
+	}
+	return dateResult(d, true, map[string]any{"skippedHolidays": skipped}), nil
+}
+
+// isoWeek は ISO 8601 の週番号を求めます
+func isoWeek(date string) (*CallToolResult, *Error) {
+	t, err := parseDay(date)
+	if err != nil {
+		return nil, err
+	}
+	year, week := t.ISOWeek()
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	weekDate := fmt.Sprintf("%04d-W%02d-%d", year, week, weekday)
+	return newStructuredResult(weekDate, map[string]any{
+		"year":      year,
+		"week":      week,
+		"weekday":   weekday,
+		"dayOfYear": t.YearDay(),
+		"weekDate":  weekDate,
+	}), nil
+}
+
+// convertTimeZone は日時を別のタイムゾーンの時刻に変換します
+func convertTimeZone(params ConvertTimeZoneParams) (*CallToolResult, *Error) {
+	from, err := loadLocation(params.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := loadLocation(params.To)
+	if err != nil {
+		return nil, err
+	}
+	t, _, err := parseDate(params.DateTime, from)
+	if err != nil {
+		return nil, err
+	}
+	t = t.In(to)
+	abbreviation, _ := t.Zone()
+	result := t.Format(time.RFC3339Nano)
+	return newStructuredResult(fmt.Sprintf("%s (%s)", result, abbreviation), map[string]any{
+		"result":       result,
+		"timezone":     to.String(),
+		"abbreviation": abbreviation,
+		"offset":       t.Format("-07:00"),
+		"dst":          t.IsDST(),
+	}), nil
+}
+
+// convertJapaneseEra は西暦と和暦を相互に変換します
+func convertJapaneseEra(params JapaneseEraParams) (*CallToolResult, *Error) {
+	var t time.Time
+	switch {
+	case params.Date != "" && params.EraDate != "":
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Specify either date or eraDate, not both"}
+	case params.Date != "":
+		d, err := parseDay(params.Date)
+		if err != nil {
+			return nil, err
+		}
+		t = d
+	case params.EraDate != "":
+		d, err := parseEraDate(params.EraDate)
+		if err != nil {
+			return nil, err
+		}
+		t = d
+	default:
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Specify date or eraDate"}
+	}
+
+	era, ok := eraOf(t)
+	if !ok {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("Dates before %s cannot be converted", gregorianAdoption.Format(dateLayout)),
+			Data:    "Japan adopted the Gregorian calendar on 1873-01-01 (明治6年)",
+		}
+	}
+	year := t.Year() - era.start.Year() + 1
+	eraYear := strconv.Itoa(year) + "年"
+	if year == 1 {
+		eraYear = "元年"
+	}
+	eraDate := fmt.Sprintf("%s%s%d月%d日", era.name, eraYear, t.Month(), t.Day())
+	date := t.Format(dateLayout)
+	text := eraDate
+	if params.EraDate != "" {
+		text = date
+	}
+	return newStructuredResult(text, map[string]any{
+		"date":    date,
+		"era":     era.name,
+		"romaji":  era.romaji,
+		"year":    year,
+		"eraDate": eraDate,
+	}), nil
+}
+
+// eraOf は日付の属する元号を返します
+func eraOf(t time.Time) (japaneseEra, bool) {
+	if t.Before(gregorianAdoption) {
+		return japaneseEra{}, false
+	}
+	for i := len(japaneseEras) - 1; i >= 0; i-- {
+		if !t.Before(japaneseEras[i].start) {
+			return japaneseEras[i], true
+		}
+	}
+	return japaneseEra{}, false
+}
+
+// parseEraDate は和暦の日付を解析します。全角数字と R7.10.16 のような略記も受け付けます
+func parseEraDate(s string) (time.Time, *Error) {
+	invalid := func(reason string) *Error {
+		return &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Invalid era date '%s'", s), Data: reason}
+	}
+	normalized := strings.Map(func(r rune) rune {
+		if r >= '０' && r <= '９' {
+			return '0' + (r - '０')
+		}
+		return r
+	}, strings.TrimSpace(s))
+	m := eraDatePattern.FindStringSubmatch(normalized)
+	if m == nil {
+		return time.Time{}, invalid("expected a date such as 令和7年10月16日 or R7.10.16")
+	}
+
+	index := -1
+	for i, era := range japaneseEras {
+		if m[1] == era.name || strings.EqualFold(m[1], era.romaji) || strings.EqualFold(m[1], era.romaji[:1]) {
+			index = i
+			break
+		}
+	}
+	year := 1
+	if m[2] != "元" {
+		year, _ = strconv.Atoi(m[2])
+	}
+	month, _ := strconv.Atoi(m[3])
+	day, _ := strconv.Atoi(m[4])
+	era := japaneseEras[index]
+	t := time.Date(era.start.Year()+year-1, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if year < 1 || t.Month() != time.Month(month) || t.Day() != day {
+		return time.Time{}, invalid("no such date")
+	}
+	// 元号の期間外（平成31年5月1日など）は受け付けない
+	if actual, ok := eraOf(t); !ok || actual.name != era.name {
+		return time.Time{}, invalid(fmt.Sprintf("the date is outside the %s era", era.romaji))
+	}
+	return t, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestDateTools(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"date_add", `{"date":"2025-01-31","months":1}`, "2025-02-28 (Friday)"},
+		{"date_add", `{"date":"2024-02-29","years":1}`, "2025-02-28 (Friday)"},
+		{"date_add", `{"date":"2025-03-01","days":-1}`, "2025-02-28 (Friday)"},
+		{"date_add", `{"date":"2025-10-16","duration":"P1Y2M3W"}`, "2027-01-06 (Wednesday)"},
+		{"date_add", `{"date":"2025-10-16","duration":"-P10D","weeks":1}`, "2025-10-13 (Monday)"},
+		{"date_add", `{"date":"2025-10-16","hours":36}`, "2025-10-17T12:00:00Z (Friday)"},
+		{"date_add", `{"date":"2025-12-31T23:30:00+09:00","duration":"PT45M"}`, "2026-01-01T00:15:00+09:00 (Thursday)"},
+		// 夏時間の切り替えをまたいでも日の加算は同じ時刻になる
+		{"date_add", `{"date":"2025-03-08T12:00","days":1,"timezone":"America/New_York"}`, "2025-03-09T12:00:00-04:00 (Sunday)"},
+		{"date_add", `{"date":"2025-03-08T12:00","hours":24,"timezone":"America/New_York"}`, "2025-03-09T13:00:00-04:00 (Sunday)"},
+		{"date_diff", `{"from":"2024-01-15","to":"2025-03-20"}`, "1 year, 2 months, 5 days (430 days)"},
+		{"date_diff", `{"from":"2025-03-20","to":"2024-01-15"}`, "-(1 year, 2 months, 5 days) (-430 days)"},
+		{"date_diff", `{"from":"2025-01-01T10:00:00+09:00","to":"2025-01-02T09:00:00+09:00"}`, "0 years, 0 months, 0 days, 23h0m0s (0.9583333333333334 days)"},
+		// 2025年5月の営業日（3日〜6日は祝日と振替休日）
+		{"business_days", `{"start":"2025-05-01","end":"2025-05-31"}`, "20 business days"},
+		{"business_days", `{"start":"2025-05-01","end":"2025-05-31","holidays":"none"}`, "22 business days"},
+		{"business_days", `{"start":"2025-05-31","end":"2025-05-01"}`, "-20 business days"},
+		{"business_days", `{"start":"2025-05-07","end":"2025-05-09","extraHolidays":["2025-05-08"]}`, "2 business days"},
+		// 2026年9月は敬老の日と秋分の日にはさまれた国民の休日がある
+		{"business_days", `{"start":"2026-09-19","end":"2026-09-24"}`, "1 business day"},
+		{"add_business_days", `{"start":"2025-05-02","days":1}`, "2025-05-07 (Wednesday)"},
+		{"add_business_days", `{"start":"2025-05-07","days":-1}`, "2025-05-02 (Friday)"},
+		{"add_business_days", `{"start":"2025-05-03","days":0}`, "2025-05-07 (Wednesday)"},
+		{"add_business_days", `{"start":"2025-12-26","days":3,"holidays":"none"}`, "2025-12-31 (Wednesday)"},
+		{"iso_week", `{"date":"2026-10-16"}`, "2026-W42-5"},
+		// 年末の日付が翌年の第1週になる場合
+		{"iso_week", `{"date":"2024-12-30"}`, "2025-W01-1"},
+		{"iso_week", `{"date":"2021-01-03"}`, "2020-W53-7"},
+		{"convert_timezone", `{"datetime":"2025-01-01T00:00:00Z","to":"Asia/Tokyo"}`, "2025-01-01T09:00:00+09:00 (JST)"},
+		{"convert_timezone", `{"datetime":"2025-07-01 09:00","from":"Asia/Tokyo","to":"Europe/London"}`, "2025-07-01T01:00:00+01:00 (BST)"},
+		{"convert_timezone", `{"datetime":"2025-07-01T09:00:00+09:00","to":"-05:30"}`, "2025-06-30T18:30:00-05:30 (-05:30)"},
+		{"japanese_era", `{"date":"2026-10-16"}`, "令和8年10月16日"},
+		{"japanese_era", `{"date":"2019-05-01"}`, "令和元年5月1日"},
+		{"japanese_era", `{"date":"2019-04-30"}`, "平成31年4月30日"},
+		{"japanese_era", `{"date":"1989-01-07"}`, "昭和64年1月7日"},
+		{"japanese_era", `{"eraDate":"令和7年10月16日"}`, "2025-10-16"},
+		{"japanese_era", `{"eraDate":"平成元年1月8日"}`, "1989-01-08"},
+		{"japanese_era", `{"eraDate":"R7.10.16"}`, "2025-10-16"},
+		{"japanese_era", `{"eraDate":"Showa 50-4-1"}`, "1975-04-01"},
+		{"japanese_era", `{"eraDate":"令和７年１月１日"}`, "2025-01-01"},
+	}
+
+	for _, tt := range tests {
+		if got := callText(t, s, tt.name, tt.args); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.name, tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestBusinessDaysHolidays(t *testing.T) {
+	s := newTestServer()
+	result, err := s.callTool(context.Background(), "business_days", json.RawMessage(`{"start":"2025-04-28","end":"2025-05-07"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := result.StructuredContent.(map[string]any)
+	holidays := data["holidays"].([]holidayEntry)
+	// 5月3日（土）と4日（日）は週末として数える
+	want := []holidayEntry{{"2025-04-29", "昭和の日"}, {"2025-05-05", "こどもの日"}, {"2025-05-06", "振替休日"}}
+	if len(holidays) != len(want) {
+		t.Fatalf("expected %v, got %v", want, holidays)
+	}
+	for i := range want {
+		if holidays[i] != want[i] {
+			t.Errorf("expected %v, got %v", want[i], holidays[i])
+		}
+	}
+	if data["businessDays"] != 5 || data["weekendDays"] != 2 || data["calendarDays"] != 10 {
+		t.Errorf("unexpected counts: %v", data)
+	}
+}
+
+func TestDateToolErrors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+	}{
+		{"date_add", `{"date":"2025-02-30","days":1}`},
+		{"date_add", `{"date":"2025-01-01","duration":"P"}`},
+		{"date_add", `{"date":"2025-01-01","duration":"1 day"}`},
+		{"date_add", `{"date":"2025-01-01","timezone":"Mars/Olympus"}`},
+		{"date_add", `{"date":"2025-01-01","timezone":"Local"}`},
+		{"date_add", `{"date":"9999-12-31","days":1}`},
+		{"date_add", `{"date":"2025-01-01","hours":9000000000}`},
+		{"business_days", `{"start":"2035-01-01","end":"2035-01-31"}`},
+		{"business_days", `{"start":"2025-01-01","end":"2025-01-31","extraHolidays":["2025-13-01"]}`},
+		{"iso_week", `{"date":"2025/01/01"}`},
+		{"japanese_era", `{}`},
+		{"japanese_era", `{"date":"1872-12-31"}`},
+		{"japanese_era", `{"eraDate":"平成32年1月1日"}`},
+		{"japanese_era", `{"eraDate":"令和7年2月30日"}`},
+		{"japanese_era", `{"date":"2025-01-01","eraDate":"令和7年1月1日"}`},
+	}
+
+	for _, tt := range tests {
+		_, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err == nil || err.Code != ErrorInvalidParams {
+			t.Errorf("%s %s: expected invalid params, got %v", tt.name, tt.args, err)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// exprError は式の解析・評価時のエラーを位置情報付きで表します
+type exprError struct {
+	Code int
+	Pos  int
+	Msg  string
+}
+
+func (e *exprError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// toRPCError は exprError を JSON-RPC のエラーに変換します
+func (e *exprError) toRPCError(expr string) *Error {
+	return &Error{
+		Code:    e.Code,
+		Message: e.Error(),
+		Data: map[string]any{
+			"position":   e.Pos,
+			"expression": expr,
+		},
+	}
+}
+
+func parseErrorAt(pos int, format string, args ...any) *exprError {
+	return &exprError{Code: ErrorInvalidParams, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// tokenKind はトークンの種類を表します
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+// token は字句解析の結果を表します。pos はルーン単位の0始まりのオフセットです
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// tokenize は式をトークン列に分割します
+func tokenize(src string) ([]token, *exprError) {
+	rs := []rune(src)
+	var toks []token
+	i := 0
+	for i < len(rs) {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			start := i
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			// 指数表記 (1e-3 など)
+			if i < len(rs) && (rs[i] == 'e' || rs[i] == 'E') {
+				j := i + 1
+				if j < len(rs) && (rs[j] == '+' || rs[j] == '-') {
+					j++
+				}
+				if j < len(rs) && unicode.IsDigit(rs[j]) {
+					i = j
+					for i < len(rs) && unicode.IsDigit(rs[i]) {
+						i++
+					}
+				}
+			}
+			text := string(rs[start:i])
+			v, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, parseErrorAt(start, "invalid number %q", text)
+			}
+			toks = append(toks, token{kind: tokNumber, text: text, num: v, pos: start})
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			// $1 や $x は履歴・変数の参照
+			start := i
+			i++
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(rs[start:i]), pos: start})
+		case strings.ContainsRune("+-*/%^", r):
+			toks = append(toks, token{kind: tokOp, text: string(r), pos: i})
+			i++
+		case r == '(':
+			toks = append(toks, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == ',':
+			toks = append(toks, token{kind: tokComma, text: ",", pos: i})
+			i++
+		case superscriptDigits[r] != 0:
+			// 上付き文字の指数 (m² など) は ^2 として扱う
+			start := i
+			var digits []rune
+			for i < len(rs) && superscriptDigits[rs[i]] != 0 {
+				digits = append(digits, superscriptDigits[rs[i]])
+				i++
+			}
+			v, err := strconv.ParseFloat(string(digits), 64)
+			if err != nil {
+				return nil, parseErrorAt(start, "invalid exponent %q", string(rs[start:i]))
+			}
+			toks = append(toks,
+				token{kind: tokOp, text: "^", pos: start},
+				token{kind: tokNumber, text: string(digits), num: v, pos: start})
+		default:
+			return nil, parseErrorAt(i, "unexpected character %q", r)
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, pos: len(rs)})
+	return toks, nil
+}
+
+// exprNode は式の構文木のノードを表します
+type exprNode interface {
+	eval(env *evalEnv) (float64, *exprError)
+	evalQuantity(env *evalEnv) (quantity, *exprError)
+}
+
+type numberNode struct {
+	value float64
+}
+
+type identNode struct {
+	name string
+	pos  int
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+	pos     int
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+	pos         int
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+	pos  int
+}
+
+// parser は再帰下降構文解析器です
+//
+//	expr     := term (('+' | '-') term)*
+//	term     := implicit (('*' | '/' | '%') implicit)*
+//	implicit := unary power*    （単位モードのみ。識別子が続く場合の暗黙の積）
+//	unary    := ('+' | '-') unary | power
+//	power    := primary ('^' unary)?
+//	primary  := number | ident | ident '(' args ')' | '(' expr ')'
+type parser struct {
+	toks        []token
+	pos         int
+	implicitMul bool
+	depth       int // 入れ子の深さ。深すぎる式でスタックを使い果たさないよう maxExprDepth で制限する
+}
+
+// maxExprDepth は括弧や単項演算子、累乗の入れ子の上限です
+const maxExprDepth = 256
+
+// parseExpression は式を構文木に変換します
+func parseExpression(src string) (exprNode, *exprError) {
+	return parse(src, false)
+}
+
+// parseExpressionWithUnits は 3 m のような暗黙の積を許して式を構文木に変換します
+func parseExpressionWithUnits(src string) (exprNode, *exprError) {
+	return parse(src, true)
+}
+
+func parse(src string, implicitMul bool) (exprNode, *exprError) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, implicitMul: implicitMul}
+	if p.peek().kind == tokEOF {
+		return nil, parseErrorAt(0, "empty expression")
+	}
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, parseErrorAt(t.pos, "unexpected token %q", t.text)
+	}
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseExpr() (exprNode, *exprError) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		op := p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op.text, left: left, right: right, pos: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (exprNode, *exprError) {
+	left, err := p.parseImplicit()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/", "%") {
+		op := p.next()
+		right, err := p.parseImplicit()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op.text, left: left, right: right, pos: op.pos}
+	}
+	return left, nil
+}
+
+// parseImplicit は 9.8 m/s^2 を (9.8·m)/(s^2) と解釈できるよう、暗黙の積を '/' より強く結合します
+func (p *parser) parseImplicit() (exprNode, *exprError) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.implicitMul && p.peek().kind == tokIdent {
+		t := p.peek()
+		right, err := p.parsePower()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "*", left: left, right: right, pos: t.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (exprNode, *exprError) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExprDepth {
+		return nil, parseErrorAt(p.peek().pos, "expression is nested too deeply (limit %d)", maxExprDepth)
+	}
+	if p.isOp("+", "-") {
+		op := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op.text, operand: operand, pos: op.pos}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *parser) parsePower() (exprNode, *exprError) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("^") {
+		op := p.next()
+		// 右結合: 2^3^2 = 2^(3^2)
+		exp, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: "^", left: base, right: exp, pos: op.pos}, nil
+	}
+	return base, nil
+}
+
+func (p *parser) parsePrimary() (exprNode, *exprError) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return &numberNode{value: t.num}, nil
+	case tokIdent:
+		if p.peek().kind != tokLParen {
+			return &identNode{name: t.text, pos: t.pos}, nil
+		}
+		p.next()
+		var args []exprNode
+		if p.peek().kind != tokRParen {
+			for {
+				arg, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if p.peek().kind != tokComma {
+					break
+				}
+				p.next()
+			}
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, parseErrorAt(closing.pos, "expected ')' to close call to %s", t.text)
+		}
+		return &callNode{name: t.text, args: args, pos: t.pos}, nil
+	case tokLParen:
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, parseErrorAt(closing.pos, "expected ')'")
+		}
+		return node, nil
+	case tokEOF:
+		return nil, parseErrorAt(t.pos, "unexpected end of expression")
+	default:
+		return nil, parseErrorAt(t.pos, "unexpected token %q", t.text)
+	}
+}
+
+// evalEnv は評価時に参照する変数を保持します
+type evalEnv struct {
+	vars map[string]float64
+}
+
+// exprConstants は式の中で使用できる定数です
+var exprConstants = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"phi": math.Phi,
+}
+
+// exprFunc は式の中で使用できる関数を表します
+type exprFunc struct {
+	minArgs, maxArgs int
+	fn               func(args []float64) float64
+}
+
+func unaryFunc(f func(float64) float64) exprFunc {
+	return exprFunc{minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return f(a[0]) }}
+}
+
+func binaryFunc(f func(float64, float64) float64) exprFunc {
+	return exprFunc{minArgs: 2, maxArgs: 2, fn: func(a []float64) float64 { return f(a[0], a[1]) }}
+}
+
+// exprFunctions は式の中で使用できる関数の一覧です
+var exprFunctions = map[string]exprFunc{
+	"sqrt":  unaryFunc(math.Sqrt),
+	"cbrt":  unaryFunc(math.Cbrt),
+	"abs":   unaryFunc(math.Abs),
+	"exp":   unaryFunc(math.Exp),
+	"ln":    unaryFunc(math.Log),
+	"log10": unaryFunc(math.Log10),
+	"log2":  unaryFunc(math.Log2),
+	"sin":   unaryFunc(math.Sin),
+	"cos":   unaryFunc(math.Cos),
+	"tan":   unaryFunc(math.Tan),
+	"asin":  unaryFunc(math.Asin),
+	"acos":  unaryFunc(math.Acos),
+	"atan":  unaryFunc(math.Atan),
+	"sinh":  unaryFunc(math.Sinh),
+	"cosh":  unaryFunc(math.Cosh),
+	"tanh":  unaryFunc(math.Tanh),
+	"floor": unaryFunc(math.Floor),
+	"ceil":  unaryFunc(math.Ceil),
+	"round": unaryFunc(math.Round),
+	"trunc": unaryFunc(math.Trunc),
+	"atan2": binaryFunc(math.Atan2),
+	"hypot": binaryFunc(math.Hypot),
+	"pow":   binaryFunc(math.Pow),
+	// log(x) は自然対数、log(x, b) は底 b の対数
+	"log": {minArgs: 1, maxArgs: 2, fn: func(a []float64) float64 {
+		if len(a) == 2 {
+			return math.Log(a[0]) / math.Log(a[1])
+		}
+		return math.Log(a[0])
+	}},
+	"min": {minArgs: 1, maxArgs: -1, fn: func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {minArgs: 1, maxArgs: -1, fn: func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+}
+
+func (n *numberNode) eval(env *evalEnv) (float64, *exprError) {
+	return n.value, nil
+}
+
+func (n *identNode) eval(env *evalEnv) (float64, *exprError) {
+	if env != nil {
+		if v, ok := env.vars[n.name]; ok {
+			return v, nil
+		}
+	}
+	if v, ok := exprConstants[n.name]; ok {
+		return v, nil
+	}
+	return 0, parseErrorAt(n.pos, "unknown identifier %q", n.name)
+}
+
+func (n *unaryNode) eval(env *evalEnv) (float64, *exprError) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == "-" {
+		return -v, nil
+	}
+	return v, nil
+}
+
+func (n *binaryNode) eval(env *evalEnv) (float64, *exprError) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	var v float64
+	switch n.op {
+	case "+":
+		v = l + r
+	case "-":
+		v = l - r
+	case "*":
+		v = l * r
+	case "/":
+		if r == 0 {
+			return 0, &exprError{Code: ErrorDivideByZero, Pos: n.pos, Msg: "division by zero"}
+		}
+		v = l / r
+	case "%":
+		if r == 0 {
+			return 0, &exprError{Code: ErrorDivideByZero, Pos: n.pos, Msg: "modulo by zero"}
+		}
+		v = math.Mod(l, r)
+	case "^":
+		v = math.Pow(l, r)
+	}
+	return checkResult(v, n.pos, n.op)
+}
+
+func (n *callNode) eval(env *evalEnv) (float64, *exprError) {
+	f, ok := exprFunctions[n.name]
+	if !ok {
+		return 0, parseErrorAt(n.pos, "unknown function %q", n.name)
+	}
+	if len(n.args) < f.minArgs || (f.maxArgs >= 0 && len(n.args) > f.maxArgs) {
+		return 0, parseErrorAt(n.pos, "wrong number of arguments to %s: got %d", n.name, len(n.args))
+	}
+	args := make([]float64, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(env)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return checkResult(f.fn(args), n.pos, n.name)
+}
+
+// checkResult は NaN や無限大を定義域エラーとして扱います
+func checkResult(v float64, pos int, op string) (float64, *exprError) {
+	if math.IsNaN(v) {
+		return 0, &exprError{Code: ErrorMathDomain, Pos: pos, Msg: fmt.Sprintf("%s: result is undefined", op)}
+	}
+	if math.IsInf(v, 0) {
+		return 0, &exprError{Code: ErrorMathDomain, Pos: pos, Msg: fmt.Sprintf("%s: result is out of range", op)}
+	}
+	return v, nil
+}
+
+// evaluateExpression は式を解析して評価します
+func evaluateExpression(src string, env *evalEnv) (float64, *exprError) {
+	node, err := parseExpression(src)
+	if err != nil {
+		return 0, err
+	}
+	return node.eval(env)
+}
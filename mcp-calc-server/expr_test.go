@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"1+2*3", 7},
+		{"(3+4)*2^5/7", 32},
+		{"-2^2", -4},
+		{"2^3^2", 512},
+		{"--3", 3},
+		{"10 % 4", 2},
+		{"sqrt(16) + abs(-2)", 6},
+		{"log(8, 2)", 3},
+		{"max(1, 5, 3) - min(4, 2)", 3},
+		{"2*pi", 2 * math.Pi},
+		{"1.5e2 + .5", 150.5},
+	}
+
+	for _, tt := range tests {
+		got, err := evaluateExpression(tt.expr, nil)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%q: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestEvaluateExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		code int
+		pos  int
+	}{
+		{"", ErrorInvalidParams, 0},
+		{"1 +", ErrorInvalidParams, 3},
+		{"(1+2", ErrorInvalidParams, 4},
+		{"2 $ 3", ErrorInvalidParams, 2},
+		{"foo(1)", ErrorInvalidParams, 0},
+		{"1 + x", ErrorInvalidParams, 4},
+		{"sqrt(1, 2)", ErrorInvalidParams, 0},
+		{"1 / (2-2)", ErrorDivideByZero, 2},
+		{"sqrt(-1)", ErrorMathDomain, 0},
+		{"10^400", ErrorMathDomain, 2},
+	}
+
+	for _, tt := range tests {
+		_, err := evaluateExpression(tt.expr, nil)
+		if err == nil {
+			t.Errorf("%q: expected error", tt.expr)
+			continue
+		}
+		if err.Code != tt.code {
+			t.Errorf("%q: expected code %d, got %d", tt.expr, tt.code, err.Code)
+		}
+		if err.Pos != tt.pos {
+			t.Errorf("%q: expected position %d, got %d", tt.expr, tt.pos, err.Pos)
+		}
+	}
+}
+
+func TestDeeplyNestedExpression(t *testing.T) {
+	// 入れ子が深すぎる式はスタックを使い果たす前に通常のエラーにする
+	deep := strings.Repeat("(", 100000) + "1" + strings.Repeat(")", 100000)
+	for _, expr := range []string{deep, strings.Repeat("-", 100000) + "1", strings.Repeat("2^", 100000) + "1"} {
+		if _, err := evaluateExpression(expr, nil); err == nil || err.Code != ErrorInvalidParams {
+			t.Errorf("expected invalid params for deeply nested expression, got %v", err)
+		}
+	}
+	if _, err := evaluateExpression(strings.Repeat("(", 100)+"1"+strings.Repeat(")", 100), nil); err != nil {
+		t.Errorf("unexpected error for moderately nested expression: %v", err)
+	}
+
+	// 同じ構文解析器を使うツールも落ちずにエラーを返す
+	s := newTestServer()
+	tests := []struct {
+		name string
+		args string
+	}{
+		{"evaluate", `{"expression":"` + deep + `"}`},
+		{"find_root", `{"expression":"` + deep + `","a":0,"b":1}`},
+		{"integrate", `{"expression":"` + deep + `","a":0,"b":1}`},
+		{"differentiate", `{"expression":"` + deep + `","at":0}`},
+	}
+	for _, tt := range tests {
+		if _, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args)); err == nil || err.Code != ErrorInvalidParams {
+			t.Errorf("%s: expected invalid params, got %v", tt.name, err)
+		}
+	}
+}
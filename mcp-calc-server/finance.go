@@ -0,0 +1,1042 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// 金融ツールの既定値と制限
+const (
+	defaultMoneyDecimals = 2
+	maxMoneyDecimals     = 10
+	maxFinancePeriods    = 10000
+	maxScheduleRows      = 1200
+	maxDepreciationLife  = 100
+	maxCashFlows         = 10000
+	maxCompoundYears     = 1000
+	defaultDBFactor      = 2  // 定率法の既定の倍率（200%定率法）
+	rateDecimals         = 10 // 利率の結果の小数点以下の桁数
+	maxDecimalDigits     = 40 // 金額や利率の分子・分母の桁数の上限
+	maxRate              = 1000000
+	maxExactBits         = 1 << 14 // 期間数乗を厳密に計算する分子・分母のビット数の上限
+	approxPrec           = 512     // 厳密に計算しない場合の浮動小数点数の精度（ビット）
+)
+
+// decimalLimit は金額や利率の分子・分母が超えてはならない 10^maxDecimalDigits です
+var decimalLimit = new(big.Int).Exp(big.NewInt(10), big.NewInt(maxDecimalDigits), nil)
+
+// AmortizationMethod はローンの返済方式を表します
+type AmortizationMethod string
+
+const (
+	EqualPayment   AmortizationMethod = "equal_payment"   // 元利均等返済
+	EqualPrincipal AmortizationMethod = "equal_principal" // 元金均等返済
+)
+
+// DepreciationMethod は減価償却の方法を表します
+type DepreciationMethod string
+
+const (
+	StraightLine     DepreciationMethod = "straight_line"       // 定額法
+	DecliningBalance DepreciationMethod = "declining_balance"   // 定率法（定額法の方が大きくなった年から切り替える）
+	SumOfYearsDigits DepreciationMethod = "sum_of_years_digits" // 級数法
+)
+
+// continuousCompounding は連続複利を表す compounding の値です
+const continuousCompounding = "continuous"
+
+// compoundingPeriods は複利の計算期間と年あたりの回数です
+var compoundingPeriods = []struct {
+	name    string
+	perYear int
+}{
+	{"annually", 1},
+	{"semiannually", 2},
+	{"quarterly", 4},
+	{"monthly", 12},
+	{"weekly", 52},
+	{"daily", 365},
+}
+
+// MoneyOptions は金額の丸め方を表します。計算は厳密な有理数で行い、金額として確定する時点で丸めます
+type MoneyOptions struct {
+	Decimals *int         `json:"decimals,omitempty"` // 小数点以下の桁数（円なら 0）
+	Rounding RoundingMode `json:"rounding,omitempty"`
+}
+
+// TimeValueParams は present_value / future_value ツールのパラメータを表します
+type TimeValueParams struct {
+	Rate           Number `json:"rate"`
+	PeriodsPerYear int    `json:"periodsPerYear,omitempty"`
+	Periods        int    `json:"periods"`
+	Payment        Number `json:"payment,omitempty"`
+	PresentValue   Number `json:"presentValue,omitempty"`
+	FutureValue    Number `json:"futureValue,omitempty"`
+	Due            bool   `json:"due,omitempty"` // 期首払い
+	MoneyOptions
+}
+
+// CashFlowParams は npv / irr ツールのパラメータを表します
+type CashFlowParams struct {
+	Rate      Number   `json:"rate,omitempty"`
+	CashFlows []Number `json:"cashFlows"`
+	Guess     *float64 `json:"guess,omitempty"` // irr の探索の起点
+	MoneyOptions
+}
+
+// DatedCashFlow は日付つきのキャッシュフローです
+type DatedCashFlow struct {
+	Date   string `json:"date"`
+	Amount Number `json:"amount"`
+}
+
+// XIRRParams は xirr ツールのパラメータを表します
+type XIRRParams struct {
+	CashFlows []DatedCashFlow `json:"cashFlows"`
+	Guess     *float64        `json:"guess,omitempty"`
+}
+
+// LoanParams は loan_payment / amortization_schedule ツールのパラメータを表します
+type LoanParams struct {
+	Principal      Number             `json:"principal"`
+	Rate           Number             `json:"rate"`
+	PeriodsPerYear int                `json:"periodsPerYear,omitempty"`
+	Periods        int                `json:"periods"`
+	Balloon        Number             `json:"balloon,omitempty"` // 最終回に残す元金
+	Method         AmortizationMethod `json:"method,omitempty"`
+	MoneyOptions
+}
+
+// CompoundParams は compound_interest ツールのパラメータを表します
+type CompoundParams struct {
+	Principal   Number `json:"principal"`
+	Rate        Number `json:"rate"`
+	Years       Number `json:"years"`
+	Compounding string `json:"compounding,omitempty"` // 省略時はすべての期間を比較する
+	MoneyOptions
+}
+
+// DepreciationParams は depreciation ツールのパラメータを表します
+type DepreciationParams struct {
+	Cost    Number             `json:"cost"`
+	Salvage Number             `json:"salvage,omitempty"`
+	Life    int                `json:"life"`
+	Method  DepreciationMethod `json:"method,omitempty"`
+	Factor  Number             `json:"factor,omitempty"`
+	MoneyOptions
+}
+
+// amortizationRow は返済予定表の1行です
+type amortizationRow struct {
+	Period    int    `json:"period"`
+	Payment   string `json:"payment"`
+	Interest  string `json:"interest"`
+	Principal string `json:"principal"`
+	Balance   string `json:"balance"`
+}
+
+// compoundRow は複利の計算期間ごとの結果です
+type compoundRow struct {
+	Compounding    string `json:"compounding"`
+	PeriodsPerYear int    `json:"periodsPerYear,omitempty"`
+	Amount         string `json:"amount"`
+	Interest       string `json:"interest"`
+	EffectiveRate  string `json:"effectiveRate"`
+	Exact          bool   `json:"exact"`
+}
+
+// depreciationRow は減価償却の1年分です
+type depreciationRow struct {
+	Period       int    `json:"period"`
+	Depreciation string `json:"depreciation"`
+	Accumulated  string `json:"accumulated"`
+	BookValue    string `json:"bookValue"`
+}
+
+// validate は丸めの指定を検証します
+func (o MoneyOptions) validate() *Error {
+	switch o.Rounding {
+	case "", RoundHalfEven, RoundHalfUp, RoundDown, RoundUp:
+	default:
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("Unknown rounding mode '%s'", o.Rounding),
+			Data:    []RoundingMode{RoundHalfEven, RoundHalfUp, RoundDown, RoundUp},
+		}
+	}
+	if o.Decimals != nil && (*o.Decimals < 0 || *o.Decimals > maxMoneyDecimals) {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("decimals must be between 0 and %d", maxMoneyDecimals),
+		}
+	}
+	return nil
+}
+
+// scale は小数点以下の桁数を返します
+func (o MoneyOptions) scale() int {
+	if o.Decimals == nil {
+		return defaultMoneyDecimals
+	}
+	return *o.Decimals
+}
+
+// round は金額を指定の桁数に丸めます。既定は銀行丸めです
+func (o MoneyOptions) round(r *big.Rat) *big.Rat {
+	mode := o.Rounding
+	if mode == "" {
+		mode = RoundHalfEven
+	}
+	return roundRat(r, o.scale(), mode)
+}
+
+// format は金額を丸めて固定桁の10進表記にします
+func (o MoneyOptions) format(r *big.Rat) string {
+	return o.round(r).FloatString(o.scale())
+}
+
+// moneySchema は金額の丸め方の指定を入力スキーマに加えます
+func moneySchema(properties map[string]any, required ...string) map[string]any {
+	properties["decimals"] = map[string]any{
+		"type":        "integer",
+		"minimum":     0,
+		"maximum":     maxMoneyDecimals,
+		"description": fmt.Sprintf("Decimal places of money amounts, e.g. 0 for yen (defaults to %d)", defaultMoneyDecimals),
+	}
+	properties["rounding"] = map[string]any{
+		"type":        "string",
+		"enum":        []RoundingMode{RoundHalfEven, RoundHalfUp, RoundDown, RoundUp},
+		"description": "Rounding of money amounts: half_even (banker's rounding, default), half_up (四捨五入), down (切り捨て) or up (切り上げ)",
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// decimalSchema は金額や利率のように文字列でも受け付ける数値の入力スキーマです
+func decimalSchema(desc string) map[string]any {
+	return map[string]any{
+		"type":        []string{"number", "string"},
+		"description": desc + ". Pass a string such as \"0.035\" to keep it exact",
+	}
+}
+
+// getFinanceTools は金融計算のツールの一覧を返します
+func getFinanceTools() []toolDef {
+	rate := decimalSchema("Nominal annual interest rate as a fraction, e.g. 0.05 for 5%")
+	periodsPerYear := map[string]any{
+		"type":        "integer",
+		"minimum":     1,
+		"maximum":     365,
+		"description": "Periods per year; the rate per period is rate / periodsPerYear (defaults to 1)",
+	}
+	periods := map[string]any{"type": "integer", "minimum": 0, "maximum": maxFinancePeriods, "description": "Number of periods"}
+	money := map[string]any{"type": "string", "description": "Exact decimal amount"}
+	valueOutput := outputSchema(map[string]any{"value": money}, "value")
+	rateOutput := outputSchema(map[string]any{
+		"rate":       map[string]any{"type": "string", "description": fmt.Sprintf("Rate per period rounded to %d decimal places", rateDecimals)},
+		"iterations": map[string]any{"type": "integer"},
+		"converged":  map[string]any{"type": "boolean"},
+	}, "rate", "iterations", "converged")
+	cashFlows := map[string]any{
+		"type":     "array",
+		"items":    decimalSchema("Cash flow (negative for outflows)"),
+		"minItems": 1,
+		"maxItems": maxCashFlows,
+	}
+	guess := map[string]any{"type": "number", "description": "Rate near which to look for a solution (defaults to 0.1)"}
+	loan := moneySchema(map[string]any{
+		"principal":      decimalSchema("Loan amount"),
+		"rate":           rate,
+		"periodsPerYear": periodsPerYear,
+		"periods":        map[string]any{"type": "integer", "minimum": 1, "maximum": maxScheduleRows, "description": "Number of payments"},
+		"balloon":        decimalSchema("Principal left to repay after the last regular payment (defaults to 0)"),
+		"method": map[string]any{
+			"type":        "string",
+			"enum":        []AmortizationMethod{EqualPayment, EqualPrincipal},
+			"description": "equal_payment (元利均等, level payments, default) or equal_principal (元金均等, level principal)",
+		},
+	}, "principal", "rate", "periods")
+
+	return bindTools([]Tool{
+		{
+			Name:        "present_value",
+			Description: "Present value of a series of equal payments plus a lump sum at the end. Amounts are positive; the result is their value today",
+			InputSchema: moneySchema(map[string]any{
+				"rate":           rate,
+				"periodsPerYear": periodsPerYear,
+				"periods":        periods,
+				"payment":        decimalSchema("Payment each period (defaults to 0)"),
+				"futureValue":    decimalSchema("Lump sum received after the last period (defaults to 0)"),
+				"due":            map[string]any{"type": "boolean", "description": "Payments at the start of each period (annuity due)"},
+			}, "rate", "periods"),
+			OutputSchema: valueOutput,
+		},
+		{
+			Name:        "future_value",
+			Description: "Future value of a present amount plus equal payments each period",
+			InputSchema: moneySchema(map[string]any{
+				"rate":           rate,
+				"periodsPerYear": periodsPerYear,
+				"periods":        periods,
+				"presentValue":   decimalSchema("Amount invested now (defaults to 0)"),
+				"payment":        decimalSchema("Payment each period (defaults to 0)"),
+				"due":            map[string]any{"type": "boolean", "description": "Payments at the start of each period (annuity due)"},
+			}, "rate", "periods"),
+			OutputSchema: valueOutput,
+		},
+		{
+			Name:        "npv",
+			Description: "Net present value of cash flows at equal intervals. The first cash flow occurs now and is not discounted",
+			InputSchema: moneySchema(map[string]any{
+				"rate":      decimalSchema("Discount rate per period"),
+				"cashFlows": cashFlows,
+			}, "rate", "cashFlows"),
+			OutputSchema: valueOutput,
+		},
+		{
+			Name:        "irr",
+			Description: "Internal rate of return per period of cash flows at equal intervals, the first occurring now",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"cashFlows": cashFlows,
+					"guess":     guess,
+				},
+				"required": []string{"cashFlows"},
+			},
+			OutputSchema: rateOutput,
+		},
+		{
+			Name:        "xirr",
+			Description: "Annual internal rate of return of cash flows on arbitrary dates (actual/365 day count)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"cashFlows": map[string]any{
+						"type": "array",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"date":   map[string]any{"type": "string", "description": "Date (YYYY-MM-DD)"},
+								"amount": decimalSchema("Cash flow (negative for outflows)"),
+							},
+							"required": []string{"date", "amount"},
+						},
+						"minItems": 2,
+						"maxItems": maxCashFlows,
+					},
+					"guess": guess,
+				},
+				"required": []string{"cashFlows"},
+			},
+			OutputSchema: rateOutput,
+		},
+		{
+			Name:        "loan_payment",
+			Description: "Payment per period of a loan and the total interest paid, following the rounded amortization schedule",
+			InputSchema: loan,
+			OutputSchema: outputSchema(map[string]any{
+				"payment":       map[string]any{"type": "string", "description": "Regular payment (first payment for equal_principal)"},
+				"finalPayment":  money,
+				"totalPayment":  money,
+				"totalInterest": money,
+			}, "payment", "finalPayment", "totalPayment", "totalInterest"),
+		},
+		{
+			Name:        "amortization_schedule",
+			Description: "Full loan amortization schedule with interest, principal and balance for every payment",
+			InputSchema: loan,
+			OutputSchema: outputSchema(map[string]any{
+				"rows": map[string]any{
+					"type": "array",
+					"items": outputSchema(map[string]any{
+						"period":    map[string]any{"type": "integer"},
+						"payment":   money,
+						"interest":  money,
+						"principal": money,
+						"balance":   money,
+					}, "period", "payment", "interest", "principal", "balance"),
+				},
+				"totalPayment":  money,
+				"totalInterest": money,
+			}, "rows", "totalPayment", "totalInterest"),
+		},
+		{
+			Name:        "compound_interest",
+			Description: "Compound interest on a principal. Without compounding, compares annual, semiannual, quarterly, monthly, weekly, daily and continuous compounding",
+			InputSchema: moneySchema(map[string]any{
+				"principal": decimalSchema("Amount invested"),
+				"rate":      rate,
+				"years":     decimalSchema("Investment period in years"),
+				"compounding": map[string]any{
+					"type": "string",
+					"enum": []string{"annually", "semiannually", "quarterly", "monthly", "weekly", "daily", continuousCompounding},
+				},
+			}, "principal", "rate", "years"),
+			OutputSchema: outputSchema(map[string]any{
+				"rows": map[string]any{
+					"type": "array",
+					"items": outputSchema(map[string]any{
+						"compounding":    map[string]any{"type": "string"},
+						"periodsPerYear": map[string]any{"type": "integer"},
+						"amount":         money,
+						"interest":       money,
+						"effectiveRate":  map[string]any{"type": "string"},
+						"exact":          map[string]any{"type": "boolean", "description": "False when a fractional number of periods or continuous compounding needed floating point"},
+					}, "compounding", "amount", "interest", "effectiveRate", "exact"),
+				},
+			}, "rows"),
+		},
+		{
+			Name:        "depreciation",
+			Description: "Depreciation schedule by the straight-line, declining-balance (switching to straight-line) or sum-of-years'-digits method",
+			InputSchema: moneySchema(map[string]any{
+				"cost":    decimalSchema("Acquisition cost"),
+				"salvage": decimalSchema("Salvage value at the end of the useful life (defaults to 0)"),
+				"life":    map[string]any{"type": "integer", "minimum": 1, "maximum": maxDepreciationLife, "description": "Useful life in years"},
+				"method": map[string]any{
+					"type":        "string",
+					"enum":        []DepreciationMethod{StraightLine, DecliningBalance, SumOfYearsDigits},
+					"description": "Depreciation method (defaults to straight_line)",
+				},
+				"factor": decimalSchema(fmt.Sprintf("Declining-balance factor, the rate being factor / life (defaults to %d)", defaultDBFactor)),
+			}, "cost", "life"),
+			OutputSchema: outputSchema(map[string]any{
+				"rows": map[string]any{
+					"type": "array",
+					"items": outputSchema(map[string]any{
+						"period":       map[string]any{"type": "integer"},
+						"depreciation": money,
+						"accumulated":  money,
+						"bookValue":    money,
+					}, "period", "depreciation", "accumulated", "bookValue"),
+				},
+			}, "rows"),
+		},
+	}, (*Server).handleFinance)
+}
+
+// handleFinance は金融ツールの呼び出しを処理します
+func (s *Server) handleFinance(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	switch name {
+	case "present_value", "future_value":
+		var params TimeValueParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return timeValue(name, params)
+	case "npv":
+		var params CashFlowParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return npv(params)
+	case "irr":
+		var params CashFlowParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return irr(params)
+	case "xirr":
+		var params XIRRParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return xirr(params)
+	case "loan_payment", "amortization_schedule":
+		var params LoanParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return loan(name, params)
+	case "compound_interest":
+		var params CompoundParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return compoundInterest(params)
+	case "depreciation":
+		var params DepreciationParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return depreciation(params)
+	default:
+		return nil, unknownTool(name)
+	}
+}
+
+// decimalParam は数値の引数を有理数にします。省略された場合は 0 です
+func decimalParam(name string, n Number) (*big.Rat, *Error) {
+	if n == "" {
+		return new(big.Rat), nil
+	}
+	tooLarge := &Error{
+		Code:    ErrorInvalidParams,
+		Message: fmt.Sprintf("Invalid %s", name),
+		Data:    fmt.Sprintf("decimal values are limited to %d digits", maxDecimalDigits),
+	}
+	// 1e999999 のような指数表記は有理数にする前に桁数を確かめる
+	if i := strings.IndexAny(string(n), "eE"); i >= 0 {
+		if exp, err := strconv.Atoi(string(n[i+1:])); err == nil && absInt(exp) > maxDecimalDigits {
+			return nil, tooLarge
+		}
+	}
+	r, err := n.Rat()
+	if err != nil {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Invalid %s", name), Data: err.Error()}
+	}
+	if new(big.Int).Abs(r.Num()).Cmp(decimalLimit) >= 0 || r.Denom().Cmp(decimalLimit) >= 0 {
+		return nil, tooLarge
+	}
+	return r, nil
+}
+
+// periodRate は年利を1期間あたりの利率にします
+func periodRate(rate Number, periodsPerYear int) (*big.Rat, *Error) {
+	r, err := decimalParam("rate", rate)
+	if err != nil {
+		return nil, err
+	}
+	// 期間数乗する値なので、大きさも抑えておく
+	if new(big.Rat).Abs(r).Cmp(big.NewRat(maxRate, 1)) > 0 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("rate must be between -%d and %d", maxRate, maxRate)}
+	}
+	if periodsPerYear < 0 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "periodsPerYear must be positive"}
+	}
+	if periodsPerYear > 1 {
+		r.Quo(r, new(big.Rat).SetInt64(int64(periodsPerYear)))
+	}
+	if r.Cmp(big.NewRat(-1, 1)) <= 0 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "The rate per period must be greater than -1"}
+	}
+	return r, nil
+}
+
+// fitsExact は r^n の分子と分母が maxExactBits に収まるかを返します
+func fitsExact(r *big.Rat, n int) bool {
+	return (r.Num().BitLen()+r.Denom().BitLen())*n <= maxExactBits
+}
+
+// ratPow は有理数の n 乗（n ≥ 0）を求めます。結果が maxExactBits に収まらない場合は
+// approxPrec ビットの浮動小数点数で計算し、桁数の増え方を抑えます
+func ratPow(r *big.Rat, n int) *big.Rat {
+	if !fitsExact(r, n) {
+		x := new(big.Float).SetPrec(approxPrec).SetRat(r)
+		result := new(big.Float).SetPrec(approxPrec).SetInt64(1)
+		for ; n > 0; n >>= 1 {
+			if n&1 == 1 {
+				result.Mul(result, x)
+			}
+			x.Mul(x, x)
+		}
+		v, _ := result.Rat(nil)
+		return v
+	}
+	e := big.NewInt(int64(n))
+	num := new(big.Int).Exp(r.Num(), e, nil)
+	den := new(big.Int).Exp(r.Denom(), e, nil)
+	return new(big.Rat).SetFrac(num, den)
+}
+
+// growth は (1 + i)^n を求めます
+func growth(i *big.Rat, n int) *big.Rat {
+	return ratPow(new(big.Rat).Add(big.NewRat(1, 1), i), n)
+}
+
+// annuityFactor は毎期1の支払いの n 期後の価値 ((1+i)^n - 1) / i を求めます。期首払いでは (1 + i) 倍します
+func annuityFactor(i *big.Rat, n int, due bool) *big.Rat {
+	if i.Sign() == 0 {
+		return new(big.Rat).SetInt64(int64(n))
+	}
+	f := growth(i, n)
+	f.Sub(f, big.NewRat(1, 1))
+	f.Quo(f, i)
+	if due {
+		f.Mul(f, new(big.Rat).Add(big.NewRat(1, 1), i))
+	}
+	return f
+}
+
+// timeValue は現在価値または将来価値を求めます
+func timeValue(name string, params TimeValueParams) (*CallToolResult, *Error) {
+	if err := params.MoneyOptions.validate(); err != nil {
+		return nil, err
+	}
+	if params.Periods < 0 || params.Periods > maxFinancePeriods {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("periods must be between 0 and %d", maxFinancePeriods)}
+	}
+	i, err := periodRate(params.Rate, params.PeriodsPerYear)
+	if err != nil {
+		return nil, err
+	}
+	pmt, err := decimalParam("payment", params.Payment)
+	if err != nil {
+		return nil, err
+	}
+	n := params.Periods
+	annuity := new(big.Rat).Mul(pmt, annuityFactor(i, n, params.Due))
+
+	var value *big.Rat
+	if name == "present_value" {
+		fv, err := decimalParam("futureValue", params.FutureValue)
+		if err != nil {
+			return nil, err
+		}
+		// 将来の金額をまとめて現在に割り引く
+		value = annuity.Add(annuity, fv)
+		value.Quo(value, growth(i, n))
+	} else {
+		pv, err := decimalParam("presentValue", params.PresentValue)
+		if err != nil {
+			return nil, err
+		}
+		value = pv.Mul(pv, growth(i, n))
+		value.Add(value, annuity)
+	}
+	text := params.MoneyOptions.format(value)
+	return newStructuredResult(text, map[string]any{"value": text}), nil
+}
+
+// cashFlowValues は数値のキャッシュフローを有理数にします
+func cashFlowValues(flows []Number) ([]*big.Rat, *Error) {
+	if len(flows) == 0 || len(flows) > maxCashFlows {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("cashFlows must contain between 1 and %d values", maxCashFlows)}
+	}
+	values := make([]*big.Rat, len(flows))
+	for i, f := range flows {
+		v, err := decimalParam(fmt.Sprintf("cashFlows[%d]", i), f)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// npv は正味現在価値を厳密に求めます
+func npv(params CashFlowParams) (*CallToolResult, *Error) {
+	if err := params.MoneyOptions.validate(); err != nil {
+		return nil, err
+	}
+	i, err := periodRate(params.Rate, 1)
+	if err != nil {
+		return nil, err
+	}
+	flows, err := cashFlowValues(params.CashFlows)
+	if err != nil {
+		return nil, err
+	}
+	// ホーナー法で後ろから割り引く。分母が大きくなりすぎる場合は approxPrec ビットの精度で計算する
+	discount := new(big.Rat).Add(big.NewRat(1, 1), i)
+	var total *big.Rat
+	if fitsExact(discount, len(flows)) {
+		total = new(big.Rat)
+		for k := len(flows) - 1; k >= 0; k-- {
+			if k < len(flows)-1 {
+				total.Quo(total, discount)
+			}
+			total.Add(total, flows[k])
+		}
+	} else {
+		d := new(big.Float).SetPrec(approxPrec).SetRat(discount)
+		t := new(big.Float).SetPrec(approxPrec)
+		for k := len(flows) - 1; k >= 0; k-- {
+			if k < len(flows)-1 {
+				t.Quo(t, d)
+			}
+			t.Add(t, new(big.Float).SetPrec(approxPrec).SetRat(flows[k]))
+		}
+		total, _ = t.Rat(nil)
+	}
+	text := params.MoneyOptions.format(total)
+	return newStructuredResult(text, map[string]any{"value": text}), nil
+}
+
+// irrGrid は内部収益率の符号の変化を探す利率の点です
+var irrGrid = []float64{-0.99, -0.9, -0.75, -0.5, -0.25, -0.1, 0, 0.05, 0.1, 0.2, 0.35, 0.5, 0.75, 1, 2, 5, 10, 100, 1000}
+
+// solveRate は f(rate) = 0 となる利率を探します。guess に近い符号の変化する区間をブレント法で解きます
+func solveRate(f func(float64) float64, guess *float64) (*CallToolResult, *Error) {
+	g := 0.1
+	if guess != nil {
+		g = *guess
+	}
+	eval := func(x float64) (float64, *Error) {
+		v := f(x)
+		if math.IsNaN(v) {
+			return 0, &Error{Code: ErrorMathDomain, Message: "Cash flows cannot be evaluated at this rate"}
+		}
+		return v, nil
+	}
+
+	var best *RootParams
+	for k := 0; k+1 < len(irrGrid); k++ {
+		a, b := irrGrid[k], irrGrid[k+1]
+		fa, fb := f(a), f(b)
+		if math.IsNaN(fa) || math.IsNaN(fb) || fa*fb > 0 {
+			continue
+		}
+		dist := math.Max(0, math.Max(a-g, g-b))
+		if best == nil || dist < math.Max(0, math.Max(best.A-g, g-best.B)) {
+			best = &RootParams{A: a, B: b}
+		}
+	}
+	if best == nil {
+		return nil, &Error{
+			Code:    ErrorMathDomain,
+			Message: "No rate of return found; the cash flows need both positive and negative values",
+		}
+	}
+	best.Tolerance = 1e-14
+	best.MaxIterations = defaultMaxIterations
+	result, err := brent(eval, *best)
+	if err != nil {
+		return nil, err
+	}
+	rate := new(big.Rat)
+	rate.SetFloat64(result.Root)
+	text := roundRat(rate, rateDecimals, RoundHalfEven).FloatString(rateDecimals)
+	res := newStructuredResult(text, map[string]any{
+		"rate":       text,
+		"iterations": result.Iterations,
+		"converged":  result.Converged,
+	})
+	res.value = floatNumber(result.Root)
+	return res, nil
+}
+
+// irr は内部収益率を求めます
+func irr(params CashFlowParams) (*CallToolResult, *Error) {
+	flows, err := cashFlowValues(params.CashFlows)
+	if err != nil {
+		return nil, err
+	}
+	amounts := make([]float64, len(flows))
+	for k, v := range flows {
+		amounts[k], _ = v.Float64()
+	}
+	return solveRate(func(r float64) float64 {
+		var total float64
+		for k := len(amounts) - 1; k >= 0; k-- {
+			total = total/(1+r) + amounts[k]
+		}
+		return total
+	}, params.Guess)
+}
+
+// xirr は日付つきのキャッシュフローの年率の内部収益率を求めます
+func xirr(params XIRRParams) (*CallToolResult, *Error) {
+	if len(params.CashFlows) < 2 || len(params.CashFlows) > maxCashFlows {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("cashFlows must contain between 2 and %d values", maxCashFlows)}
+	}
+	dates := make([]time.Time, len(params.CashFlows))
+	amounts := make([]float64, len(params.CashFlows))
+	for k, cf := range params.CashFlows {
+		d, err := parseDay(cf.Date)
+		if err != nil {
+			return nil, err
+		}
+		v, err := decimalParam(fmt.Sprintf("cashFlows[%d].amount", k), cf.Amount)
+		if err != nil {
+			return nil, err
+		}
+		dates[k] = d
+		amounts[k], _ = v.Float64()
+	}
+	// 最も早い日付を基準にする
+	first := slices.MinFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+	years := make([]float64, len(dates))
+	for k, d := range dates {
+		years[k] = d.Sub(first).Hours() / 24 / 365
+	}
+	return solveRate(func(r float64) float64 {
+		var total float64
+		for k, a := range amounts {
+			total += a / math.Pow(1+r, years[k])
+		}
+		return total
+	}, params.Guess)
+}
+
+// amortize は返済予定表を作ります。利息は毎回丸め、最終回で端数を調整して残高を balloon にします
+func amortize(principal, balloon, i *big.Rat, n int, method AmortizationMethod, opts MoneyOptions) []amortizationRow {
+	balance := new(big.Rat).Set(principal)
+	var payment, principalPart *big.Rat
+	switch method {
+	case EqualPrincipal:
+		principalPart = new(big.Rat).Sub(principal, balloon)
+		principalPart = opts.round(principalPart.Quo(principalPart, new(big.Rat).SetInt64(int64(n))))
+	default:
+		// 支払額 = (元金 × (1+i)^n - balloon) / 年金終価係数
+		payment = new(big.Rat).Mul(principal, growth(i, n))
+		payment.Sub(payment, balloon)
+		payment = opts.round(payment.Quo(payment, annuityFactor(i, n, false)))
+	}
+
+	rows := make([]amortizationRow, n)
+	for k := range n {
+		interest := opts.round(new(big.Rat).Mul(balance, i))
+		var pay, repaid *big.Rat
+		switch {
+		case k == n-1:
+			repaid = new(big.Rat).Sub(balance, balloon)
+			pay = new(big.Rat).Add(repaid, interest)
+		case method == EqualPrincipal:
+			repaid = principalPart
+			pay = new(big.Rat).Add(repaid, interest)
+		default:
+			pay = payment
+			repaid = new(big.Rat).Sub(payment, interest)
+		}
+		balance.Sub(balance, repaid)
+		rows[k] = amortizationRow{
+			Period:    k + 1,
+			Payment:   opts.format(pay),
+			Interest:  opts.format(interest),
+			Principal: opts.format(repaid),
+			Balance:   opts.format(balance),
+		}
+	}
+	return rows
+}
+
+// loan はローンの返済額または返済予定表を求めます
+func loan(name string, params LoanParams) (*CallToolResult, *Error) {
+	if err := params.MoneyOptions.validate(); err != nil {
+		return nil, err
+	}
+	if params.Periods < 1 || params.Periods > maxScheduleRows {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("periods must be between 1 and %d", maxScheduleRows)}
+	}
+	switch params.Method {
+	case "", EqualPayment, EqualPrincipal:
+	default:
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown method '%s'", params.Method)}
+	}
+	principal, err := decimalParam("principal", params.Principal)
+	if err != nil {
+		return nil, err
+	}
+	balloon, err := decimalParam("balloon", params.Balloon)
+	if err != nil {
+		return nil, err
+	}
+	i, err := periodRate(params.Rate, params.PeriodsPerYear)
+	if err != nil {
+		return nil, err
+	}
+	if principal.Sign() <= 0 || balloon.Sign() < 0 || balloon.Cmp(principal) > 0 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "principal must be positive and balloon between 0 and principal"}
+	}
+
+	rows := amortize(principal, balloon, i, params.Periods, params.Method, params.MoneyOptions)
+	totalPayment, totalInterest := new(big.Rat), new(big.Rat)
+	for _, row := range rows {
+		p, _ := new(big.Rat).SetString(row.Payment)
+		interest, _ := new(big.Rat).SetString(row.Interest)
+		totalPayment.Add(totalPayment, p)
+		totalInterest.Add(totalInterest, interest)
+	}
+	total, interest := params.MoneyOptions.format(totalPayment), params.MoneyOptions.format(totalInterest)
+
+	if name == "loan_payment" {
+		last := rows[len(rows)-1].Payment
+		text := fmt.Sprintf("%s per period (final %s, total %s, interest %s)", rows[0].Payment, last, total, interest)
+		return newStructuredResult(text, map[string]any{
+			"payment":       rows[0].Payment,
+			"finalPayment":  last,
+			"totalPayment":  total,
+			"totalInterest": interest,
+		}), nil
+	}
+
+	table := [][]string{{"period", "payment", "interest", "principal", "balance"}}
+	for _, row := range rows {
+		table = append(table, []string{fmt.Sprint(row.Period), row.Payment, row.Interest, row.Principal, row.Balance})
+	}
+	text := formatTable(table) + fmt.Sprintf("total %s, interest %s", total, interest)
+	return newStructuredResult(text, map[string]any{
+		"rows":          rows,
+		"totalPayment":  total,
+		"totalInterest": interest,
+	}), nil
+}
+
+// formatTable は表を右寄せの列にそろえたテキストにします
+func formatTable(rows [][]string) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+	}
+	w.Flush()
+	return b.String()
+}
+
+// compoundInterest は複利で運用した金額を計算期間ごとに求めます
+func compoundInterest(params CompoundParams) (*CallToolResult, *Error) {
+	if err := params.MoneyOptions.validate(); err != nil {
+		return nil, err
+	}
+	principal, err := decimalParam("principal", params.Principal)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := periodRate(params.Rate, 1)
+	if err != nil {
+		return nil, err
+	}
+	years, err := decimalParam("years", params.Years)
+	if err != nil {
+		return nil, err
+	}
+	if years.Sign() < 0 || years.Cmp(big.NewRat(maxCompoundYears, 1)) > 0 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("years must be between 0 and %d", maxCompoundYears)}
+	}
+
+	var rows []compoundRow
+	for _, c := range compoundingPeriods {
+		if params.Compounding == "" || params.Compounding == c.name {
+			rows = append(rows, compound(principal, rate, years, c.name, c.perYear, params.MoneyOptions))
+		}
+	}
+	if params.Compounding == "" || params.Compounding == continuousCompounding {
+		rows = append(rows, compound(principal, rate, years, continuousCompounding, 0, params.MoneyOptions))
+	}
+	if len(rows) == 0 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown compounding '%s'", params.Compounding)}
+	}
+
+	text := rows[0].Amount
+	if len(rows) > 1 {
+		table := [][]string{{"compounding", "amount", "interest", "effective rate"}}
+		for _, row := range rows {
+			table = append(table, []string{row.Compounding, row.Amount, row.Interest, row.EffectiveRate})
+		}
+		text = strings.TrimSuffix(formatTable(table), "\n")
+	}
+	result := newStructuredResult(text, map[string]any{"rows": rows})
+	if len(rows) == 1 {
+		result.value = Number(rows[0].Amount)
+	}
+	return result, nil
+}
+
+// compound は1つの計算期間で複利計算します。期間の数が maxFinancePeriods 以下の整数なら厳密に、そうでなければ float64 で計算します
+func compound(principal, rate, years *big.Rat, name string, perYear int, opts MoneyOptions) compoundRow {
+	row := compoundRow{Compounding: name, PeriodsPerYear: perYear}
+	var factor, effective *big.Rat
+	periods := new(big.Rat).Mul(years, new(big.Rat).SetInt64(int64(perYear)))
+	if perYear > 0 && periods.IsInt() && periods.Cmp(big.NewRat(maxFinancePeriods, 1)) <= 0 {
+		i := new(big.Rat).Quo(rate, new(big.Rat).SetInt64(int64(perYear)))
+		n := int(periods.Num().Int64())
+		factor = growth(i, n)
+		effective = growth(i, perYear)
+		row.Exact = fitsExact(new(big.Rat).Add(big.NewRat(1, 1), i), max(n, perYear))
+	} else {
+		r, _ := rate.Float64()
+		y, _ := years.Float64()
+		var f, e float64
+		if perYear == 0 {
+			f, e = math.Exp(r*y), math.Exp(r)
+		} else {
+			m := float64(perYear)
+			f, e = math.Pow(1+r/m, m*y), math.Pow(1+r/m, m)
+		}
+		factor, effective = new(big.Rat).SetFloat64(f), new(big.Rat).SetFloat64(e)
+	}
+	amount := opts.round(new(big.Rat).Mul(principal, factor))
+	row.Amount = opts.format(amount)
+	row.Interest = opts.format(new(big.Rat).Sub(amount, principal))
+	row.EffectiveRate = roundRat(effective.Sub(effective, big.NewRat(1, 1)), rateDecimals, RoundHalfEven).FloatString(rateDecimals)
+	return row
+}
+
+// depreciation は減価償却の予定表を作ります。毎年の償却額を丸め、最終年で残存価額にそろえます
+func depreciation(params DepreciationParams) (*CallToolResult, *Error) {
+	if err := params.MoneyOptions.validate(); err != nil {
+		return nil, err
+	}
+	if params.Life < 1 || params.Life > maxDepreciationLife {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("life must be between 1 and %d", maxDepreciationLife)}
+	}
+	cost, err := decimalParam("cost", params.Cost)
+	if err != nil {
+		return nil, err
+	}
+	salvage, err := decimalParam("salvage", params.Salvage)
+	if err != nil {
+		return nil, err
+	}
+	if cost.Sign() <= 0 || salvage.Sign() < 0 || salvage.Cmp(cost) > 0 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "cost must be positive and salvage between 0 and cost"}
+	}
+	factor := big.NewRat(defaultDBFactor, 1)
+	if params.Factor != "" {
+		if factor, err = decimalParam("factor", params.Factor); err != nil {
+			return nil, err
+		}
+		if factor.Sign() <= 0 {
+			return nil, &Error{Code: ErrorInvalidParams, Message: "factor must be positive"}
+		}
+	}
+
+	opts := params.MoneyOptions
+	life := new(big.Rat).SetInt64(int64(params.Life))
+	depreciable := new(big.Rat).Sub(cost, salvage)
+	digits := new(big.Rat).SetInt64(int64(params.Life * (params.Life + 1) / 2))
+	book := new(big.Rat).Set(cost)
+	accumulated := new(big.Rat)
+	rows := make([]depreciationRow, params.Life)
+	for k := range params.Life {
+		remaining := new(big.Rat).Sub(book, salvage)
+		var amount *big.Rat
+		switch params.Method {
+		case "", StraightLine:
+			amount = new(big.Rat).Quo(depreciable, life)
+		case DecliningBalance:
+			amount = new(big.Rat).Mul(book, factor)
+			amount.Quo(amount, life)
+			// 残りの年数で均等に償却する方が大きくなったら定額に切り替える
+			if sl := new(big.Rat).Quo(remaining, new(big.Rat).SetInt64(int64(params.Life-k))); sl.Cmp(amount) > 0 {
+				amount = sl
+			}
+		case SumOfYearsDigits:
+			amount = new(big.Rat).Mul(depreciable, new(big.Rat).SetInt64(int64(params.Life-k)))
+			amount.Quo(amount, digits)
+		default:
+			return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown method '%s'", params.Method)}
+		}
+		amount = opts.round(amount)
+		if k == params.Life-1 || amount.Cmp(remaining) > 0 {
+			amount = remaining
+		}
+		book.Sub(book, amount)
+		accumulated.Add(accumulated, amount)
+		rows[k] = depreciationRow{
+			Period:       k + 1,
+			Depreciation: opts.format(amount),
+			Accumulated:  opts.format(accumulated),
+			BookValue:    opts.format(book),
+		}
+	}
+
+	table := [][]string{{"year", "depreciation", "accumulated", "book value"}}
+	for _, row := range rows {
+		table = append(table, []string{fmt.Sprint(row.Period), row.Depreciation, row.Accumulated, row.BookValue})
+	}
+	return newStructuredResult(strings.TrimSuffix(formatTable(table), "\n"), map[string]any{"rows": rows}), nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestFinanceTools(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"present_value", `{"rate":0.08,"periodsPerYear":12,"periods":240,"payment":500}`, "59777.15"},
+		{"present_value", `{"rate":"0.05","periods":10,"futureValue":10000,"decimals":0}`, "6139"},
+		{"present_value", `{"rate":0,"periods":12,"payment":100}`, "1200.00"},
+		{"future_value", `{"rate":"0.06","periodsPerYear":12,"periods":10,"payment":200,"presentValue":500,"due":true}`, "2581.40"},
+		// 最初のキャッシュフローは割り引かない
+		{"npv", `{"rate":"0.1","cashFlows":[-10000,3000,4200,6800]}`, "1307.29"},
+		{"irr", `{"cashFlows":[-70000,12000,15000,18000,21000,26000]}`, "0.0866309480"},
+		{"irr", `{"cashFlows":[-70000,12000,15000,18000,21000],"guess":-0.1}`, "-0.0212448483"},
+		{"xirr", `{"cashFlows":[{"date":"2008-01-01","amount":-10000},{"date":"2008-03-01","amount":2750},{"date":"2008-10-30","amount":4250},{"date":"2009-02-15","amount":3250},{"date":"2009-04-01","amount":2750}]}`, "0.3733625335"},
+		{"loan_payment", `{"principal":10000,"rate":"0.08","periodsPerYear":12,"periods":10}`, "1037.03 per period (final 1037.07, total 10370.34, interest 370.34)"},
+		// 残価は最終回の返済後に残高として残る
+		{"loan_payment", `{"principal":1000000,"rate":"0.06","periods":3,"balloon":400000,"decimals":0}`, "248466 per period (final 248466, total 745398, interest 145398)"},
+		{"compound_interest", `{"principal":10000,"rate":"0.05","years":10,"compounding":"monthly"}`, "16470.09"},
+		{"compound_interest", `{"principal":10000,"rate":"0.05","years":10,"compounding":"continuous"}`, "16487.21"},
+	}
+
+	for _, tt := range tests {
+		if got := callText(t, s, tt.name, tt.args); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.name, tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestAmortizationSchedule(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		args  string
+		first amortizationRow
+		last  amortizationRow
+		total string
+	}{
+		// 元利均等返済で利息を切り捨てる場合、最終回で端数を調整する
+		{
+			`{"principal":1000000,"rate":"0.015","periodsPerYear":12,"periods":6,"decimals":0,"rounding":"down"}`,
+			amortizationRow{1, "167396", "1250", "166146", "833854"},
+			amortizationRow{6, "167397", "208", "167189", "0"},
+			"4377",
+		},
+		{
+			`{"principal":1000000,"rate":"0.015","periodsPerYear":12,"periods":6,"decimals":0,"method":"equal_principal"}`,
+			amortizationRow{1, "167917", "1250", "166667", "833333"},
+			amortizationRow{6, "166873", "208", "166665", "0"},
+			"4375",
+		},
+		// 銀行丸めでは 0.5 円の利息が偶数に丸められる
+		{
+			`{"principal":"90","rate":"0.05","periods":2,"decimals":0}`,
+			amortizationRow{1, "48", "4", "44", "46"},
+			amortizationRow{2, "48", "2", "46", "0"},
+			"6",
+		},
+		{
+			`{"principal":"90","rate":"0.05","periods":2,"decimals":0,"rounding":"half_up"}`,
+			amortizationRow{1, "48", "5", "43", "47"},
+			amortizationRow{2, "49", "2", "47", "0"},
+			"7",
+		},
+	}
+
+	for _, tt := range tests {
+		result, err := s.callTool(context.Background(), "amortization_schedule", json.RawMessage(tt.args))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.args, err)
+		}
+		data := result.StructuredContent.(map[string]any)
+		rows := data["rows"].([]amortizationRow)
+		if rows[0] != tt.first || rows[len(rows)-1] != tt.last || data["totalInterest"] != tt.total {
+			t.Errorf("%s: expected %+v … %+v (interest %s), got %+v … %+v (interest %v)",
+				tt.args, tt.first, tt.last, tt.total, rows[0], rows[len(rows)-1], data["totalInterest"])
+		}
+	}
+}
+
+func TestCompoundInterestComparison(t *testing.T) {
+	s := newTestServer()
+	result, err := s.callTool(context.Background(), "compound_interest", json.RawMessage(`{"principal":10000,"rate":"0.05","years":10}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows := result.StructuredContent.(map[string]any)["rows"].([]compoundRow)
+	want := []string{"16288.95", "16386.16", "16436.19", "16470.09", "16483.25", "16486.65", "16487.21"}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %+v", len(want), rows)
+	}
+	for i, row := range rows {
+		if row.Amount != want[i] {
+			t.Errorf("%s: expected %s, got %s", row.Compounding, want[i], row.Amount)
+		}
+	}
+	// 連続複利は float64 で計算する
+	if !rows[0].Exact || rows[len(rows)-1].Exact || rows[0].EffectiveRate != "0.0500000000" {
+		t.Errorf("unexpected rows: %+v", rows)
+	}
+}
+
+func TestDepreciation(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		args  string
+		first string
+		last  string
+	}{
+		{`{"cost":30000,"salvage":7500,"life":10}`, "2250.00", "2250.00"},
+		{`{"cost":30000,"salvage":7500,"life":10,"method":"sum_of_years_digits"}`, "4090.91", "409.09"},
+		{`{"cost":2400,"salvage":300,"life":10,"method":"declining_balance"}`, "480.00", "22.12"},
+		// 定額の方が大きくなった年から切り替える
+		{`{"cost":1000000,"life":5,"method":"declining_balance","decimals":0}`, "400000", "108000"},
+		{`{"cost":100000,"life":3,"decimals":0,"rounding":"down"}`, "33333", "33334"},
+	}
+
+	for _, tt := range tests {
+		result, err := s.callTool(context.Background(), "depreciation", json.RawMessage(tt.args))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.args, err)
+		}
+		rows := result.StructuredContent.(map[string]any)["rows"].([]depreciationRow)
+		if rows[0].Depreciation != tt.first || rows[len(rows)-1].Depreciation != tt.last {
+			t.Errorf("%s: expected %s … %s, got %+v", tt.args, tt.first, tt.last, rows)
+		}
+	}
+}
+
+func TestRatPowApproximation(t *testing.T) {
+	// maxExactBits を超える累乗も、厳密な値と approxPrec ビットの精度で一致する
+	r := big.NewRat(241, 240)
+	n := 10000
+	if fitsExact(r, n) {
+		t.Fatalf("expected (241/240)^%d to exceed maxExactBits", n)
+	}
+	e := big.NewInt(int64(n))
+	exact := new(big.Rat).SetFrac(new(big.Int).Exp(r.Num(), e, nil), new(big.Int).Exp(r.Denom(), e, nil))
+	diff := new(big.Rat).Sub(ratPow(r, n), exact)
+	diff.Abs(diff).Quo(diff, exact)
+	if diff.Cmp(new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(140), nil))) > 0 {
+		t.Errorf("expected relative error below 1e-140, got %s", diff.FloatString(160))
+	}
+}
+
+func TestFinanceLargeInputsFinish(t *testing.T) {
+	s := newTestServer()
+	// 桁数の多い利率でも分子・分母を増やし続けずにすぐ終わる
+	for _, args := range []string{
+		`{"rate":"0.1234567890123456789012345678901234567","periods":10000,"presentValue":"1"}`,
+		`{"rate":"999999.123456789","periods":10000,"presentValue":"1","periodsPerYear":12}`,
+	} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if _, err := s.callTool(context.Background(), "future_value", json.RawMessage(args)); err != nil {
+				t.Errorf("%s: unexpected error: %v", args, err)
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: did not finish within 5s", args)
+		}
+	}
+}
+
+func TestFinanceToolErrors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		code int
+	}{
+		{"present_value", `{"rate":"abc","periods":10}`, ErrorInvalidParams},
+		{"present_value", `{"rate":-1,"periods":10}`, ErrorInvalidParams},
+		{"present_value", `{"rate":0.05,"periods":10,"rounding":"nearest"}`, ErrorInvalidParams},
+		{"present_value", `{"rate":0.05,"periods":10,"decimals":11}`, ErrorInvalidParams},
+		// 期間数乗する利率や金額の大きさと桁数は制限する
+		{"future_value", `{"rate":"1e3000","periods":10000,"presentValue":"1"}`, ErrorInvalidParams},
+		{"future_value", `{"rate":2000000,"periods":10,"presentValue":"1"}`, ErrorInvalidParams},
+		{"future_value", `{"rate":0.05,"periods":10,"presentValue":"0.12345678901234567890123456789012345678901"}`, ErrorInvalidParams},
+		{"npv", `{"rate":0.05,"cashFlows":["1e-41"]}`, ErrorInvalidParams},
+		{"irr", `{"cashFlows":[100,200]}`, ErrorMathDomain},
+		{"xirr", `{"cashFlows":[{"date":"2025-01-01","amount":-100}]}`, ErrorInvalidParams},
+		{"xirr", `{"cashFlows":[{"date":"2025-13-01","amount":-100},{"date":"2026-01-01","amount":110}]}`, ErrorInvalidParams},
+		{"loan_payment", `{"principal":0,"rate":0.05,"periods":10}`, ErrorInvalidParams},
+		{"loan_payment", `{"principal":100,"rate":0.05,"periods":10,"balloon":200}`, ErrorInvalidParams},
+		{"compound_interest", `{"principal":100,"rate":0.05,"years":-1}`, ErrorInvalidParams},
+		{"depreciation", `{"cost":100,"salvage":200,"life":5}`, ErrorInvalidParams},
+		{"depreciation", `{"cost":100,"life":5,"method":"declining_balance","factor":0}`, ErrorInvalidParams},
+	}
+
+	for _, tt := range tests {
+		_, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err == nil || err.Code != tt.code {
+			t.Errorf("%s %s: expected error code %d, got %v", tt.name, tt.args, tt.code, err)
+		}
+	}
+}
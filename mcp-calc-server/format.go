@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Notation は数値の表記法を表します
+type Notation string
+
+const (
+	NotationFixed       Notation = "fixed"       // 小数点以下 digits 桁（既定は6桁）
+	NotationSignificant Notation = "significant" // 有効数字 digits 桁の位取り表記
+	NotationScientific  Notation = "scientific"  // 有効数字 digits 桁の指数表記（1.23e+20）
+	NotationEngineering Notation = "engineering" // 指数が3の倍数の指数表記（123e+18）
+)
+
+// NumberBase は整数を表示する基数を表します
+type NumberBase string
+
+const (
+	BaseDecimal NumberBase = "decimal"
+	BaseHex     NumberBase = "hex"
+	BaseBinary  NumberBase = "binary"
+	BaseOctal   NumberBase = "octal"
+)
+
+// 表記の既定値と制限
+const (
+	defaultFixedDigits       = 6
+	defaultSignificantDigits = 6
+	maxFixedDigits           = 30
+	maxSignificantDigits     = 17 // float64 で意味のある有効数字の上限
+	maxExactInteger          = 1 << 53
+)
+
+// numberLocale はロケールごとの記号を表します
+type numberLocale struct {
+	decimal string // 小数点
+	group   string // 3桁ごとの区切り
+}
+
+// numberLocales は対応しているロケールです。ja-JP も en-US と同じ記号を使います
+var numberLocales = map[string]numberLocale{
+	"en-US": {decimal: ".", group: ","},
+	"ja-JP": {decimal: ".", group: ","},
+}
+
+// Grouping は整数部の桁の区切り方です。JSON では true（thousands）と false（none）も受け付けます
+type Grouping string
+
+const (
+	GroupingNone      Grouping = "none"
+	GroupingThousands Grouping = "thousands" // 3桁ごとにロケールの区切り記号で区切る（1,234,567）
+	GroupingMyriad    Grouping = "myriad"    // 4桁ごとに万・億・兆などの単位で区切る（123万4567）
+)
+
+// UnmarshalJSON は真偽値または区切り方の名前を読み込みます
+func (g *Grouping) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*g = GroupingNone
+		if b {
+			*g = GroupingThousands
+		}
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("grouping must be a boolean or one of %q, %q, %q", GroupingNone, GroupingThousands, GroupingMyriad)
+	}
+	*g = Grouping(name)
+	return nil
+}
+
+// myriadUnits は4桁ごとの単位です
+var myriadUnits = []string{
+	"", "万", "億", "兆", "京", "垓", "𥝱", "穣", "溝", "澗",
+	"正", "載", "極", "恒河沙", "阿僧祇", "那由他", "不可思議", "無量大数",
+}
+
+// FormatOptions は数値の結果をテキストにする方法を表します。
+// ツール呼び出しごとの format 引数、initialize 時の experimental.calculator.format、
+// またはサーバーの既定値として指定できます。未指定の項目は既定値を引き継ぎます
+type FormatOptions struct {
+	Notation Notation   `json:"notation,omitempty"`
+	Digits   *int       `json:"digits,omitempty"`   // fixed では小数点以下の桁数、それ以外は有効数字
+	Grouping Grouping   `json:"grouping,omitempty"` // 整数部の桁の区切り方
+	Locale   string     `json:"locale,omitempty"`
+	Base     NumberBase `json:"base,omitempty"` // 整数の結果に使う基数。整数でない値は10進で表示する
+}
+
+// formatSchema はツールの format 引数の入力スキーマです
+var formatSchema = map[string]any{
+	"type":        "object",
+	"description": "How to format numbers in the text result. Defaults come from the server",
+	"properties": map[string]any{
+		"notation": map[string]any{
+			"type": "string",
+			"enum": []Notation{NotationFixed, NotationSignificant, NotationScientific, NotationEngineering},
+		},
+		"digits": map[string]any{
+			"type":        "integer",
+			"minimum":     0,
+			"maximum":     maxFixedDigits,
+			"description": "Decimal places for fixed notation, significant digits otherwise",
+		},
+		"grouping": map[string]any{
+			"type":        []string{"boolean", "string"},
+			"enum":        []any{true, false, GroupingNone, GroupingThousands, GroupingMyriad},
+			"description": "Group digits in the integer part: true or thousands separates thousands (1,234,567), myriad uses Japanese myriad units (123万4567)",
+		},
+		"locale": map[string]any{
+			"type": "string",
+			"enum": []string{"en-US", "ja-JP"},
+		},
+		"base": map[string]any{
+			"type":        "string",
+			"enum":        []NumberBase{BaseDecimal, BaseHex, BaseBinary, BaseOctal},
+			"description": "Base for integer results; other values stay decimal",
+		},
+	},
+}
+
+// validate は表記の指定が正しいかを検証します
+func (o FormatOptions) validate() *Error {
+	invalid := func(format string, args ...any) *Error {
+		return &Error{Code: ErrorInvalidParams, Message: "Invalid format", Data: fmt.Sprintf(format, args...)}
+	}
+	switch o.Notation {
+	case "", NotationFixed:
+		if o.Digits != nil && (*o.Digits < 0 || *o.Digits > maxFixedDigits) {
+			return invalid("digits must be between 0 and %d for fixed notation", maxFixedDigits)
+		}
+	case NotationSignificant, NotationScientific, NotationEngineering:
+		if o.Digits != nil && (*o.Digits < 1 || *o.Digits > maxSignificantDigits) {
+			return invalid("digits must be between 1 and %d for %s notation", maxSignificantDigits, o.Notation)
+		}
+	default:
+		return invalid("unknown notation %q", o.Notation)
+	}
+	if _, ok := numberLocales[o.Locale]; o.Locale != "" && !ok {
+		return invalid("unknown locale %q", o.Locale)
+	}
+	switch o.Grouping {
+	case "", GroupingNone, GroupingThousands, GroupingMyriad:
+	default:
+		return invalid("unknown grouping %q", o.Grouping)
+	}
+	switch o.Base {
+	case "", BaseDecimal, BaseHex, BaseBinary, BaseOctal:
+	default:
+		return invalid("unknown base %q", o.Base)
+	}
+	return nil
+}
+
+// merge は呼び出しごとの指定を優先して既定値と合成します
+func (o FormatOptions) merge(defaults FormatOptions) FormatOptions {
+	if o.Notation == "" {
+		o.Notation = defaults.Notation
+		if o.Digits == nil {
+			o.Digits = defaults.Digits
+		}
+	}
+	if o.Grouping == "" {
+		o.Grouping = defaults.Grouping
+	}
+	if o.Locale == "" {
+		o.Locale = defaults.Locale
+	}
+	if o.Base == "" {
+		o.Base = defaults.Base
+	}
+	return o
+}
+
+// withFormat はサーバーの既定の表記を設定します
+func withFormat(opts FormatOptions) serverOption {
+	return func(s *Server) error {
+		if err := opts.validate(); err != nil {
+			return fmt.Errorf("%s: %v", err.Message, err.Data)
+		}
+		s.format = opts
+		return nil
+	}
+}
+
+// format は数値を表記の指定に従ってテキストにします
+func (o FormatOptions) format(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	if text, ok := o.formatInteger(v); ok {
+		return text
+	}
+
+	switch o.Notation {
+	case NotationSignificant:
+		// 整数部が有効数字より長い場合も下の桁を丸めるため、一度指数表記で丸める
+		digits := o.digits(defaultSignificantDigits)
+		rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'e', digits-1, 64), 64)
+		decimals := max(0, digits-1-decimalExponent(v, digits))
+		return o.localize(strconv.FormatFloat(rounded, 'f', decimals, 64))
+	case NotationScientific:
+		return o.localize(strconv.FormatFloat(v, 'e', o.digits(defaultSignificantDigits)-1, 64))
+	case NotationEngineering:
+		digits := o.digits(defaultSignificantDigits)
+		exp := decimalExponent(v, digits)
+		eng := int(math.Floor(float64(exp)/3)) * 3
+		mantissa := v / math.Pow10(eng)
+		decimals := max(0, digits-1-(exp-eng))
+		return o.localize(strconv.FormatFloat(mantissa, 'f', decimals, 64)) + fmt.Sprintf("e%+03d", eng)
+	default:
+		return o.localize(strconv.FormatFloat(v, 'f', o.digits(defaultFixedDigits), 64))
+	}
+}
+
+// formatInteger は基数の指定がある場合に整数を 0x / 0b / 0o 付きで表記します
+func (o FormatOptions) formatInteger(v float64) (string, bool) {
+	var base int
+	var prefix string
+	switch o.Base {
+	case BaseHex:
+		base, prefix = 16, "0x"
+	case BaseBinary:
+		base, prefix = 2, "0b"
+	case BaseOctal:
+		base, prefix = 8, "0o"
+	default:
+		return "", false
+	}
+	// float64 で正確に表せる整数だけを変換する
+	if v != math.Trunc(v) || math.Abs(v) > maxExactInteger {
+		return "", false
+	}
+	sign := ""
+	if v < 0 {
+		sign = "-"
+	}
+	return sign + prefix + strconv.FormatUint(uint64(math.Abs(v)), base), true
+}
+
+func (o FormatOptions) digits(fallback int) int {
+	if o.Digits == nil {
+		return fallback
+	}
+	return *o.Digits
+}
+
+// localize は "-1234.5" のような表記にロケールの小数点と桁区切りを適用します
+func (o FormatOptions) localize(s string) string {
+	loc, ok := numberLocales[o.Locale]
+	if !ok {
+		loc = numberLocales["en-US"]
+	}
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	if exponent != "" {
+		exponent = "e" + exponent
+	}
+	sign := ""
+	if strings.HasPrefix(mantissa, "-") {
+		sign, mantissa = "-", mantissa[1:]
+	}
+	intPart, frac, hasFrac := strings.Cut(mantissa, ".")
+	if exponent == "" {
+		switch o.Grouping {
+		case GroupingThousands:
+			intPart = groupThousands(intPart, loc.group)
+		case GroupingMyriad:
+			intPart = groupMyriads(intPart)
+			// 1億.5 とならないよう、単位で終わる場合は 0 を補う
+			if last := intPart[len(intPart)-1]; hasFrac && (last < '0' || last > '9') {
+				intPart += "0"
+			}
+		}
+	}
+	if hasFrac {
+		return sign + intPart + loc.decimal + frac + exponent
+	}
+	return sign + intPart + exponent
+}
+
+// groupThousands は整数の数字列を3桁ごとに区切ります
+func groupThousands(digits, sep string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// groupMyriads は整数の数字列を4桁ごとに万・億・兆などの単位で区切ります（123456789 なら 1億2345万6789）。
+// 0 の区切りは省き、無量大数を超える桁は無量大数の前にまとめて置きます
+func groupMyriads(digits string) string {
+	if len(digits) <= 4 {
+		return digits
+	}
+	var b strings.Builder
+	head := (len(digits)-1)%4 + 1
+	if top := 4 * (len(myriadUnits) - 1); len(digits)-top > 4 {
+		head = len(digits) - top
+	}
+	for start, end := 0, head; start < len(digits); start, end = end, end+4 {
+		if group := strings.TrimLeft(digits[start:end], "0"); group != "" {
+			b.WriteString(group)
+			b.WriteString(myriadUnits[(len(digits)-end)/4])
+		}
+	}
+	return b.String()
+}
+
+// decimalExponent は有効数字 digits 桁に丸めた後の10進の指数を返します（1234 なら 3）
+func decimalExponent(v float64, digits int) int {
+	if v == 0 {
+		return 0
+	}
+	s := strconv.FormatFloat(v, 'e', digits-1, 64)
+	exp, _ := strconv.Atoi(s[strings.IndexByte(s, 'e')+1:])
+	return exp
+}
+
+type formatKey struct{}
+
+// withNumberFormat は呼び出しで使う表記をコンテキストに設定します
+func withNumberFormat(ctx context.Context, opts FormatOptions) context.Context {
+	return context.WithValue(ctx, formatKey{}, opts)
+}
+
+// numberFormat は呼び出しで使う表記を返します
+func numberFormat(ctx context.Context) FormatOptions {
+	opts, _ := ctx.Value(formatKey{}).(FormatOptions)
+	return opts
+}
+
+// formatNumber は呼び出しの表記の指定に従って数値をテキストにします
+func formatNumber(ctx context.Context, v float64) string {
+	return numberFormat(ctx).format(v)
+}
+
+// callFormat はツールの引数の format を既定の表記と合成します
+func (s *Server) callFormat(args json.RawMessage) (FormatOptions, *Error) {
+	var params struct {
+		Format FormatOptions `json:"format"`
+	}
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &params); err != nil {
+			return FormatOptions{}, &Error{Code: ErrorInvalidParams, Message: "Invalid format", Data: err.Error()}
+		}
+	}
+	opts := params.Format.merge(s.format)
+	if err := opts.validate(); err != nil {
+		return FormatOptions{}, err
+	}
+	return opts, nil
+}
+
+// formatNumbers は数値の列を表記の指定に従ってテキストにします
+func formatNumbers(ctx context.Context, values []float64) []string {
+	opts := numberFormat(ctx)
+	texts := make([]string, len(values))
+	for i, v := range values {
+		texts[i] = opts.format(v)
+	}
+	return texts
+}
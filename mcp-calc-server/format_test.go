@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFormatNumber(t *testing.T) {
+	digits := func(n int) *int { return &n }
+
+	tests := []struct {
+		value float64
+		opts  FormatOptions
+		want  string
+	}{
+		// 既定は従来どおり小数点以下6桁
+		{3, FormatOptions{}, "3.000000"},
+		{1e-9, FormatOptions{}, "0.000000"},
+		{2.5, FormatOptions{Notation: NotationFixed, Digits: digits(2)}, "2.50"},
+		{1e20, FormatOptions{Notation: NotationSignificant}, "100000000000000000000"},
+		{1e-9, FormatOptions{Notation: NotationSignificant, Digits: digits(3)}, "0.00000000100"},
+		{123.456, FormatOptions{Notation: NotationSignificant, Digits: digits(4)}, "123.5"},
+		{0, FormatOptions{Notation: NotationSignificant, Digits: digits(3)}, "0.00"},
+		{1e20, FormatOptions{Notation: NotationScientific}, "1.00000e+20"},
+		{-0.00012345, FormatOptions{Notation: NotationScientific, Digits: digits(3)}, "-1.23e-04"},
+		{12345.678, FormatOptions{Notation: NotationEngineering, Digits: digits(4)}, "12.35e+03"},
+		{0.000123, FormatOptions{Notation: NotationEngineering, Digits: digits(3)}, "123e-06"},
+		// 丸めで桁が繰り上がる場合
+		{999.96, FormatOptions{Notation: NotationEngineering, Digits: digits(4)}, "1.000e+03"},
+		{1234567.891, FormatOptions{Notation: NotationFixed, Digits: digits(2), Grouping: GroupingThousands}, "1,234,567.89"},
+		{-1234567, FormatOptions{Notation: NotationSignificant, Digits: digits(7), Grouping: GroupingThousands, Locale: "ja-JP"}, "-1,234,567"},
+		{1234567, FormatOptions{Notation: NotationSignificant, Digits: digits(7), Grouping: GroupingNone}, "1234567"},
+		// myriad は万・億の単位で区切り、0 の区切りは省く
+		{-1234567, FormatOptions{Notation: NotationSignificant, Digits: digits(7), Grouping: GroupingMyriad}, "-123万4567"},
+		{300000012.5, FormatOptions{Notation: NotationFixed, Digits: digits(1), Grouping: GroupingMyriad}, "3億12.5"},
+		{100000000.5, FormatOptions{Notation: NotationFixed, Digits: digits(1), Grouping: GroupingMyriad}, "1億0.5"},
+		{1e20, FormatOptions{Notation: NotationSignificant, Grouping: GroupingMyriad}, "1垓"},
+		{123, FormatOptions{Grouping: GroupingThousands}, "123.000000"},
+		{255, FormatOptions{Base: BaseHex}, "0xff"},
+		{-10, FormatOptions{Base: BaseBinary}, "-0b1010"},
+		{8, FormatOptions{Base: BaseOctal}, "0o10"},
+		// 整数でない値は10進のまま
+		{2.5, FormatOptions{Base: BaseHex}, "2.500000"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.opts.format(tt.value); got != tt.want {
+			t.Errorf("%v with %+v: expected %s, got %s", tt.value, tt.opts, tt.want, got)
+		}
+	}
+
+	// 無量大数を超える桁は無量大数の前にまとめる
+	if got := groupMyriads("15" + strings.Repeat("0", 72)); got != "150000無量大数" {
+		t.Errorf("expected digits above the largest unit to stay together, got %s", got)
+	}
+}
+
+func TestFormatOptionsValidate(t *testing.T) {
+	zero, big := 0, 18
+	invalid := []FormatOptions{
+		{Notation: "roman"},
+		{Notation: NotationScientific, Digits: &zero},
+		{Notation: NotationSignificant, Digits: &big},
+		{Locale: "fr-FR"},
+		{Grouping: "indian"},
+		{Base: "base64"},
+	}
+	for _, opts := range invalid {
+		if err := opts.validate(); err == nil || err.Code != ErrorInvalidParams {
+			t.Errorf("%+v: expected invalid params, got %v", opts, err)
+		}
+	}
+}
+
+func TestFormatPerCallAndDefaults(t *testing.T) {
+	s := newTestServer()
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"experimental":{"calculator":{"format":{"notation":"significant","digits":4,"grouping":true}}}}}}`)
+	roundTrip(t, s, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"multiply","arguments":{"a":1234.5678,"b":1000}}}`)
+	if !strings.Contains(out, `"text":"1,235,000"`) || !strings.Contains(out, `"value":1234567.8`) {
+		t.Errorf("expected session default format with numeric value, got %s", out)
+	}
+
+	// 呼び出しごとの指定が優先される
+	out = roundTrip(t, s, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"mean","arguments":{"values":[1,2],"format":{"notation":"scientific","digits":2}}}}`)
+	if !strings.Contains(out, `"text":"1.5e+00"`) {
+		t.Errorf("expected per-call format, got %s", out)
+	}
+	out = roundTrip(t, s, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"add","arguments":{"a":200,"b":55,"format":{"base":"hex"}}}}`)
+	if !strings.Contains(out, `"text":"0xff"`) {
+		t.Errorf("expected hex result, got %s", out)
+	}
+	out = roundTrip(t, s, `{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"add","arguments":{"a":1234567,"b":0,"format":{"notation":"fixed","digits":0,"grouping":"myriad"}}}}`)
+	if !strings.Contains(out, `"text":"123万4567"`) {
+		t.Errorf("expected myriad grouping, got %s", out)
+	}
+
+	// 整形したテキストでも数値として参照できる
+	if got := callText(t, s, "add", `{"a":"$1","b":"$3"}`); got != "1,235,000" {
+		t.Errorf("expected reference to formatted result, got %s", got)
+	}
+	entry, _ := s.memory.entry(3)
+	if entry.Value != "255" {
+		t.Errorf("expected numeric value for hex result, got %q", entry.Value)
+	}
+
+	out = roundTrip(t, s, `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2,"format":{"locale":"fr-FR"}}}}`)
+	if !strings.Contains(out, `"isError":true`) {
+		t.Errorf("expected invalid format error, got %s", out)
+	}
+}
+
+func TestServerDefaultFormat(t *testing.T) {
+	var opts FormatOptions
+	json.Unmarshal([]byte(`{"notation":"fixed","digits":2}`), &opts)
+	s, err := newServer(withFormat(opts))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := callText(t, s, "divide", `{"a":1,"b":3}`); got != "0.33" {
+		t.Errorf("expected server default format, got %s", got)
+	}
+	if _, err := newServer(withFormat(FormatOptions{Notation: "roman"})); err == nil {
+		t.Error("expected error for invalid default format")
+	}
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Streamable HTTP トランスポートの定数
+const (
+	mcpEndpoint           = "/mcp"
+	sessionIDHeader       = "Mcp-Session-Id"
+	protocolVersionHeader = "Mcp-Protocol-Version"
+	maxHTTPBodySize       = 4 << 20
+	sessionEventBuffer    = 64
+	sessionIdleTimeout    = 30 * time.Minute // この間リクエストのないセッションは破棄する
+	maxHTTPSessions       = 1000             // 超えると最も長く使われていないセッションを破棄する
+	sessionSweepInterval  = time.Minute
+)
+
+// httpSession は Mcp-Session-Id で識別される1つのセッションを表します
+type httpSession struct {
+	id     string
+	server *Server
+
+	// mu は streaming と dropping を保護します。メッセージの処理はサーバー側で排他制御するため、
+	// 実行中のツール呼び出しを別の POST の notifications/cancelled で止められます
+	mu sync.Mutex
+
+	// lastUsed は最後にリクエストを受けた時刻です。httpHandler.mu で保護します
+	lastUsed time.Time
+
+	// events は GET の SSE ストリームへ送るサーバーからのメッセージです。
+	// dropping はバッファが一杯でメッセージを破棄している間 true になります
+	events    chan []byte
+	streaming bool
+	dropping  bool
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// handle はセッションのサーバーでメッセージを処理します
+func (sess *httpSession) handle(ctx context.Context, msg json.RawMessage) any {
+	return sess.server.handleMessageContext(ctx, msg)
+}
+
+// enqueue はリクエストに関係しないサーバーからのメッセージを GET の SSE ストリームに積みます。
+// ストリームが開いていない場合は送り先がないので破棄します。バッファが一杯の場合も破棄し、
+// 破棄し始めたときだけ記録します
+func (sess *httpSession) enqueue(msg any) {
+	sess.mu.Lock()
+	streaming := sess.streaming
+	sess.mu.Unlock()
+	if !streaming {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding message: %v\n", err)
+		return
+	}
+	select {
+	case sess.events <- data:
+		sess.mu.Lock()
+		sess.dropping = false
+		sess.mu.Unlock()
+	default:
+		sess.mu.Lock()
+		first := !sess.dropping
+		sess.dropping = true
+		sess.mu.Unlock()
+		if first {
+			fmt.Fprintf(os.Stderr, "Dropping messages for session %s: event buffer full\n", sess.id)
+		}
+	}
+}
+
+func (sess *httpSession) close() {
+	sess.closeOnce.Do(func() { close(sess.closed) })
+}
+
+// httpHandler は MCP の Streamable HTTP トランスポートを実装します。
+// POST でリクエストを受け付け、GET で SSE ストリームを開き、DELETE でセッションを終了します
+type httpHandler struct {
+	newServer func() (*Server, error)
+
+	// idleTimeout と maxSessions はセッションの寿命と数の上限です。now はテストで時刻を差し替えるために使います
+	idleTimeout time.Duration
+	maxSessions int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+func newHTTPHandler(newServer func() (*Server, error)) *httpHandler {
+	return &httpHandler{
+		newServer:   newServer,
+		idleTimeout: sessionIdleTimeout,
+		maxSessions: maxHTTPSessions,
+		now:         time.Now,
+		sessions:    make(map[string]*httpSession),
+	}
+}
+
+// serveHTTP は addr で Streamable HTTP トランスポートを起動し、シグナルを受けるまで待ちます。
+// newServer はセッションごとに呼ばれます
+func serveHTTP(addr string, newServer func() (*Server, error)) error {
+	handler := newHTTPHandler(newServer)
+	mux := http.NewServeMux()
+	mux.Handle(mcpEndpoint, handler)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// 開いたままの SSE ストリームがシャットダウンを妨げないようにする
+	srv.RegisterOnShutdown(handler.closeAll)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 使われなくなったセッションを定期的に破棄する
+	go func() {
+		ticker := time.NewTicker(sessionSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				handler.sweep()
+			}
+		}
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	fmt.Fprintf(os.Stderr, "Listening on http://%s%s\n", addr, mcpEndpoint)
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// DNS リバインディング対策としてブラウザからのリクエストは Origin を検証する
+	if !allowedOrigin(r) {
+		writeHTTPError(w, http.StatusForbidden, ErrorInvalidRequest, "Origin not allowed")
+		return
+	}
+	if v := r.Header.Get(protocolVersionHeader); v != "" {
+		if _, ok := protocolFeatureTable[v]; !ok {
+			writeHTTPError(w, http.StatusBadRequest, ErrorInvalidRequest, fmt.Sprintf("Unsupported protocol version '%s'", v))
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodGet:
+		h.handleGet(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeHTTPError(w, http.StatusMethodNotAllowed, ErrorInvalidRequest, "Method not allowed")
+	}
+}
+
+// handlePost はクライアントからの JSON-RPC メッセージを処理します
+func (h *httpHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBodySize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeHTTPError(w, http.StatusRequestEntityTooLarge, ErrorInvalidRequest, "Request body too large")
+			return
+		}
+		writeHTTPError(w, http.StatusBadRequest, ErrorParseError, "Failed to read request body")
+		return
+	}
+
+	sid := r.Header.Get(sessionIDHeader)
+	if sid == "" {
+		// セッション ID がない場合は initialize で新しいセッションを開始する
+		req, rpcErr := decodeRequest(body)
+		if rpcErr != nil || req.Method != "initialize" {
+			writeHTTPError(w, http.StatusBadRequest, ErrorInvalidRequest, "Missing "+sessionIDHeader+" header")
+			return
+		}
+		sess, err := h.newSession()
+		if err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, ErrorInternalError, err.Error())
+			return
+		}
+		reply := sess.handle(context.Background(), body)
+		if sess.server.currentState() != stateUninitialized {
+			h.add(sess)
+			w.Header().Set(sessionIDHeader, sess.id)
+		}
+		writeReply(w, reply)
+		return
+	}
+
+	sess := h.lookup(sid)
+	if sess == nil {
+		writeHTTPError(w, http.StatusNotFound, ErrorInvalidRequest, "Session not found")
+		return
+	}
+	// ツールの呼び出しには SSE で応答し、その呼び出しの進捗やログを同じストリームで送る
+	if acceptsEventStream(r) && hasToolCall(body) {
+		streamReply(w, sess, body)
+		return
+	}
+	reply := sess.handle(context.Background(), body)
+	if sess.server.currentState() == stateShuttingDown {
+		h.remove(sid)
+	}
+	writeReply(w, reply)
+}
+
+// hasToolCall はメッセージが tools/call のリクエストか、それを含むバッチであるかを返します
+func hasToolCall(msg []byte) bool {
+	if _, ok := toolCallRequest(msg); ok {
+		return true
+	}
+	batch, err := decodeBatch(msg)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(batch, func(raw json.RawMessage) bool {
+		_, ok := toolCallRequest(raw)
+		return ok
+	})
+}
+
+// streamReply はメッセージを処理し、SSE のストリームで応答します。
+// 処理中に送る進捗やログの通知を書き込み、最後に応答を書き込んでストリームを閉じます
+func streamReply(w http.ResponseWriter, sess *httpSession, body []byte) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeReply(w, sess.handle(context.Background(), body))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// 通知はツールを実行する goroutine から届くので書き込みを直列化し、応答の後は書き込まない
+	var mu sync.Mutex
+	open := true
+	send := func(msg any) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !open {
+			return
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding message: %v\n", err)
+			return
+		}
+		writeEvent(w, flusher, data)
+	}
+	// キャンセルされたリクエストには応答せず、そのままストリームを閉じる
+	if reply := sess.handle(withSender(context.Background(), send), body); reply != nil {
+		send(reply)
+	}
+	mu.Lock()
+	open = false
+	mu.Unlock()
+}
+
+// handleGet はサーバーからクライアントへのメッセージを送る SSE ストリームを開きます
+func (h *httpHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		writeHTTPError(w, http.StatusNotAcceptable, ErrorInvalidRequest, "Accept must include text/event-stream")
+		return
+	}
+	sess := h.lookup(r.Header.Get(sessionIDHeader))
+	if sess == nil {
+		writeHTTPError(w, http.StatusNotFound, ErrorInvalidRequest, "Session not found")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeHTTPError(w, http.StatusInternalServerError, ErrorInternalError, "Streaming not supported")
+		return
+	}
+
+	// セッションごとに開ける SSE ストリームは1本まで
+	sess.mu.Lock()
+	if sess.streaming {
+		sess.mu.Unlock()
+		writeHTTPError(w, http.StatusConflict, ErrorInvalidRequest, "Stream already open for this session")
+		return
+	}
+	sess.streaming = true
+	sess.mu.Unlock()
+	defer func() {
+		sess.mu.Lock()
+		sess.streaming = false
+		sess.mu.Unlock()
+		// ストリームを閉じた時点から待機時間を数える
+		h.mu.Lock()
+		sess.lastUsed = h.now()
+		h.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sess.closed:
+			return
+		case data := <-sess.events:
+			if err := writeEvent(w, flusher, data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleDelete はクライアントからの明示的なセッション終了を処理します
+func (h *httpHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sid := r.Header.Get(sessionIDHeader)
+	if h.lookup(sid) == nil {
+		writeHTTPError(w, http.StatusNotFound, ErrorInvalidRequest, "Session not found")
+		return
+	}
+	h.remove(sid)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newSession は新しいセッションとそのサーバーを生成します（まだ登録はしません）
+func (h *httpHandler) newSession() (*httpSession, error) {
+	server, err := h.newServer()
+	if err != nil {
+		return nil, err
+	}
+	sess := &httpSession{
+		id:     rand.Text(),
+		server: server,
+		events: make(chan []byte, sessionEventBuffer),
+		closed: make(chan struct{}),
+	}
+	sess.server.send = sess.enqueue
+	return sess, nil
+}
+
+// add はセッションを登録します。上限に達している場合は期限切れのセッションを破棄し、
+// それでも空きがなければ最も長く使われていないセッションを破棄します
+func (h *httpHandler) add(sess *httpSession) {
+	h.sweep()
+
+	h.mu.Lock()
+	var evicted *httpSession
+	if len(h.sessions) >= h.maxSessions {
+		for _, other := range h.sessions {
+			if evicted == nil || other.lastUsed.Before(evicted.lastUsed) {
+				evicted = other
+			}
+		}
+		delete(h.sessions, evicted.id)
+	}
+	sess.lastUsed = h.now()
+	h.sessions[sess.id] = sess
+	h.mu.Unlock()
+
+	if evicted != nil {
+		evicted.close()
+	}
+}
+
+// lookup はセッションを探し、最後に使われた時刻を更新します。期限切れのセッションは破棄します
+func (h *httpHandler) lookup(sid string) *httpSession {
+	if sid == "" {
+		return nil
+	}
+	h.mu.Lock()
+	sess, ok := h.sessions[sid]
+	if ok && h.expired(sess) {
+		delete(h.sessions, sid)
+		h.mu.Unlock()
+		sess.close()
+		return nil
+	}
+	if ok {
+		sess.lastUsed = h.now()
+	}
+	h.mu.Unlock()
+	return sess
+}
+
+// expired はセッションが idleTimeout より長く使われていないかを返します。
+// SSE ストリームを開いているセッションは使用中として扱います。h.mu を保持して呼び出します
+func (h *httpHandler) expired(sess *httpSession) bool {
+	sess.mu.Lock()
+	streaming := sess.streaming
+	sess.mu.Unlock()
+	return !streaming && h.now().Sub(sess.lastUsed) > h.idleTimeout
+}
+
+// sweep は期限切れのセッションをすべて破棄します
+func (h *httpHandler) sweep() {
+	var expired []*httpSession
+	h.mu.Lock()
+	for sid, sess := range h.sessions {
+		if h.expired(sess) {
+			delete(h.sessions, sid)
+			expired = append(expired, sess)
+		}
+	}
+	h.mu.Unlock()
+	for _, sess := range expired {
+		sess.close()
+	}
+}
+
+func (h *httpHandler) remove(sid string) {
+	h.mu.Lock()
+	sess, ok := h.sessions[sid]
+	delete(h.sessions, sid)
+	h.mu.Unlock()
+	if ok {
+		sess.close()
+	}
+}
+
+// closeAll はすべてのセッションを終了します
+func (h *httpHandler) closeAll() {
+	h.mu.Lock()
+	sessions := h.sessions
+	h.sessions = make(map[string]*httpSession)
+	h.mu.Unlock()
+	for _, sess := range sessions {
+		sess.close()
+	}
+}
+
+// allowedOrigin は Origin ヘッダーがローカルホストまたは同一オリジンであるかを検証します。
+// Origin を送らない（ブラウザ以外の）クライアントは許可します
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return slices.Contains([]string{"localhost", "127.0.0.1", "::1"}, u.Hostname()) || u.Host == r.Host
+}
+
+// acceptsEventStream はクライアントが SSE の応答を受け付けるかを返します
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// writeEvent は SSE のイベントを1つ書き込んで送り出します
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, data []byte) error {
+	if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// writeReply は handleMessage の結果を書き込みます。応答がない場合は 202 Accepted を返します
+func writeReply(w http.ResponseWriter, reply any) {
+	if reply == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, newErrorResponse(nil, &Error{
+		Code:    code,
+		Message: message,
+	}))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding response: %v\n", err)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHTTPServer(t *testing.T) (*httptest.Server, *httpHandler) {
+	t.Helper()
+	handler := newHTTPHandler(func() (*Server, error) { return newServer() })
+	ts := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		handler.closeAll()
+		ts.Close()
+	})
+	return ts, handler
+}
+
+func postMCP(t *testing.T, url, sid, body string) *http.Response {
+	t.Helper()
+	return postMCPAccept(t, url, sid, "application/json, text/event-stream", body)
+}
+
+// postMCPAccept は Accept ヘッダーを指定して POST します
+func postMCPAccept(t *testing.T, url, sid, accept, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if sid != "" {
+		req.Header.Set(sessionIDHeader, sid)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readEvents は SSE の応答からイベントの data を順に読み出します
+func readEvents(t *testing.T, resp *http.Response) []string {
+	t.Helper()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q: %s", ct, readBody(t, resp))
+	}
+	var events []string
+	for line := range strings.Lines(readBody(t, resp)) {
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	return events
+}
+
+func initializeHTTPSession(t *testing.T, url string) string {
+	t.Helper()
+	resp := postMCP(t, url, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, readBody(t, resp))
+	}
+	sid := resp.Header.Get(sessionIDHeader)
+	if sid == "" {
+		t.Fatal("expected session id header")
+	}
+	return sid
+}
+
+func TestHTTPSessionLifecycle(t *testing.T) {
+	ts, _ := newTestHTTPServer(t)
+	sid := initializeHTTPSession(t, ts.URL)
+
+	// 通知には 202 Accepted を返す
+	resp := postMCP(t, ts.URL, sid, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expected status 202 for notification, got %d", resp.StatusCode)
+	}
+
+	// SSE を受け付けるクライアントにはツールの呼び出しの応答を SSE で返す
+	resp = postMCP(t, ts.URL, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"add","arguments":{"a":"0.1","b":"0.2","precision":"exact"}}}`)
+	if events := readEvents(t, resp); len(events) != 1 || events[0] != `{"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"0.3"},{"type":"text","text":"Stored as $1"}]},"id":2}` {
+		t.Errorf("unexpected tools/call response: %q", events)
+	}
+	resp = postMCPAccept(t, ts.URL, sid, "application/json", `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2,"precision":"exact"}}}`)
+	if body := readBody(t, resp); body != `{"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"3"},{"type":"text","text":"Stored as $2"}]},"id":3}` {
+		t.Errorf("unexpected tools/call response: %s", body)
+	}
+
+	// セッションを終了すると以降のリクエストは 404 になる
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	req.Header.Set(sessionIDHeader, sid)
+	delResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	delResp.Body.Close()
+	if delResp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status 204 for delete, got %d", delResp.StatusCode)
+	}
+	resp = postMCP(t, ts.URL, sid, `{"jsonrpc":"2.0","id":3,"method":"ping"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPSessionsAreIndependent(t *testing.T) {
+	ts, handler := newTestHTTPServer(t)
+	first := initializeHTTPSession(t, ts.URL)
+	second := initializeHTTPSession(t, ts.URL)
+	if first == second {
+		t.Fatal("expected distinct session ids")
+	}
+	if n := len(handler.sessions); n != 2 {
+		t.Errorf("expected 2 sessions, got %d", n)
+	}
+}
+
+func TestHTTPRejectsRequestsWithoutSession(t *testing.T) {
+	ts, _ := newTestHTTPServer(t)
+
+	resp := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 without session, got %d", resp.StatusCode)
+	}
+	resp = postMCP(t, ts.URL, "unknown", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown session, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPRejectsForeignOrigin(t *testing.T) {
+	ts, _ := newTestHTTPServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	req.Header.Set("Origin", "https://evil.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPEventStream(t *testing.T) {
+	ts, handler := newTestHTTPServer(t)
+	sid := initializeHTTPSession(t, ts.URL)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(sessionIDHeader, sid)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	// サーバーからのメッセージが SSE イベントとして届く
+	handler.lookup(sid).server.send(map[string]string{"jsonrpc": "2.0", "method": "notifications/test"})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed before event arrived")
+			}
+			if line == `data: {"jsonrpc":"2.0","method":"notifications/test"}` {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+func TestHTTPToolCallNotificationsOnPostStream(t *testing.T) {
+	ts, handler := newTestHTTPServer(t)
+	sid := initializeHTTPSession(t, ts.URL)
+	postMCP(t, ts.URL, sid, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	postMCP(t, ts.URL, sid, `{"jsonrpc":"2.0","id":2,"method":"logging/setLevel","params":{"level":"info"}}`)
+
+	// 呼び出し中のログは GET のストリームではなく POST の SSE 応答に、応答より先に届く
+	resp := postMCP(t, ts.URL, sid, `[{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}}]`)
+	events := readEvents(t, resp)
+	if len(events) != 2 || !strings.Contains(events[0], `"method":"notifications/message"`) || !strings.Contains(events[0], `"message":"Called add"`) ||
+		!strings.HasPrefix(events[1], `[{"jsonrpc":"2.0","result":`) {
+		t.Errorf("expected log then batch response, got %q", events)
+	}
+
+	// GET のストリームがなければ他の通知は積まずに捨てる
+	sess := handler.lookup(sid)
+	for range 2 * sessionEventBuffer {
+		sess.server.notify("notifications/test", nil)
+	}
+	if n := len(sess.events); n != 0 {
+		t.Errorf("expected no queued messages without a stream, got %d", n)
+	}
+}
+
+func TestHTTPProgressOnPostStream(t *testing.T) {
+	ts, _ := newTestHTTPServer(t)
+	sid := initializeHTTPSession(t, ts.URL)
+	postMCP(t, ts.URL, sid, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	// POST だけを使うクライアントにも進捗の通知が応答のストリームで届く
+	resp := postMCP(t, ts.URL, sid, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"factorial","arguments":{"n":1000},"_meta":{"progressToken":"fact-1"}}}`)
+	events := readEvents(t, resp)
+	if len(events) < 3 {
+		t.Fatalf("expected progress events before the response, got %q", events)
+	}
+	last := -1.0
+	for _, data := range events[:len(events)-1] {
+		var n struct {
+			Method string         `json:"method"`
+			Params ProgressParams `json:"params"`
+		}
+		if err := json.Unmarshal([]byte(data), &n); err != nil || n.Method != "notifications/progress" || string(n.Params.ProgressToken) != `"fact-1"` {
+			t.Fatalf("unexpected event: %s", data)
+		}
+		if n.Params.Progress <= last {
+			t.Errorf("progress did not increase: %v after %v", n.Params.Progress, last)
+		}
+		last = n.Params.Progress
+	}
+	if last != 1000 {
+		t.Errorf("expected final progress 1000, got %v", last)
+	}
+	if final := events[len(events)-1]; !strings.Contains(final, `"text":"402387260077`) || !strings.HasSuffix(final, `"id":2}`) {
+		t.Errorf("expected the response as the last event, got %s", final)
+	}
+}
+
+func TestHTTPSessionExpires(t *testing.T) {
+	ts, handler := newTestHTTPServer(t)
+	now := time.Now()
+	handler.mu.Lock()
+	handler.now = func() time.Time { return now }
+	handler.mu.Unlock()
+
+	idle := initializeHTTPSession(t, ts.URL)
+	active := initializeHTTPSession(t, ts.URL)
+
+	// 使われているセッションは期限が延びる
+	now = now.Add(sessionIdleTimeout - time.Minute)
+	if resp := postMCP(t, ts.URL, active, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	now = now.Add(2 * time.Minute)
+	handler.sweep()
+
+	if resp := postMCP(t, ts.URL, idle, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 for expired session, got %d", resp.StatusCode)
+	}
+	if resp := postMCP(t, ts.URL, active, `{"jsonrpc":"2.0","id":3,"method":"ping"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected active session to survive, got %d", resp.StatusCode)
+	}
+	if n := len(handler.sessions); n != 1 {
+		t.Errorf("expected 1 session, got %d", n)
+	}
+}
+
+func TestHTTPSessionLimit(t *testing.T) {
+	ts, handler := newTestHTTPServer(t)
+	now := time.Now()
+	handler.mu.Lock()
+	handler.maxSessions = 2
+	handler.now = func() time.Time { return now }
+	handler.mu.Unlock()
+
+	oldest := initializeHTTPSession(t, ts.URL)
+	now = now.Add(time.Second)
+	second := initializeHTTPSession(t, ts.URL)
+	now = now.Add(time.Second)
+	third := initializeHTTPSession(t, ts.URL)
+
+	// 上限を超えると最も長く使われていないセッションが破棄される
+	if n := len(handler.sessions); n != 2 {
+		t.Errorf("expected 2 sessions, got %d", n)
+	}
+	if resp := postMCP(t, ts.URL, oldest, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected oldest session to be evicted, got %d", resp.StatusCode)
+	}
+	for _, sid := range []string{second, third} {
+		if resp := postMCP(t, ts.URL, sid, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status 200, got %d", resp.StatusCode)
+		}
+	}
+}
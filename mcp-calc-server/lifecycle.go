@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+)
+
+// lifecycleState は MCP のライフサイクルにおけるサーバーの状態を表します
+type lifecycleState int
+
+const (
+	stateUninitialized lifecycleState = iota // initialize 待ち
+	stateInitializing                        // initialize 応答済み、notifications/initialized 待ち
+	stateReady                               // 通常運用
+	stateShuttingDown                        // shutdown 受信後、終了待ち
+)
+
+func (st lifecycleState) String() string {
+	switch st {
+	case stateUninitialized:
+		return "uninitialized"
+	case stateInitializing:
+		return "initializing"
+	case stateReady:
+		return "ready"
+	case stateShuttingDown:
+		return "shutting-down"
+	default:
+		return fmt.Sprintf("lifecycleState(%d)", int(st))
+	}
+}
+
+// checkLifecycle は現在の状態でメソッドを受け付けられるかを検証します
+func (s *Server) checkLifecycle(method string) *Error {
+	// ping はどの状態でも受け付ける
+	if method == "ping" {
+		return nil
+	}
+
+	switch s.state {
+	case stateShuttingDown:
+		return &Error{
+			Code:    ErrorInvalidRequest,
+			Message: "Server is shutting down",
+		}
+	case stateUninitialized:
+		if method != "initialize" {
+			return &Error{
+				Code:    ErrorServerNotInitialized,
+				Message: "Server not initialized",
+			}
+		}
+	default:
+		if method == "initialize" {
+			return &Error{
+				Code:    ErrorInvalidRequest,
+				Message: "Server already initialized",
+			}
+		}
+	}
+	return nil
+}
+
+// handleNotification は通知を処理します。通知には応答を返しません
+func (s *Server) handleNotification(req Request) {
+	switch req.Method {
+	case "notifications/initialized":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.state != stateInitializing {
+			s.log(LevelWarning, "server", fmt.Sprintf("Ignoring %s in state %s", req.Method, s.state), nil)
+			return
+		}
+		s.state = stateReady
+	case "notifications/cancelled":
+		s.handleCancelled(req.Params)
+	default:
+		// 未知の通知は仕様に従い黙って無視する
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLifecycle(t *testing.T) {
+	s := newTestServer()
+
+	// initialize 前のリクエストは拒否される
+	if out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`); out != `{"jsonrpc":"2.0","error":{"code":-32004,"message":"Server not initialized"},"id":1}` {
+		t.Errorf("unexpected response before initialize: %s", out)
+	}
+
+	// ping はいつでも受け付ける
+	if out := roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); out != `{"jsonrpc":"2.0","result":{},"id":2}` {
+		t.Errorf("unexpected ping response: %s", out)
+	}
+
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":3,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+	if !strings.Contains(out, `"instructions":"This server is a calculator.`) {
+		t.Errorf("expected server instructions, got %s", out)
+	}
+	if s.state != stateInitializing {
+		t.Fatalf("expected state %s, got %s", stateInitializing, s.state)
+	}
+
+	// 通知には応答しない
+	if out := roundTrip(t, s, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); out != "" {
+		t.Errorf("expected no response to notification, got %s", out)
+	}
+	if s.state != stateReady {
+		t.Fatalf("expected state %s, got %s", stateReady, s.state)
+	}
+	if out := roundTrip(t, s, `{"jsonrpc":"2.0","method":"notifications/unknown"}`); out != "" {
+		t.Errorf("expected unknown notification to be ignored, got %s", out)
+	}
+
+	// 二重の initialize は拒否される
+	if out := roundTrip(t, s, `{"jsonrpc":"2.0","id":4,"method":"initialize","params":{}}`); out != `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Server already initialized"},"id":4}` {
+		t.Errorf("unexpected response to second initialize: %s", out)
+	}
+
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":5,"method":"shutdown"}`)
+	if s.state != stateShuttingDown {
+		t.Fatalf("expected state %s, got %s", stateShuttingDown, s.state)
+	}
+	if out := roundTrip(t, s, `{"jsonrpc":"2.0","id":6,"method":"tools/list"}`); out != `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Server is shutting down"},"id":6}` {
+		t.Errorf("unexpected response after shutdown: %s", out)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// LoggingLevel は RFC 5424 の syslog の重大度に対応するログレベルを表します
+type LoggingLevel string
+
+const (
+	LevelDebug     LoggingLevel = "debug"
+	LevelInfo      LoggingLevel = "info"
+	LevelNotice    LoggingLevel = "notice"
+	LevelWarning   LoggingLevel = "warning"
+	LevelError     LoggingLevel = "error"
+	LevelCritical  LoggingLevel = "critical"
+	LevelAlert     LoggingLevel = "alert"
+	LevelEmergency LoggingLevel = "emergency"
+)
+
+// loggingLevels は重大度の低い順のログレベルです
+var loggingLevels = []LoggingLevel{
+	LevelDebug, LevelInfo, LevelNotice, LevelWarning, LevelError, LevelCritical, LevelAlert, LevelEmergency,
+}
+
+// defaultStderrLevel は標準エラー出力に書き出す既定のログレベルです
+const defaultStderrLevel = LevelWarning
+
+// severity はログレベルの重大度を返します。未知のレベルは -1 です
+func (l LoggingLevel) severity() int {
+	return slices.Index(loggingLevels, l)
+}
+
+// parseLoggingLevel はログレベルの名前を検証します
+func parseLoggingLevel(s string) (LoggingLevel, error) {
+	l := LoggingLevel(strings.ToLower(s))
+	if l.severity() < 0 {
+		return "", fmt.Errorf("unknown log level %q", s)
+	}
+	return l, nil
+}
+
+// LoggingCapability はサーバーがクライアントにログを送れることを示します
+type LoggingCapability struct{}
+
+// SetLevelParams は logging/setLevel のパラメータを表します
+type SetLevelParams struct {
+	Level LoggingLevel `json:"level"`
+}
+
+// LoggingMessageParams は notifications/message のパラメータを表します
+type LoggingMessageParams struct {
+	Level  LoggingLevel `json:"level"`
+	Logger string       `json:"logger,omitempty"`
+	Data   any          `json:"data"`
+}
+
+// serverLogger はセッションごとのログの送信先を管理します。
+// クライアントが logging/setLevel でレベルを指定するまでは notifications/message を送らず、
+// 標準エラー出力（フォールバック）にだけ書き出します
+type serverLogger struct {
+	// mu はツールを並行して実行している間もレベルの変更と書き出しを安全にします
+	mu          *sync.Mutex
+	clientLevel LoggingLevel // 空の場合はクライアントに送らない
+	stderrLevel LoggingLevel
+	stderr      io.Writer
+}
+
+func newServerLogger() serverLogger {
+	return serverLogger{
+		mu:          new(sync.Mutex),
+		stderrLevel: defaultStderrLevel,
+		stderr:      os.Stderr,
+	}
+}
+
+// withLogLevel は標準エラー出力に書き出すログレベルを設定します
+func withLogLevel(level string) serverOption {
+	return func(s *Server) error {
+		if level == "" {
+			return nil
+		}
+		l, err := parseLoggingLevel(level)
+		if err != nil {
+			return err
+		}
+		s.logger.stderrLevel = l
+		return nil
+	}
+}
+
+// log はログを記録します。data には message と付加情報を含む構造化データを渡します
+func (s *Server) log(level LoggingLevel, logger string, message string, fields map[string]any) {
+	s.logContext(context.Background(), level, logger, message, fields)
+}
+
+// logContext はリクエストの処理中のログを記録します。クライアントへの通知は ctx の送信先に送ります
+func (s *Server) logContext(ctx context.Context, level LoggingLevel, logger string, message string, fields map[string]any) {
+	data := map[string]any{"message": message}
+	for k, v := range fields {
+		data[k] = v
+	}
+
+	s.logger.mu.Lock()
+	defer s.logger.mu.Unlock()
+	sev := level.severity()
+	if s.logger.clientLevel != "" && sev >= s.logger.clientLevel.severity() {
+		s.notifyContext(ctx, "notifications/message", LoggingMessageParams{
+			Level:  level,
+			Logger: logger,
+			Data:   data,
+		})
+	}
+	if sev >= s.logger.stderrLevel.severity() {
+		line := message
+		if len(fields) > 0 {
+			extra, _ := json.Marshal(fields)
+			line += " " + string(extra)
+		}
+		fmt.Fprintf(s.logger.stderr, "%s [%s] %s\n", strings.ToUpper(string(level)), logger, line)
+	}
+}
+
+// handleSetLevel は logging/setLevel を処理します
+func (s *Server) handleSetLevel(raw json.RawMessage) (any, *Error) {
+	var params SetLevelParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Invalid params", Data: err.Error()}
+	}
+	level, err := parseLoggingLevel(string(params.Level))
+	if err != nil {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: err.Error(),
+			Data:    loggingLevels,
+		}
+	}
+	s.logger.mu.Lock()
+	s.logger.clientLevel = level
+	s.logger.mu.Unlock()
+	return struct{}{}, nil
+}
+
+// logResponse はリクエストの処理結果を記録します。エラーは notice、内部エラーは error として記録します
+func (s *Server) logResponse(ctx context.Context, req Request, resp Response) {
+	var id any
+	_ = json.Unmarshal(req.ID, &id)
+	if resp.Error == nil {
+		s.logContext(ctx, LevelDebug, "server", "Handled "+req.Method, map[string]any{"method": req.Method, "id": id})
+		return
+	}
+	level := LevelNotice
+	if resp.Error.Code == ErrorInternalError {
+		level = LevelError
+	}
+	s.logContext(ctx, level, "server", fmt.Sprintf("%s failed: %s", req.Method, resp.Error.Message), map[string]any{
+		"method": req.Method,
+		"id":     id,
+		"code":   resp.Error.Code,
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// captureNotifications はサーバーから送られた通知を記録します
+func captureNotifications(s *Server) *[]Notification {
+	var sent []Notification
+	s.send = func(msg any) {
+		if n, ok := msg.(Notification); ok {
+			sent = append(sent, n)
+		}
+	}
+	return &sent
+}
+
+func TestSetLevel(t *testing.T) {
+	s := newReadyServer(t)
+	sent := captureNotifications(s)
+
+	// setLevel の前はクライアントにログを送らない
+	callText(t, s, "add", `{"a":1,"b":2}`)
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}}`)
+	if len(*sent) != 0 {
+		t.Fatalf("expected no log messages before setLevel, got %v", *sent)
+	}
+
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"logging/setLevel","params":{"level":"info"}}`)
+	if out != `{"jsonrpc":"2.0","result":{},"id":2}` {
+		t.Fatalf("unexpected setLevel response: %s", out)
+	}
+
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"divide","arguments":{"a":1,"b":0}}}`)
+	var levels []string
+	for _, n := range *sent {
+		if n.Method != "notifications/message" {
+			t.Errorf("unexpected notification %s", n.Method)
+			continue
+		}
+		p := n.Params.(LoggingMessageParams)
+		levels = append(levels, string(p.Level)+":"+p.Logger)
+	}
+	// debug の「Handled ...」は info 未満なので送られない。ツールのエラーは isError の結果なので notice にならない
+	if got := strings.Join(levels, ","); got != "info:tools" {
+		t.Errorf("expected tool timing message, got %s", got)
+	}
+
+	data := (*sent)[0].Params.(LoggingMessageParams).Data.(map[string]any)
+	if data["tool"] != "divide" || data["error"] != "Division by zero" {
+		t.Errorf("unexpected tool timing data: %v", data)
+	}
+	if _, ok := data["durationMs"].(float64); !ok {
+		t.Errorf("expected durationMs, got %v", data)
+	}
+}
+
+func TestSetLevelFiltersMessages(t *testing.T) {
+	s := newReadyServer(t)
+	sent := captureNotifications(s)
+
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"logging/setLevel","params":{"level":"error"}}`)
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"unknown"}`)
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}}`)
+	if len(*sent) != 0 {
+		t.Errorf("expected messages below error to be filtered, got %v", *sent)
+	}
+
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":4,"method":"logging/setLevel","params":{"level":"debug"}}`)
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":5,"method":"ping"}`)
+	// setLevel 自身の応答も新しいレベルで記録される
+	if len(*sent) != 2 || (*sent)[1].Params.(LoggingMessageParams).Data.(map[string]any)["method"] != "ping" {
+		t.Errorf("expected debug message for ping, got %v", *sent)
+	}
+}
+
+func TestSetLevelInvalid(t *testing.T) {
+	s := newReadyServer(t)
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"logging/setLevel","params":{"level":"verbose"}}`)
+	if !strings.Contains(out, `"code":-32602`) || !strings.Contains(out, `"data":["debug","info"`) {
+		t.Errorf("expected invalid params with levels, got %s", out)
+	}
+}
+
+func TestStderrFallback(t *testing.T) {
+	s, err := newServer(withLogLevel("notice"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	s.logger.stderr = &buf
+
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+
+	want := `NOTICE [server] tools/list failed: Server not initialized {"code":-32004,"id":1,"method":"tools/list"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if _, err := newServer(withLogLevel("loud")); err == nil {
+		t.Error("expected error for unknown log level")
+	}
+}
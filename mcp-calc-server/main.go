@@ -1,9 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"math"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // エラーコード定数（JSON-RPC 2.0仕様に準拠）
@@ -14,13 +23,44 @@ const (
 	ErrorInvalidParams  = -32602
 	ErrorInternalError  = -32603
 	ErrorDivideByZero   = -32000 // カスタムエラー
+	ErrorMathDomain     = -32001 // カスタムエラー（定義域外・オーバーフロー）
+
+	ErrorUnitMismatch         = -32003 // カスタムエラー（次元の異なる単位）
+	ErrorServerNotInitialized = -32004 // initialize 前のリクエスト（-32002 はリソース未検出に使う）
+	ErrorRequestCancelled     = -32800 // カスタムエラー（notifications/cancelled によるキャンセル）
+
+	// ErrorResourceNotFound は MCP 仕様で定められたリソース未検出のエラーです
+	ErrorResourceNotFound = -32002
 )
 
-// Server はMCPサーバーの状態を管理します
+// Server はMCPサーバーの状態を管理します。
+// ツールの呼び出しは並行して実行されるため、mu でセッションの状態を保護します
 type Server struct {
-	name        string
-	version     string
-	initialized bool
+	name    string
+	version string
+
+	// mu は state、protocolVersion、precision、format、subscriptions、inflight を保護します
+	mu    sync.Mutex
+	state lifecycleState
+	// protocolVersion は initialize で交渉したプロトコルバージョン
+	protocolVersion string
+	precision       PrecisionOptions // initialize 時に指定された既定の精度モード
+	format          FormatOptions    // 数値の既定の表記（サーバーの既定値に initialize 時の指定を合成したもの）
+	memory          *sessionMemory   // 計算履歴と名前付き変数
+	logger          serverLogger     // ログレベルと送信先
+	// tools はセッションで利用できるツールの登録簿です
+	tools *toolRegistry
+
+	// subscriptions は resources/subscribe で購読されているリソースの URI
+	subscriptions map[string]bool
+	// inflight は実行中のツール呼び出しのキャンセル関数をリクエスト ID ごとに保持します
+	inflight map[string]context.CancelFunc
+	// slots はツールを同時に実行できる数を制限するセマフォです
+	slots          chan struct{}
+	maxConcurrency int
+
+	// send はサーバーからクライアントへのメッセージを送信します。トランスポートが設定します
+	send func(msg any)
 }
 
 // Implementation はMCPの実装情報を表します
@@ -43,8 +83,26 @@ type RootsCapability struct {
 
 // ServerCapabilities はサーバーがサポートする機能を表します
 type ServerCapabilities struct {
-	Experimental map[string]any   `json:"experimental,omitempty"`
-	Tools        *ToolsCapability `json:"tools,omitempty"`
+	Experimental map[string]any         `json:"experimental,omitempty"`
+	Completions  *CompletionsCapability `json:"completions,omitempty"`
+	Logging      *LoggingCapability     `json:"logging,omitempty"`
+	Prompts      *PromptsCapability     `json:"prompts,omitempty"`
+	Resources    *ResourcesCapability   `json:"resources,omitempty"`
+	Tools        *ToolsCapability       `json:"tools,omitempty"`
+}
+
+// CompletionsCapability は引数の補完をサポートすることを示します
+type CompletionsCapability struct{}
+
+// PromptsCapability はプロンプト関連の機能をサポートするかを示します
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged"`
+}
+
+// ResourcesCapability はリソース関連の機能をサポートするかを示します
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe"`
+	ListChanged bool `json:"listChanged"`
 }
 
 // ToolsCapability はツール関連の機能をサポートするかを示します
@@ -57,7 +115,12 @@ type Request struct {
 	JsonRPC string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params"`
-	ID      any             `json:"id"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// IsNotification は id を持たない通知であるかを返します
+func (r *Request) IsNotification() bool {
+	return r.ID == nil
 }
 
 // Response は JSON-RPC 2.0 レスポンスを表します
@@ -66,6 +129,16 @@ type Response struct {
 	Result  any    `json:"result,omitempty"`
 	Error   *Error `json:"error,omitempty"`
 	ID      any    `json:"id"`
+
+	// cancelled はキャンセルされたため応答を送らないことを示します
+	cancelled bool
+}
+
+// Notification はサーバーからクライアントへ送る JSON-RPC 2.0 通知を表します
+type Notification struct {
+	JsonRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
 }
 
 // Error は JSON-RPC 2.0 エラーを表します
@@ -90,11 +163,72 @@ type InitializeResult struct {
 	Instructions    string             `json:"instructions,omitempty"`
 }
 
+// serverInstructions は initialize の結果でクライアントに伝えるサーバーの説明です
+const serverInstructions = "This server is a calculator. Its tools cover arithmetic with float, exact, decimal and big-float precision, " +
+	"expressions with physical units and unit conversion, statistics, number theory, complex numbers, matrices, " +
+	"root finding, differentiation and integration, dates and business days, finance, and bitwise and IEEE-754 operations. " +
+	"Tools with floating-point results accept a format argument for the text. " +
+	"Each result is stored as $1, $2, ... and can be used as an argument of later calls; " +
+	"store and recall keep named variables, and the calc://history resource lists past results. " +
+	"Prompts guide step-by-step explanations of calculations, loan amortization and unit conversions. " +
+	"Some tools may be disabled, so use tools/list to see what is available."
+
 // Tool はサーバーが提供するツールを表します
 type Tool struct {
 	Name        string         `json:"name"`
 	Description string         `json:"description,omitempty"`
 	InputSchema map[string]any `json:"inputSchema"`
+	// OutputSchema は structuredContent の形式です（2025-06-18 以降）
+	OutputSchema map[string]any   `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// outputSchema はオブジェクト形式の出力スキーマを生成します
+func outputSchema(properties map[string]any, required ...string) map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// valueOutputSchema は数値1つを返すツールの出力スキーマです
+var valueOutputSchema = outputSchema(map[string]any{
+	"value": map[string]any{"type": "number"},
+}, "value")
+
+// CallToolResult はツール呼び出しの結果を表します。
+// ツールの実行エラーはモデルが読んで修正できるよう、JSON-RPC のエラーではなく IsError を立てた結果として返します
+type CallToolResult struct {
+	Content           []TextContent `json:"content"`
+	StructuredContent any           `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
+
+	// value は履歴に記録する値です。空の場合は構造化データの value を記録し、
+	// どちらもなければ結果は $n で参照できません
+	value Number
+}
+
+// TextContent はテキストのコンテンツを表します
+type TextContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// ToolAnnotations はツールの振る舞いに関するヒントを表します
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	IdempotentHint  bool   `json:"idempotentHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
+}
+
+// calculatorToolAnnotations は計算ツールに共通のヒントです。
+// 計算は外部に副作用を持たず、同じ入力に対して常に同じ結果を返します
+var calculatorToolAnnotations = &ToolAnnotations{
+	ReadOnlyHint:   true,
+	IdempotentHint: true,
 }
 
 // ListToolsResult はツール一覧のレスポンスを表します
@@ -105,66 +239,348 @@ type ListToolsResult struct {
 
 // CalcParams は計算ツールのパラメータを表します
 type CalcParams struct {
-	A float64 `json:"a"`
-	B float64 `json:"b"`
+	A Number `json:"a"`
+	B Number `json:"b"`
+	PrecisionOptions
+}
+
+// EvaluateParams は式評価ツールのパラメータを表します
+type EvaluateParams struct {
+	Expression string `json:"expression"`
+	Units      bool   `json:"units,omitempty"` // 単位付きの値として評価する
+	To         string `json:"to,omitempty"`    // 単位付きの結果を変換する単位
 }
 
 // ToolRequest はツール呼び出しのリクエストを表します
 type ToolRequest struct {
 	Name      string          `json:"name"`
 	Arguments json.RawMessage `json:"arguments"`
+	Meta      *RequestMeta    `json:"_meta,omitempty"`
 }
 
 func main() {
-	server := &Server{
-		name:        "go-calculator-server",
-		version:     "0.0.1",
-		initialized: false,
+	transport := flag.String("transport", "stdio", "transport to serve on: stdio or http")
+	addr := flag.String("addr", "127.0.0.1:8080", "listen address for the http transport")
+	historyFile := flag.String("history-file", "", "persist calculation history and variables to this JSON file between runs (HTTP sessions start from a copy and are not saved)")
+	logLevel := flag.String("log-level", string(defaultStderrLevel), "minimum level of log messages written to stderr")
+	maxConcurrency := flag.Int("max-concurrency", runtime.NumCPU(), "maximum number of tool calls executed in parallel")
+	disableTools := flag.String("disable-tools", "", "comma-separated list of tools to disable")
+	numberFormat := flag.String("format", "", `default number format as JSON, e.g. {"notation":"significant","digits":4}`)
+	flag.Parse()
+
+	var format FormatOptions
+	if *numberFormat != "" {
+		if err := json.Unmarshal([]byte(*numberFormat), &format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -format: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var disabled []string
+	if *disableTools != "" {
+		disabled = strings.Split(*disableTools, ",")
+	}
+
+	memories, err := historyMemories(*historyFile, *transport)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	factory := func() (*Server, error) {
+		return newServer(withMemory(memories()), withLogLevel(*logLevel), withMaxConcurrency(*maxConcurrency), withDisabledTools(disabled...), withFormat(format))
+	}
+
+	switch *transport {
+	case "stdio":
+		var server *Server
+		if server, err = factory(); err == nil {
+			err = server.serveStdio(os.Stdin, os.Stdout)
+		}
+	case "http":
+		err = serveHTTP(*addr, factory)
+	default:
+		err = fmt.Errorf("unknown transport %q", *transport)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// serverOption はサーバー生成時の設定を表します
+type serverOption func(*Server) error
+
+// historyMemories は path の履歴ファイルを読み込み、セッションごとのメモリを返す関数を作ります。
+// stdio はセッションが1つなので、読み込んだメモリに記録してファイルへ保存します。
+// HTTP ではセッションごとにメモリを分け、各セッションは読み込んだ内容の複製から始めてファイルには保存しません。
+// path が空の場合は毎回空のメモリを返します
+func historyMemories(path, transport string) (func() *sessionMemory, error) {
+	if path == "" {
+		return newSessionMemory, nil
+	}
+	mem, err := loadSessionMemory(path)
+	if err != nil {
+		return nil, err
+	}
+	if transport == "stdio" {
+		return func() *sessionMemory { return mem }, nil
 	}
-	server.run()
+	return mem.clone, nil
 }
 
-// getAvailableTools は利用可能なツールの一覧を返します
-func getAvailableTools() []Tool {
-	commonSchema := map[string]any{
+// withMemory は計算履歴と変数を mem に記録します
+func withMemory(mem *sessionMemory) serverOption {
+	return func(s *Server) error {
+		s.memory = mem
+		return nil
+	}
+}
+
+// notify はクライアントに通知を送ります。送信先のトランスポートがない場合は何もしません
+func (s *Server) notify(method string, params any) {
+	s.notifyContext(context.Background(), method, params)
+}
+
+// senderKey はリクエストに関する通知の送信先をコンテキストに保持するキーです
+type senderKey struct{}
+
+// withSender はリクエストの処理中に送る進捗やログの通知の送信先を send にします。
+// Streamable HTTP では POST に対する SSE の応答へ書き込むために使います
+func withSender(ctx context.Context, send func(msg any)) context.Context {
+	return context.WithValue(ctx, senderKey{}, send)
+}
+
+// notifyContext は ctx に送信先があればそこへ、なければセッションの送信先へ通知を送ります
+func (s *Server) notifyContext(ctx context.Context, method string, params any) {
+	send, ok := ctx.Value(senderKey{}).(func(msg any))
+	if !ok {
+		send = s.send
+	}
+	if send == nil {
+		return
+	}
+	send(Notification{
+		JsonRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+// newServer は初期状態のサーバーを生成します。
+// HTTP トランスポートではセッションごとに生成されます
+func newServer(opts ...serverOption) (*Server, error) {
+	tools, err := newToolRegistry(builtinTools()...)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		name:    "go-calculator-server",
+		version: "0.0.1",
+		state:   stateUninitialized,
+		memory:  newSessionMemory(),
+		logger:  newServerLogger(),
+		tools:   tools,
+
+		subscriptions:  make(map[string]bool),
+		inflight:       make(map[string]context.CancelFunc),
+		maxConcurrency: runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	s.slots = make(chan struct{}, s.maxConcurrency)
+	return s, nil
+}
+
+// getArithmeticTools は四則演算と式評価のツールの一覧を返します
+func getArithmeticTools() []toolDef {
+	arithmeticSchema := map[string]any{
 		"type": "object",
 		"properties": map[string]any{
 			"a": map[string]any{
-				"type": "number",
+				"type":        []string{"number", "string"},
+				"description": "First operand. Pass a string to avoid float64 rounding",
 			},
 			"b": map[string]any{
-				"type": "number",
+				"type":        []string{"number", "string"},
+				"description": "Second operand. Pass a string to avoid float64 rounding",
+			},
+			"precision": map[string]any{
+				"type":        "string",
+				"enum":        []PrecisionMode{PrecisionFloat, PrecisionExact, PrecisionDecimal, PrecisionBigFloat},
+				"description": "Numeric mode: float (float64), exact (rational), decimal (rounded decimal) or bigfloat (arbitrary precision)",
 			},
+			"digits": map[string]any{
+				"type":        "integer",
+				"minimum":     0,
+				"description": "Fractional digits for decimal mode or significant digits for bigfloat mode",
+			},
+			"format": formatSchema,
 		},
 		"required": []string{"a", "b"},
 	}
 
-	return []Tool{
+	resultSchema := outputSchema(map[string]any{
+		"result": map[string]any{
+			"type":        "string",
+			"description": "Result formatted in the requested precision mode",
+		},
+		"value": map[string]any{
+			"type":        "number",
+			"description": "Numeric value of the result (rounded to float64 outside float mode, omitted when out of float64 range)",
+		},
+	}, "result")
+
+	tools := bindTools([]Tool{
 		{
-			Name:        "add",
-			Description: "Add two numbers",
-			InputSchema: commonSchema,
+			Name:         "add",
+			Description:  "Add two numbers",
+			InputSchema:  arithmeticSchema,
+			OutputSchema: resultSchema,
 		},
 		{
-			Name:        "subtract",
-			Description: "Subtract two numbers",
-			InputSchema: commonSchema,
+			Name:         "subtract",
+			Description:  "Subtract two numbers",
+			InputSchema:  arithmeticSchema,
+			OutputSchema: resultSchema,
 		},
 		{
-			Name:        "multiply",
-			Description: "Multiply two numbers",
-			InputSchema: commonSchema,
+			Name:         "multiply",
+			Description:  "Multiply two numbers",
+			InputSchema:  arithmeticSchema,
+			OutputSchema: resultSchema,
 		},
 		{
-			Name:        "divide",
-			Description: "Divide first number by second number",
-			InputSchema: commonSchema,
+			Name:         "divide",
+			Description:  "Divide first number by second number",
+			InputSchema:  arithmeticSchema,
+			OutputSchema: resultSchema,
+		},
+	}, (*Server).handleArithmetic)
+
+	return append(tools, toolDef{
+		Tool: Tool{
+			Name:        "evaluate",
+			Description: "Evaluate an arithmetic expression with operator precedence, parentheses, unary minus, exponentiation (^) and functions such as sqrt, sin and log",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"expression": map[string]any{
+						"type":        "string",
+						"description": "Expression to evaluate, e.g. (3+4)*2^5/7, or 3 m * 4 m in units mode",
+					},
+					"units": map[string]any{
+						"type":        "boolean",
+						"description": "Carry units through the calculation and reject dimension mismatches",
+					},
+					"to": map[string]any{
+						"type":        "string",
+						"description": "Unit to express the result in (implies units mode)",
+					},
+					"format": formatSchema,
+				},
+				"required": []string{"expression"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"value": map[string]any{"type": "number"},
+				"unit": map[string]any{
+					"type":        "string",
+					"description": "Unit of the value in units mode (SI base units unless to is given)",
+				},
+			}, "value"),
 		},
+		handler: (*Server).handleEvaluate,
+	})
+}
+
+// handleToolCall はツールの呼び出しを処理します。返すエラーはツールの実行エラーです
+func (s *Server) handleToolCall(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	tool, ok := s.tools.lookup(name)
+	if !ok {
+		return nil, unknownTool(name)
 	}
+
+	// 数値の引数では $1 や $x で以前の結果や変数を参照できる
+	args, err := s.memory.resolveReferences(args, tool.schema)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.runTool(ctx, tool, args)
+	if err != nil {
+		return nil, err
+	}
+	if tool.noHistory {
+		return result, nil
+	}
+	return s.recordResult(name, args, result), nil
 }
 
-// handleToolCall はツールの呼び出しを処理します
-func (s *Server) handleToolCall(name string, args json.RawMessage) (any, *Error) {
+// recordResult は結果を履歴に追加し、参照用の ID（$1 など）を結果に付け加えます
+func (s *Server) recordResult(name string, args json.RawMessage, result *CallToolResult) *CallToolResult {
+	if len(result.Content) == 0 {
+		return result
+	}
+	// テキストは 1,234.5 や 0xff のように整形されていることがあるので、読み戻さずにハンドラーが返した値を記録する
+	text := result.Content[0].Text
+	value := result.value
+	if data, ok := result.StructuredContent.(map[string]any); ok && value == "" {
+		switch v := data["value"].(type) {
+		case float64:
+			value = floatNumber(v)
+		case string:
+			// 任意精度の整数などは文字列のまま記録する
+			value = Number(v)
+		}
+	}
+	entry, err := s.memory.record(name, args, text, value)
+	if err != nil {
+		s.log(LevelError, "memory", "Error saving history", map[string]any{"error": err.Error()})
+	}
+	s.notifyResourceUpdated(historyURI)
+	result.Content = append(result.Content, TextContent{
+		Type: "text",
+		Text: "Stored as " + entry.Ref(),
+	})
+	return result
+}
+
+// callTool は名前でツールを探して実行します。結果は履歴に記録しません
+func (s *Server) callTool(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	tool, ok := s.tools.lookup(name)
+	if !ok {
+		return nil, unknownTool(name)
+	}
+	return s.runTool(ctx, tool, args)
+}
+
+// runTool は引数を入力スキーマで検証してからツールのハンドラーを実行します
+func (s *Server) runTool(ctx context.Context, tool *registeredTool, args json.RawMessage) (*CallToolResult, *Error) {
+	if err := ctx.Err(); err != nil {
+		return nil, requestCancelled(err)
+	}
+	if err := validateArguments(args, tool.schema); err != nil {
+		return nil, err
+	}
+	format, err := s.callFormat(args)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tool.handler(s, withNumberFormat(ctx, format), args)
+	if err != nil {
+		return nil, err
+	}
+	// NaN や無限大を含む構造化データは JSON にできず応答が返らなくなるので、定義域エラーにする
+	if _, merr := json.Marshal(result.StructuredContent); merr != nil {
+		return nil, outOfRange(tool.Name)
+	}
+	return result, nil
+}
+
+// handleArithmetic は四則演算のツールの呼び出しを処理します
+func (s *Server) handleArithmetic(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
 	var params CalcParams
 	if err := json.Unmarshal(args, &params); err != nil {
 		return nil, &Error{
@@ -174,137 +590,533 @@ func (s *Server) handleToolCall(name string, args json.RawMessage) (any, *Error)
 		}
 	}
 
-	result := map[string]any{
-		"content": []map[string]any{
-			{
-				"type": "text",
-			},
-		},
+	opts := params.PrecisionOptions.merge(s.precision)
+	if err := opts.validate(); err != nil {
+		return nil, err
 	}
 
-	switch name {
-	case "add":
-		result["content"].([]map[string]any)[0]["text"] = fmt.Sprintf("%f", params.A+params.B)
-		return result, nil
-	case "subtract":
-		result["content"].([]map[string]any)[0]["text"] = fmt.Sprintf("%f", params.A-params.B)
-		return result, nil
-	case "multiply":
-		result["content"].([]map[string]any)[0]["text"] = fmt.Sprintf("%f", params.A*params.B)
-		return result, nil
-	case "divide":
-		if params.B == 0 {
+	// float モードの結果は表記の指定に従って整形する。他のモードは厳密な表記のまま返す
+	if opts.Mode == PrecisionFloat {
+		value, err := calculateFloat(name, params.A, params.B)
+		if err != nil {
+			return nil, err
+		}
+		text := formatNumber(ctx, value)
+		return newStructuredResult(text, map[string]any{"result": text, "value": value}), nil
+	}
+	text, err := calculate(name, params.A, params.B, opts)
+	if err != nil {
+		return nil, err
+	}
+	// 厳密な結果は float64 の範囲を超えることがあるので、その場合は value を省く
+	structured := map[string]any{"result": text}
+	if value, err := Number(text).Float64(); err == nil {
+		structured["value"] = value
+	}
+	// 履歴には丸めた float64 ではなく厳密な結果を記録する
+	result := newStructuredResult(text, structured)
+	result.value = Number(text)
+	return result, nil
+}
+
+// handleEvaluate は evaluate ツールの呼び出しを処理します
+func (s *Server) handleEvaluate(ctx context.Context, args json.RawMessage) (*CallToolResult, *Error) {
+	var params EvaluateParams
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+
+	if params.Units || params.To != "" {
+		q, err := evaluateQuantity(params.Expression, s.memory.env())
+		if err != nil {
+			return nil, err.toRPCError(params.Expression)
+		}
+		if params.To == "" {
+			return newStructuredResult(q.format(numberFormat(ctx)), map[string]any{"value": q.value, "unit": q.dim.String()}), nil
+		}
+		to, perr := parseUnit(params.To)
+		if perr != nil {
 			return nil, &Error{
-				Code:    ErrorDivideByZero,
-				Message: "Division by zero",
+				Code:    ErrorInvalidParams,
+				Message: perr.Error(),
+				Data:    map[string]any{"unit": params.To},
 			}
 		}
-		result["content"].([]map[string]any)[0]["text"] = fmt.Sprintf("%f", params.A/params.B)
-		return result, nil
+		if q.dim != to.dim {
+			return nil, unitMismatchError(q.dim.String(), params.To, q.dim, to.dim)
+		}
+		value := (q.value - to.offset) / to.factor
+		if !finite(value) {
+			return nil, outOfRange("evaluate")
+		}
+		return newStructuredResult(formatNumber(ctx, value)+" "+params.To, map[string]any{"value": value, "unit": params.To}), nil
+	}
+
+	value, err := evaluateExpression(params.Expression, s.memory.env())
+	if err != nil {
+		return nil, err.toRPCError(params.Expression)
+	}
+
+	return newStructuredResult(formatNumber(ctx, value), map[string]any{"value": value}), nil
+}
+
+// newTextResult はテキスト1件からなるツールの結果を生成します
+func newTextResult(text string) *CallToolResult {
+	return &CallToolResult{
+		Content: []TextContent{
+			{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}
+}
+
+// newStructuredResult はテキストに加えて構造化データを持つツールの結果を生成します。
+// structured はツールの OutputSchema に従います
+func newStructuredResult(text string, structured any) *CallToolResult {
+	result := newTextResult(text)
+	result.StructuredContent = structured
+	return result
+}
+
+// floatNumber は float64 を丸めずに Number にします
+func floatNumber(v float64) Number {
+	return Number(strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// finite は値が NaN でも無限大でもないかを返します
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// outOfRange は結果が float64 の範囲を超えたときの定義域エラーを返します
+func outOfRange(name string) *Error {
+	return &Error{Code: ErrorMathDomain, Message: fmt.Sprintf("Result of %s is out of range", name)}
+}
+
+// newToolErrorResult はツールの実行エラーを isError の結果に変換します
+func newToolErrorResult(err *Error) *CallToolResult {
+	text := err.Message
+	switch data := err.Data.(type) {
+	case nil:
+	case string:
+		text += ": " + data
 	default:
-		return nil, &Error{
-			Code:    ErrorMethodNotFound,
-			Message: fmt.Sprintf("Tool '%s' not found", name),
+		if b, merr := json.Marshal(data); merr == nil {
+			text += " " + string(b)
 		}
 	}
+	result := newTextResult(text)
+	result.IsError = true
+	return result
 }
 
-func (s *Server) run() {
-	decoder := json.NewDecoder(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+// unknownTool は存在しないツールの呼び出しに対するエラーを生成します
+func unknownTool(name string) *Error {
+	return &Error{
+		Code:    ErrorInvalidParams,
+		Message: fmt.Sprintf("Unknown tool '%s'", name),
+	}
+}
+
+// handleMessage は単一のリクエストまたはバッチを処理し、送り返すべき値を返します。
+// 返す値がない場合（通知のみのバッチなど）は nil を返します
+func (s *Server) handleMessage(msg json.RawMessage) any {
+	return s.handleMessageContext(context.Background(), msg)
+}
+
+// handleMessageContext は ctx のもとでメッセージを処理します。
+// リクエストの処理中に送る通知は ctx の送信先（withSender）に送ります
+func (s *Server) handleMessageContext(ctx context.Context, msg json.RawMessage) any {
+	if !json.Valid(msg) {
+		return newErrorResponse(nil, &Error{
+			Code:    ErrorParseError,
+			Message: "Parse error",
+		})
+	}
 
-	for {
-		var req Request
-		if err := decoder.Decode(&req); err != nil {
-			fmt.Fprintf(os.Stderr, "Error decoding request: %v\n", err)
-			continue
+	trimmed := bytes.TrimSpace(msg)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		req, err := decodeRequest(msg)
+		if err != nil {
+			return newErrorResponse(req.ID, err)
 		}
+		if req.IsNotification() {
+			s.handleNotification(req)
+			return nil
+		}
+		resp := s.handleRequest(ctx, req)
+		if resp.cancelled {
+			return nil
+		}
+		return &resp
+	}
 
-		var resp Response
-		resp.JsonRPC = "2.0"
-		resp.ID = req.ID
+	batch, err := decodeBatch(msg)
+	if err != nil {
+		return newErrorResponse(nil, err)
+	}
+	wait, _ := s.dispatchBatch(ctx, batch)
+	return wait()
+}
 
-		switch req.Method {
-		case "initialize":
-			var initParams InitializeParams
-			if err := json.Unmarshal(req.Params, &initParams); err != nil {
-				resp.Error = &Error{
-					Code:    ErrorInvalidParams,
-					Message: "Invalid initialize params",
-					Data:    err.Error(),
+// decodeBatch はバッチを要素に分けます。配列でない場合や空の場合はエラーを返します
+func decodeBatch(msg json.RawMessage) ([]json.RawMessage, *Error) {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(msg, &batch); err != nil {
+		return nil, &Error{
+			Code:    ErrorInvalidRequest,
+			Message: "Invalid Request",
+			Data:    err.Error(),
+		}
+	}
+	if len(batch) == 0 {
+		return nil, &Error{
+			Code:    ErrorInvalidRequest,
+			Message: "Invalid Request",
+			Data:    "empty batch",
+		}
+	}
+	return batch, nil
+}
+
+// dispatchBatch はバッチの要素を順に処理します。tools/call の要素はキャンセルできるよう登録してから
+// 別の goroutine で実行するので、呼び出し側は時間のかかるツールを待たずに次のメッセージを読めます。
+// 返された関数はすべてのツールの完了を待ち、バッチの応答を返します。応答がない場合は nil を返します。
+// pending はバッチに実行中の tools/call があるかどうかを表し、false の場合 wait はすぐに戻ります
+func (s *Server) dispatchBatch(ctx context.Context, batch []json.RawMessage) (wait func() any, pending bool) {
+	// 通知やキャンセルされたリクエストの応答は nil のまま残り、バッチの結果に含めない
+	responses := make([]*Response, len(batch))
+	var wg sync.WaitGroup
+	for i, raw := range batch {
+		req, err := decodeRequest(raw)
+		switch {
+		case err != nil:
+			responses[i] = newErrorResponse(req.ID, err)
+		case req.IsNotification():
+			s.handleNotification(req)
+		case req.Method == "tools/call":
+			reqCtx, done := s.startRequest(ctx, req.ID)
+			pending = true
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer done()
+				if resp := s.handleStartedToolsCall(reqCtx, req); !resp.cancelled {
+					responses[i] = &resp
 				}
-				break
+			}()
+		default:
+			if resp := s.handleRequest(ctx, req); !resp.cancelled {
+				responses[i] = &resp
+			}
+		}
+	}
+	wait = func() any {
+		wg.Wait()
+		var replies []Response
+		for _, resp := range responses {
+			if resp != nil {
+				replies = append(replies, *resp)
 			}
+		}
+		if len(replies) == 0 {
+			return nil
+		}
+		return replies
+	}
+	return wait, pending
+}
 
-			// 初期化処理
-			s.initialized = true
+// decodeRequest は単一の JSON-RPC リクエストを検証しながらデコードします
+func decodeRequest(raw json.RawMessage) (Request, *Error) {
+	var req Request
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return req, &Error{
+			Code:    ErrorInvalidRequest,
+			Message: "Invalid Request",
+			Data:    "request must be a JSON object",
+		}
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return req, &Error{
+			Code:    ErrorInvalidRequest,
+			Message: "Invalid Request",
+			Data:    err.Error(),
+		}
+	}
+	if !validID(req.ID) {
+		// 不正な id はそのまま返せないので、応答の id は null にする
+		req.ID = nil
+		return req, &Error{
+			Code:    ErrorInvalidRequest,
+			Message: "Invalid Request",
+			Data:    "id must be a string, number or null",
+		}
+	}
+	if req.JsonRPC != "2.0" {
+		return req, &Error{
+			Code:    ErrorInvalidRequest,
+			Message: "Invalid Request",
+			Data:    `jsonrpc must be "2.0"`,
+		}
+	}
+	if req.Method == "" {
+		return req, &Error{
+			Code:    ErrorInvalidRequest,
+			Message: "Invalid Request",
+			Data:    "missing method",
+		}
+	}
+	return req, nil
+}
 
-			resp.Result = InitializeResult{
-				ServerInfo: Implementation{
-					Name:    s.name,
-					Version: s.version,
-				},
-				ProtocolVersion: initParams.ProtocolVersion,
-				Capabilities: ServerCapabilities{
-					Tools: &ToolsCapability{
-						ListChanged: true,
-					},
-				},
-				Instructions: "This server provides basic arithmetic operations through tools.",
-			}
+// validID は id が省略されているか、文字列・数値・null のいずれかであるかを返します
+func validID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	var v any
+	if err := json.Unmarshal(id, &v); err != nil {
+		return false
+	}
+	switch v.(type) {
+	case nil, string, float64:
+		return true
+	}
+	return false
+}
 
-		case "shutdown":
-			resp.Result = struct{}{}
-			if err := encoder.Encode(resp); err != nil {
-				fmt.Fprintf(os.Stderr, "Error encoding response: %v\n", err)
-			}
-			os.Exit(0)
+// newErrorResponse はエラーレスポンスを生成します
+func newErrorResponse(id any, err *Error) *Response {
+	return &Response{
+		JsonRPC: "2.0",
+		Error:   err,
+		ID:      id,
+	}
+}
 
-		case "tools/list":
-			if !s.initialized {
-				resp.Error = &Error{
-					Code:    ErrorInternalError,
-					Message: "Server not initialized",
-				}
-				break
-			}
-			resp.Result = ListToolsResult{
-				Tools: getAvailableTools(),
+// handleRequest は単一のリクエストをメソッドに応じて処理します
+func (s *Server) handleRequest(ctx context.Context, req Request) (resp Response) {
+	// ツールの呼び出しはセッションの状態をロックせずに実行し、他のリクエストを妨げない
+	if req.Method == "tools/call" {
+		ctx, done := s.startRequest(ctx, req.ID)
+		defer done()
+		return s.handleStartedToolsCall(ctx, req)
+	}
+
+	defer func() { s.logResponse(ctx, req, resp) }()
+
+	resp.JsonRPC = "2.0"
+	resp.ID = req.ID
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkLifecycle(req.Method); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	switch req.Method {
+	case "initialize":
+		var initParams InitializeParams
+		if err := json.Unmarshal(req.Params, &initParams); err != nil {
+			resp.Error = &Error{
+				Code:    ErrorInvalidParams,
+				Message: "Invalid initialize params",
+				Data:    err.Error(),
 			}
+			break
+		}
 
-		case "tools/call":
-			if !s.initialized {
-				resp.Error = &Error{
-					Code:    ErrorInternalError,
-					Message: "Server not initialized",
-				}
-				break
+		// クライアントが experimental.calculator で既定の精度モードと表記を指定できる
+		if opts, ok := initParams.Capabilities.Experimental["calculator"]; ok {
+			raw, _ := json.Marshal(opts)
+			var calc struct {
+				PrecisionOptions
+				Format FormatOptions `json:"format"`
 			}
-			var toolReq ToolRequest
-			if err := json.Unmarshal(req.Params, &toolReq); err != nil {
+			if err := json.Unmarshal(raw, &calc); err != nil {
 				resp.Error = &Error{
 					Code:    ErrorInvalidParams,
-					Message: "Invalid params",
+					Message: "Invalid calculator options",
 					Data:    err.Error(),
 				}
 				break
 			}
-			result, err := s.handleToolCall(toolReq.Name, toolReq.Arguments)
-			if err != nil {
+			if err := calc.PrecisionOptions.validate(); err != nil {
 				resp.Error = err
-			} else {
-				resp.Result = result
+				break
 			}
+			format := calc.Format.merge(s.format)
+			if err := format.validate(); err != nil {
+				resp.Error = err
+				break
+			}
+			s.precision = calc.PrecisionOptions
+			s.format = format
+		}
 
-		default:
+		version, verr := negotiateProtocolVersion(initParams.ProtocolVersion)
+		if verr != nil {
+			resp.Error = verr
+			break
+		}
+		s.protocolVersion = version
+
+		// 初期化処理（notifications/initialized を受けて ready に移行する）
+		s.state = stateInitializing
+
+		resp.Result = InitializeResult{
+			ServerInfo: Implementation{
+				Name:    s.name,
+				Version: s.version,
+			},
+			ProtocolVersion: s.protocolVersion,
+			Capabilities:    s.serverCapabilities(),
+			Instructions:    serverInstructions,
+		}
+
+	case "ping":
+		resp.Result = struct{}{}
+
+	case "shutdown":
+		resp.Result = struct{}{}
+		s.state = stateShuttingDown
+
+	case "tools/list":
+		tools := s.tools.list()
+		for i := range tools {
+			if !s.features().toolAnnotations {
+				tools[i].Annotations = nil
+			} else if tools[i].Annotations == nil {
+				tools[i].Annotations = calculatorToolAnnotations
+			}
+			if !s.features().structuredOutput {
+				tools[i].OutputSchema = nil
+			}
+		}
+		resp.Result = ListToolsResult{
+			Tools: tools,
+		}
+
+	case "prompts/list":
+		resp.Result = ListPromptsResult{
+			Prompts: getAvailablePrompts(),
+		}
+
+	case "prompts/get":
+		resp.Result, resp.Error = s.handleGetPrompt(req.Params)
+
+	case "completion/complete":
+		if !s.features().completions {
 			resp.Error = &Error{
 				Code:    ErrorMethodNotFound,
-				Message: fmt.Sprintf("Method '%s' not found", req.Method),
+				Message: fmt.Sprintf("Method '%s' requires protocol version 2025-03-26 or later", req.Method),
 			}
+			break
+		}
+		resp.Result, resp.Error = s.handleComplete(req.Params)
+
+	case "logging/setLevel":
+		resp.Result, resp.Error = s.handleSetLevel(req.Params)
+
+	case "resources/list":
+		resp.Result, resp.Error = s.handleListResources(req.Params)
+
+	case "resources/templates/list":
+		resp.Result = ListResourceTemplatesResult{
+			ResourceTemplates: historyResourceTemplates(),
 		}
 
-		if err := encoder.Encode(resp); err != nil {
-			fmt.Fprintf(os.Stderr, "Error encoding response: %v\n", err)
+	case "resources/read":
+		resp.Result, resp.Error = s.handleReadResource(req.Params)
+
+	case "resources/subscribe", "resources/unsubscribe":
+		resp.Result, resp.Error = s.handleSubscribe(req.Method, req.Params)
+
+	default:
+		resp.Error = &Error{
+			Code:    ErrorMethodNotFound,
+			Message: fmt.Sprintf("Method '%s' not found", req.Method),
+		}
+	}
+
+	return resp
+}
+
+// handleStartedToolsCall は startRequest で登録済みの tools/call を処理し、応答を返します
+func (s *Server) handleStartedToolsCall(ctx context.Context, req Request) (resp Response) {
+	defer func() { s.logResponse(ctx, req, resp) }()
+
+	resp.JsonRPC = "2.0"
+	resp.ID = req.ID
+	s.handleToolsCall(ctx, req, &resp)
+	return resp
+}
+
+// handleToolsCall は tools/call を処理します。
+// 同時に実行できる数を slots で制限し、ctx がキャンセルされたら応答しません
+func (s *Server) handleToolsCall(ctx context.Context, req Request, resp *Response) {
+	s.mu.Lock()
+	err := s.checkLifecycle(req.Method)
+	structuredOutput := s.features().structuredOutput
+	s.mu.Unlock()
+	if err != nil {
+		resp.Error = err
+		return
+	}
+
+	var toolReq ToolRequest
+	if err := json.Unmarshal(req.Params, &toolReq); err != nil {
+		resp.Error = &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid params",
+			Data:    err.Error(),
 		}
+		return
+	}
+	// 存在しないツールはプロトコルのエラー、ツールの実行時のエラーは isError の結果として返す
+	if _, ok := s.tools.lookup(toolReq.Name); !ok {
+		resp.Error = unknownTool(toolReq.Name)
+		return
+	}
+
+	if toolReq.Meta != nil {
+		ctx = s.withProgress(ctx, toolReq.Meta.ProgressToken)
+	}
+	if err := s.acquireSlot(ctx); err != nil {
+		resp.cancelled = true
+		return
+	}
+	defer s.releaseSlot()
+
+	start := time.Now()
+	result, terr := s.handleToolCall(ctx, toolReq.Name, toolReq.Arguments)
+	fields := map[string]any{
+		"tool":       toolReq.Name,
+		"durationMs": float64(time.Since(start).Microseconds()) / 1000,
+	}
+	// キャンセルされたリクエストには応答しない
+	if ctx.Err() != nil {
+		resp.cancelled = true
+		s.logContext(ctx, LevelInfo, "tools", "Cancelled "+toolReq.Name, fields)
+		return
+	}
+	if terr != nil {
+		result = newToolErrorResult(terr)
+		fields["error"] = terr.Message
+	}
+	if !structuredOutput {
+		result.StructuredContent = nil
 	}
+	resp.Result = result
+	s.logContext(ctx, LevelInfo, "tools", "Called "+toolReq.Name, fields)
 }
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func newTestServer() *Server {
+	s, err := newServer()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// roundTrip は handleMessage の戻り値を JSON に変換して返します
+func roundTrip(t *testing.T, s *Server, msg string) string {
+	t.Helper()
+	reply := s.handleMessage(json.RawMessage(msg))
+	if reply == nil {
+		return ""
+	}
+	out, err := json.Marshal(reply)
+	if err != nil {
+		t.Fatalf("failed to marshal reply: %v", err)
+	}
+	return string(out)
+}
+
+func TestHandleMessageBatch(t *testing.T) {
+	s := newTestServer()
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+
+	out := roundTrip(t, s, `[
+		{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}},
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"2.0","id":"two","method":"unknown"}
+	]`)
+
+	var responses []Response
+	if err := json.Unmarshal([]byte(out), &responses); err != nil {
+		t.Fatalf("expected array response, got %s", out)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("expected 2 responses (notification omitted), got %d: %s", len(responses), out)
+	}
+	if responses[0].Error != nil || responses[0].ID != float64(1) {
+		t.Errorf("unexpected first response: %+v", responses[0])
+	}
+	if responses[1].Error == nil || responses[1].Error.Code != ErrorMethodNotFound || responses[1].ID != "two" {
+		t.Errorf("unexpected second response: %+v", responses[1])
+	}
+}
+
+func TestHandleMessageInvalidBatch(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{
+			name: "empty batch",
+			msg:  `[]`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"empty batch"},"id":null}`,
+		},
+		{
+			name: "invalid elements",
+			msg:  `[1, 2]`,
+			want: `[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"request must be a JSON object"},"id":null},` +
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"request must be a JSON object"},"id":null}]`,
+		},
+		{
+			name: "notifications only",
+			msg:  `[{"jsonrpc":"2.0","method":"notifications/initialized"}]`,
+			want: ``,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := roundTrip(t, s, tt.msg); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestHandleMessageInvalidRequest(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{
+			name: "wrong version",
+			msg:  `{"jsonrpc":"1.0","id":8,"method":"ping"}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"jsonrpc must be \"2.0\""},"id":8}`,
+		},
+		{
+			name: "missing version",
+			msg:  `{"id":8,"method":"ping"}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"jsonrpc must be \"2.0\""},"id":8}`,
+		},
+		{
+			name: "object id",
+			msg:  `{"jsonrpc":"2.0","id":{"a":1},"method":"ping"}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"id must be a string, number or null"},"id":null}`,
+		},
+		{
+			name: "array id",
+			msg:  `{"jsonrpc":"2.0","id":[1],"method":"ping"}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"id must be a string, number or null"},"id":null}`,
+		},
+		{
+			name: "boolean id",
+			msg:  `{"jsonrpc":"2.0","id":true,"method":"ping"}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"id must be a string, number or null"},"id":null}`,
+		},
+		{
+			name: "missing method",
+			msg:  `{"jsonrpc":"2.0","id":"a"}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"missing method"},"id":"a"}`,
+		},
+		{
+			name: "string id",
+			msg:  `{"jsonrpc":"2.0","id":"a","method":"ping"}`,
+			want: `{"jsonrpc":"2.0","result":{},"id":"a"}`,
+		},
+		// バッチの要素も同じように検証する
+		{
+			name: "batch element",
+			msg:  `[{"jsonrpc":"1.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"ping"}]`,
+			want: `[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"jsonrpc must be \"2.0\""},"id":1},{"jsonrpc":"2.0","result":{},"id":2}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := roundTrip(t, s, tt.msg); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestHandleMessageBatchParseError(t *testing.T) {
+	s := newTestServer()
+	out := roundTrip(t, s, `[{"jsonrpc":"2.0","id":1,"method":"ping"},`)
+
+	var resp Response
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("expected single response, got %s", out)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrorParseError {
+		t.Errorf("expected parse error, got %s", out)
+	}
+}
+
+func TestToolErrorsAsResults(t *testing.T) {
+	s := newReadyServer(t)
+
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{
+			name: "division by zero",
+			msg:  `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"divide","arguments":{"a":1,"b":0}}}`,
+			want: `{"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"Division by zero"}],"isError":true},"id":1}`,
+		},
+		{
+			name: "invalid arguments",
+			msg:  `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"add","arguments":{"a":"x","b":1}}}`,
+			want: `{"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"Invalid arguments: invalid number \"x\""}],"isError":true},"id":2}`,
+		},
+		{
+			name: "error data",
+			msg:  `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"evaluate","arguments":{"expression":"1 +"}}}`,
+			want: `{"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"unexpected end of expression at position 3 {\"expression\":\"1 +\",\"position\":3}"}],"isError":true},"id":3}`,
+		},
+		{
+			// NaN や無限大は応答の JSON にできないので引数の段階で拒否する
+			name: "non-finite argument",
+			msg:  `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"add","arguments":{"a":"NaN","b":1}}}`,
+			want: `{"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"Invalid arguments: \"NaN\" is not a finite float64 number"}],"isError":true},"id":5}`,
+		},
+		{
+			name: "overflow",
+			msg:  `{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"multiply","arguments":{"a":1e308,"b":10}}}`,
+			want: `{"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"Result of multiply is out of range"}],"isError":true},"id":6}`,
+		},
+		{
+			// 各ツールで検査していない値も、構造化データを JSON にする前に検出する
+			name: "non-finite structured content",
+			msg:  `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"matrix_determinant","arguments":{"a":[[1e200,0],[0,1e200]]}}}`,
+			want: `{"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"Result of matrix_determinant is out of range"}],"isError":true},"id":7}`,
+		},
+		{
+			// 存在しないツールはプロトコルのエラーになる
+			name: "unknown tool",
+			msg:  `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"launch","arguments":{}}}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Unknown tool 'launch'"},"id":4}`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := roundTrip(t, s, tt.msg); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestStructuredContentGatedByVersion(t *testing.T) {
+	for version, want := range map[string]bool{"2025-03-26": false, "2025-06-18": true} {
+		s := newTestServer()
+		roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+version+`"}}`)
+
+		list := roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+		if got := strings.Contains(list, `"outputSchema"`); got != want {
+			t.Errorf("%s: expected outputSchema present=%v, got %s", version, want, list)
+		}
+		out := roundTrip(t, s, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"add","arguments":{"a":"1/3","b":"1/3","precision":"exact"}}}`)
+		if got := strings.Contains(out, `"structuredContent":{"result":"2/3","value":0.6666666666666666}`); got != want {
+			t.Errorf("%s: expected structuredContent present=%v, got %s", version, want, out)
+		}
+	}
+}
+
+func TestOutputSchemaForEveryTool(t *testing.T) {
+	for _, tool := range newTestServer().tools.list() {
+		if tool.OutputSchema == nil {
+			t.Errorf("%s: missing outputSchema", tool.Name)
+		}
+	}
+}
+
+func TestExactResultBeyondFloat64(t *testing.T) {
+	s := newTestServer()
+	result, err := s.callTool(context.Background(), "multiply", json.RawMessage(`{"a":"1e300","b":"1e300","precision":"exact"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 厳密な結果は返し、float64 にできない value は省く
+	data := result.StructuredContent.(map[string]any)
+	if _, ok := data["value"]; ok || !strings.HasPrefix(data["result"].(string), "1000000") {
+		t.Errorf("expected exact result without value, got %v", data)
+	}
+}
+
+func TestErrorCodesAreDistinct(t *testing.T) {
+	// クライアントがエラーの種類をコードで見分けられるようにする
+	codes := map[int]string{}
+	for name, code := range map[string]int{
+		"ErrorParseError":           ErrorParseError,
+		"ErrorInvalidRequest":       ErrorInvalidRequest,
+		"ErrorMethodNotFound":       ErrorMethodNotFound,
+		"ErrorInvalidParams":        ErrorInvalidParams,
+		"ErrorInternalError":        ErrorInternalError,
+		"ErrorDivideByZero":         ErrorDivideByZero,
+		"ErrorMathDomain":           ErrorMathDomain,
+		"ErrorResourceNotFound":     ErrorResourceNotFound,
+		"ErrorUnitMismatch":         ErrorUnitMismatch,
+		"ErrorServerNotInitialized": ErrorServerNotInitialized,
+		"ErrorRequestCancelled":     ErrorRequestCancelled,
+	} {
+		if other, ok := codes[code]; ok {
+			t.Errorf("%s and %s share code %d", name, other, code)
+		}
+		codes[code] = name
+	}
+}
@@ -0,0 +1,789 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 行列ツールの制限
+const (
+	maxMatrixDim      = 100
+	maxEigenMatrixDim = 10
+	maxEigenIters     = 30
+)
+
+// matrix は行優先の2次元配列で表した行列です
+type matrix [][]float64
+
+// MatrixParams は行列ツールのパラメータを表します
+type MatrixParams struct {
+	A matrix `json:"a"`
+	B matrix `json:"b,omitempty"`
+}
+
+// LinearSystemParams は連立一次方程式 Ax = b のパラメータを表します
+type LinearSystemParams struct {
+	A matrix    `json:"a"`
+	B []float64 `json:"b"`
+}
+
+// complexValue は複素数の値を表します。固有値や複素数ツールの結果に使います
+type complexValue struct {
+	Re float64 `json:"re"`
+	Im float64 `json:"im"`
+}
+
+func (c complexValue) String() string {
+	return c.format(FormatOptions{})
+}
+
+// format は複素数を表記の指定に従って a + bi の形にします
+func (c complexValue) format(opts FormatOptions) string {
+	switch {
+	case c.Im == 0:
+		return opts.format(c.Re)
+	case c.Im < 0:
+		return opts.format(c.Re) + " - " + opts.format(-c.Im) + "i"
+	default:
+		return opts.format(c.Re) + " + " + opts.format(c.Im) + "i"
+	}
+}
+
+// matrixSchema は2次元配列の入力スキーマを生成します
+func matrixSchema(description string) map[string]any {
+	return map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			"type":     "array",
+			"items":    map[string]any{"type": "number"},
+			"minItems": 1,
+		},
+		"minItems":    1,
+		"description": description,
+	}
+}
+
+// getMatrixTools は行列ツールの一覧を返します
+func getMatrixTools() []toolDef {
+	unary := func(description string) map[string]any {
+		return map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"a":      matrixSchema(description),
+				"format": formatSchema,
+			},
+			"required": []string{"a"},
+		}
+	}
+	binary := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"a":      matrixSchema("Left matrix as an array of rows"),
+			"b":      matrixSchema("Right matrix as an array of rows"),
+			"format": formatSchema,
+		},
+		"required": []string{"a", "b"},
+	}
+
+	numbers := map[string]any{"type": "array", "items": map[string]any{"type": "number"}}
+	matrixOutput := outputSchema(map[string]any{
+		"matrix": map[string]any{"type": "array", "items": numbers},
+	}, "matrix")
+
+	return bindTools([]Tool{
+		{Name: "matrix_add", Description: "Add two matrices of the same shape", InputSchema: binary, OutputSchema: matrixOutput},
+		{Name: "matrix_multiply", Description: "Multiply two matrices (columns of a must equal rows of b)", InputSchema: binary, OutputSchema: matrixOutput},
+		{Name: "matrix_transpose", Description: "Transpose a matrix", InputSchema: unary("Matrix as an array of rows"), OutputSchema: matrixOutput},
+		{
+			Name:        "matrix_determinant",
+			Description: "Determinant of a square matrix",
+			InputSchema: unary("Square matrix as an array of rows"),
+			OutputSchema: outputSchema(map[string]any{
+				"determinant": map[string]any{"type": "number"},
+			}, "determinant"),
+		},
+		{Name: "matrix_inverse", Description: "Inverse of a square non-singular matrix", InputSchema: unary("Square matrix as an array of rows"), OutputSchema: matrixOutput},
+		{
+			Name:        "matrix_rank",
+			Description: "Rank of a matrix",
+			InputSchema: unary("Matrix as an array of rows"),
+			OutputSchema: outputSchema(map[string]any{
+				"rank": map[string]any{"type": "integer"},
+			}, "rank"),
+		},
+		{
+			Name:        "matrix_eigenvalues",
+			Description: fmt.Sprintf("Eigenvalues of a square matrix up to %dx%d, including complex eigenvalues", maxEigenMatrixDim, maxEigenMatrixDim),
+			InputSchema: unary("Square matrix as an array of rows"),
+			OutputSchema: outputSchema(map[string]any{
+				"eigenvalues": map[string]any{
+					"type": "array",
+					"items": outputSchema(map[string]any{
+						"re": map[string]any{"type": "number"},
+						"im": map[string]any{"type": "number"},
+					}, "re", "im"),
+				},
+			}, "eigenvalues"),
+		},
+		{
+			Name:        "solve_linear_system",
+			Description: "Solve the linear system Ax = b for a square non-singular matrix A",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"a": matrixSchema("Coefficient matrix A as an array of rows"),
+					"b": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "number"},
+						"minItems":    1,
+						"description": "Right-hand side vector b",
+					},
+					"format": formatSchema,
+				},
+				"required": []string{"a", "b"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"solution": numbers,
+			}, "solution"),
+		},
+	}, (*Server).handleMatrix)
+}
+
+// handleMatrix は行列ツールの呼び出しを処理します
+func (s *Server) handleMatrix(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	if name == "solve_linear_system" {
+		return handleLinearSystem(ctx, args)
+	}
+
+	var params MatrixParams
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+	if err := params.A.validate("a"); err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "matrix_add", "matrix_multiply":
+		if err := params.B.validate("b"); err != nil {
+			return nil, err
+		}
+		var result matrix
+		if name == "matrix_add" {
+			if params.A.rows() != params.B.rows() || params.A.cols() != params.B.cols() {
+				return nil, nonConformable("matrix_add", params.A, params.B)
+			}
+			result = params.A.add(params.B)
+		} else {
+			if params.A.cols() != params.B.rows() {
+				return nil, nonConformable("matrix_multiply", params.A, params.B)
+			}
+			result = params.A.mul(params.B)
+		}
+		return matrixResult(ctx, name, result)
+
+	case "matrix_transpose":
+		return matrixResult(ctx, name, params.A.transpose())
+
+	case "matrix_rank":
+		rank := params.A.rank()
+		result := newStructuredResult(fmt.Sprintf("%d", rank), map[string]any{"rank": rank})
+		result.value = Number(strconv.Itoa(rank))
+		return result, nil
+	}
+
+	// 以降は正方行列のみ
+	if err := params.A.requireSquare(); err != nil {
+		return nil, err
+	}
+	switch name {
+	case "matrix_determinant":
+		det := params.A.determinant()
+		if !finite(det) {
+			return nil, outOfRange(name)
+		}
+		result := newStructuredResult(formatNumber(ctx, det), map[string]any{"determinant": det})
+		result.value = floatNumber(det)
+		return result, nil
+
+	case "matrix_inverse":
+		inv, ok := params.A.inverse()
+		if !ok {
+			return nil, singularMatrix()
+		}
+		return matrixResult(ctx, name, inv)
+
+	case "matrix_eigenvalues":
+		if params.A.rows() > maxEigenMatrixDim {
+			return nil, &Error{
+				Code:    ErrorInvalidParams,
+				Message: fmt.Sprintf("Eigenvalues are supported for matrices up to %dx%d", maxEigenMatrixDim, maxEigenMatrixDim),
+			}
+		}
+		values, ok := params.A.eigenvalues()
+		if !ok {
+			return nil, &Error{
+				Code:    ErrorMathDomain,
+				Message: "Eigenvalue iteration did not converge",
+			}
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			if !finite(v.Re) || !finite(v.Im) {
+				return nil, outOfRange(name)
+			}
+			parts[i] = v.format(numberFormat(ctx))
+		}
+		return newStructuredResult(strings.Join(parts, ", "), map[string]any{"eigenvalues": values}), nil
+	}
+
+	return nil, unknownTool(name)
+}
+
+// handleLinearSystem は solve_linear_system ツールの呼び出しを処理します
+func handleLinearSystem(ctx context.Context, args json.RawMessage) (*CallToolResult, *Error) {
+	var params LinearSystemParams
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+	if err := params.A.validate("a"); err != nil {
+		return nil, err
+	}
+	if err := params.A.requireSquare(); err != nil {
+		return nil, err
+	}
+	if len(params.B) != params.A.rows() {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("Non-conformable system: a is %dx%d but b has %d elements", params.A.rows(), params.A.cols(), len(params.B)),
+		}
+	}
+
+	x, ok := params.A.solve(params.B)
+	if !ok {
+		return nil, singularMatrix()
+	}
+	parts := make([]string, len(x))
+	for i, v := range x {
+		if !finite(v) {
+			return nil, outOfRange("solve_linear_system")
+		}
+		parts[i] = fmt.Sprintf("x%d = %s", i+1, formatNumber(ctx, v))
+	}
+	return newStructuredResult(strings.Join(parts, "\n"), map[string]any{"solution": x}), nil
+}
+
+// matrixResult は行列をテキストと構造化データの両方で返します。
+// 要素が float64 の範囲を超えた場合は定義域エラーにします
+func matrixResult(ctx context.Context, name string, m matrix) (*CallToolResult, *Error) {
+	for _, row := range m {
+		for _, v := range row {
+			if !finite(v) {
+				return nil, outOfRange(name)
+			}
+		}
+	}
+	return newStructuredResult(m.format(numberFormat(ctx)), map[string]any{"matrix": m}), nil
+}
+
+func nonConformable(op string, a, b matrix) *Error {
+	return &Error{
+		Code:    ErrorInvalidParams,
+		Message: fmt.Sprintf("Non-conformable matrices for %s: %dx%d and %dx%d", op, a.rows(), a.cols(), b.rows(), b.cols()),
+	}
+}
+
+func singularMatrix() *Error {
+	return &Error{
+		Code:    ErrorMathDomain,
+		Message: "Matrix is singular",
+	}
+}
+
+func (m matrix) rows() int { return len(m) }
+func (m matrix) cols() int { return len(m[0]) }
+
+// validate は行列が空でなく、すべての行が同じ長さであることを検証します
+func (m matrix) validate(name string) *Error {
+	if len(m) == 0 || len(m[0]) == 0 {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("%s must be a non-empty matrix", name),
+		}
+	}
+	if len(m) > maxMatrixDim || len(m[0]) > maxMatrixDim {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("%s exceeds the maximum size of %dx%d", name, maxMatrixDim, maxMatrixDim),
+		}
+	}
+	for i, row := range m {
+		if len(row) != len(m[0]) {
+			return &Error{
+				Code:    ErrorInvalidParams,
+				Message: fmt.Sprintf("%s is not rectangular: row %d has %d columns, expected %d", name, i, len(row), len(m[0])),
+			}
+		}
+	}
+	return nil
+}
+
+func (m matrix) requireSquare() *Error {
+	if m.rows() != m.cols() {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("Matrix must be square, got %dx%d", m.rows(), m.cols()),
+		}
+	}
+	return nil
+}
+
+func (m matrix) String() string {
+	return m.format(FormatOptions{})
+}
+
+// format は行列を表記の指定に従って1行ずつ整形します
+func (m matrix) format(opts FormatOptions) string {
+	lines := make([]string, len(m))
+	for i, row := range m {
+		cells := make([]string, len(row))
+		for j, v := range row {
+			cells[j] = opts.format(v)
+		}
+		lines[i] = "[" + strings.Join(cells, ", ") + "]"
+	}
+	return strings.Join(lines, "\n")
+}
+
+func newMatrix(rows, cols int) matrix {
+	m := make(matrix, rows)
+	for i := range m {
+		m[i] = make([]float64, cols)
+	}
+	return m
+}
+
+func (m matrix) clone() matrix {
+	c := newMatrix(m.rows(), m.cols())
+	for i := range m {
+		copy(c[i], m[i])
+	}
+	return c
+}
+
+func (m matrix) add(o matrix) matrix {
+	r := newMatrix(m.rows(), m.cols())
+	for i := range m {
+		for j := range m[i] {
+			r[i][j] = m[i][j] + o[i][j]
+		}
+	}
+	return r
+}
+
+func (m matrix) mul(o matrix) matrix {
+	r := newMatrix(m.rows(), o.cols())
+	for i := range m {
+		for k := range o {
+			for j := range o[k] {
+				r[i][j] += m[i][k] * o[k][j]
+			}
+		}
+	}
+	return r
+}
+
+func (m matrix) transpose() matrix {
+	r := newMatrix(m.cols(), m.rows())
+	for i := range m {
+		for j := range m[i] {
+			r[j][i] = m[i][j]
+		}
+	}
+	return r
+}
+
+// machineEpsilon は float64 の計算機イプシロン（1 と次に大きい数の差）です
+const machineEpsilon = 2.220446049250313e-16
+
+// tolerance は特異性の判定に使う閾値を行列の大きさと要素の絶対値の最大から求めます。
+// 要素の大きさに比例させるので、要素がすべて小さい行列も特異とは判定しません。
+// 零行列では 0 を返し、ピボットがすべて 0 以下と判定されてランク 0 になります
+func (m matrix) tolerance() float64 {
+	maxAbs := 0.0
+	for _, row := range m {
+		for _, v := range row {
+			maxAbs = math.Max(maxAbs, math.Abs(v))
+		}
+	}
+	return float64(max(m.rows(), m.cols())) * maxAbs * machineEpsilon
+}
+
+// eliminate は部分ピボット選択付きのガウスの消去法で m を行階段形にします。
+// 行の入れ替え回数とランクを返します
+func (m matrix) eliminate() (swaps, rank int) {
+	tol := m.tolerance()
+	row := 0
+	for col := 0; col < m.cols() && row < m.rows(); col++ {
+		pivot := row
+		for i := row + 1; i < m.rows(); i++ {
+			if math.Abs(m[i][col]) > math.Abs(m[pivot][col]) {
+				pivot = i
+			}
+		}
+		if math.Abs(m[pivot][col]) <= tol {
+			continue
+		}
+		if pivot != row {
+			m[pivot], m[row] = m[row], m[pivot]
+			swaps++
+		}
+		for i := row + 1; i < m.rows(); i++ {
+			f := m[i][col] / m[row][col]
+			for j := col; j < m.cols(); j++ {
+				m[i][j] -= f * m[row][j]
+			}
+		}
+		row++
+	}
+	return swaps, row
+}
+
+func (m matrix) rank() int {
+	_, rank := m.clone().eliminate()
+	return rank
+}
+
+func (m matrix) determinant() float64 {
+	u := m.clone()
+	swaps, rank := u.eliminate()
+	if rank < m.rows() {
+		return 0
+	}
+	det := 1.0
+	if swaps%2 == 1 {
+		det = -1
+	}
+	for i := range u {
+		det *= u[i][i]
+	}
+	return det
+}
+
+// inverse はガウス・ジョルダン法で逆行列を求めます。特異な場合は false を返します
+func (m matrix) inverse() (matrix, bool) {
+	n := m.rows()
+	aug := newMatrix(n, 2*n)
+	for i := range m {
+		copy(aug[i], m[i])
+		aug[i][n+i] = 1
+	}
+	tol := m.tolerance()
+	for col := range n {
+		pivot := col
+		for i := col + 1; i < n; i++ {
+			if math.Abs(aug[i][col]) > math.Abs(aug[pivot][col]) {
+				pivot = i
+			}
+		}
+		if math.Abs(aug[pivot][col]) <= tol {
+			return nil, false
+		}
+		aug[pivot], aug[col] = aug[col], aug[pivot]
+		p := aug[col][col]
+		for j := range aug[col] {
+			aug[col][j] /= p
+		}
+		for i := range n {
+			if i == col {
+				continue
+			}
+			f := aug[i][col]
+			for j := range aug[i] {
+				aug[i][j] -= f * aug[col][j]
+			}
+		}
+	}
+	inv := newMatrix(n, n)
+	for i := range aug {
+		copy(inv[i], aug[i][n:])
+	}
+	return inv, true
+}
+
+// solve は Ax = b を解きます。A が特異な場合は false を返します
+func (m matrix) solve(b []float64) ([]float64, bool) {
+	n := m.rows()
+	aug := newMatrix(n, n+1)
+	for i := range m {
+		copy(aug[i], m[i])
+		aug[i][n] = b[i]
+	}
+	// 係数行列だけで特異性を判定するため、閾値は m から求める
+	tol := m.tolerance()
+	for col := range n {
+		pivot := col
+		for i := col + 1; i < n; i++ {
+			if math.Abs(aug[i][col]) > math.Abs(aug[pivot][col]) {
+				pivot = i
+			}
+		}
+		if math.Abs(aug[pivot][col]) <= tol {
+			return nil, false
+		}
+		aug[pivot], aug[col] = aug[col], aug[pivot]
+		for i := col + 1; i < n; i++ {
+			f := aug[i][col] / aug[col][col]
+			for j := col; j <= n; j++ {
+				aug[i][j] -= f * aug[col][j]
+			}
+		}
+	}
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		v := aug[i][n]
+		for j := i + 1; j < n; j++ {
+			v -= aug[i][j] * x[j]
+		}
+		x[i] = v / aug[i][i]
+	}
+	return x, true
+}
+
+// eigenvalues はヘッセンベルグ行列への変換と二重シフト QR 法で固有値を求めます。
+// 収束しない場合は false を返します
+func (m matrix) eigenvalues() ([]complexValue, bool) {
+	n := m.rows()
+	// 以下のアルゴリズムは1始まりの添字で記述する
+	a := newMatrix(n+1, n+1)
+	for i := range m {
+		copy(a[i+1][1:], m[i])
+	}
+	toHessenberg(a, n)
+
+	wr := make([]float64, n+1)
+	wi := make([]float64, n+1)
+	if !hessenbergQR(a, n, wr, wi) {
+		return nil, false
+	}
+	values := make([]complexValue, n)
+	for i := range values {
+		values[i] = complexValue{Re: wr[i+1], Im: wi[i+1]}
+	}
+	return values, true
+}
+
+// toHessenberg はピボット選択付きの消去法で a（1始まり）を上ヘッセンベルグ形に変換します
+func toHessenberg(a matrix, n int) {
+	for m := 2; m < n; m++ {
+		x := 0.0
+		i := m
+		for j := m; j <= n; j++ {
+			if math.Abs(a[j][m-1]) > math.Abs(x) {
+				x = a[j][m-1]
+				i = j
+			}
+		}
+		if i != m {
+			for j := m - 1; j <= n; j++ {
+				a[i][j], a[m][j] = a[m][j], a[i][j]
+			}
+			for j := 1; j <= n; j++ {
+				a[j][i], a[j][m] = a[j][m], a[j][i]
+			}
+		}
+		if x != 0 {
+			for i := m + 1; i <= n; i++ {
+				y := a[i][m-1]
+				if y == 0 {
+					continue
+				}
+				y /= x
+				a[i][m-1] = y
+				for j := m; j <= n; j++ {
+					a[i][j] -= y * a[m][j]
+				}
+				for j := 1; j <= n; j++ {
+					a[j][m] += y * a[j][i]
+				}
+			}
+		}
+	}
+	// 消去に使った乗数が残っている副対角より下を 0 にする
+	for i := 3; i <= n; i++ {
+		for j := 1; j < i-1; j++ {
+			a[i][j] = 0
+		}
+	}
+}
+
+// hessenbergQR は上ヘッセンベルグ行列 a（1始まり）の固有値を Francis の二重シフト QR 法で求め、
+// 実部を wr、虚部を wi に格納します
+func hessenbergQR(a matrix, n int, wr, wi []float64) bool {
+	anorm := 0.0
+	for i := 1; i <= n; i++ {
+		for j := max(i-1, 1); j <= n; j++ {
+			anorm += math.Abs(a[i][j])
+		}
+	}
+
+	var p, q, r, s, t, w, x, y, z float64
+	nn := n
+	for nn >= 1 {
+		its := 0
+		var l int
+		for {
+			// 小さな副対角要素を探して行列を分割する
+			for l = nn; l >= 2; l-- {
+				s = math.Abs(a[l-1][l-1]) + math.Abs(a[l][l])
+				if s == 0 {
+					s = anorm
+				}
+				if math.Abs(a[l][l-1])+s == s {
+					a[l][l-1] = 0
+					break
+				}
+			}
+			x = a[nn][nn]
+			if l == nn {
+				// 1つの実固有値が求まった
+				wr[nn] = x + t
+				wi[nn] = 0
+				nn--
+			} else {
+				y = a[nn-1][nn-1]
+				w = a[nn][nn-1] * a[nn-1][nn]
+				if l == nn-1 {
+					// 2x2 ブロックから2つの固有値が求まった
+					p = 0.5 * (y - x)
+					q = p*p + w
+					z = math.Sqrt(math.Abs(q))
+					x += t
+					if q >= 0 {
+						z = p + math.Copysign(z, p)
+						wr[nn-1] = x + z
+						wr[nn] = x + z
+						if z != 0 {
+							wr[nn] = x - w/z
+						}
+						wi[nn-1], wi[nn] = 0, 0
+					} else {
+						wr[nn-1] = x + p
+						wr[nn] = x + p
+						wi[nn-1] = -z
+						wi[nn] = z
+					}
+					nn -= 2
+				} else {
+					if its == maxEigenIters {
+						return false
+					}
+					// 収束が遅い場合は例外シフトを使う
+					if its == 10 || its == 20 {
+						t += x
+						for i := 1; i <= nn; i++ {
+							a[i][i] -= x
+						}
+						s = math.Abs(a[nn][nn-1]) + math.Abs(a[nn-1][nn-2])
+						x = 0.75 * s
+						y = x
+						w = -0.4375 * s * s
+					}
+					its++
+
+					var m int
+					for m = nn - 2; m >= l; m-- {
+						z = a[m][m]
+						r = x - z
+						s = y - z
+						p = (r*s-w)/a[m+1][m] + a[m][m+1]
+						q = a[m+1][m+1] - z - r - s
+						r = a[m+2][m+1]
+						s = math.Abs(p) + math.Abs(q) + math.Abs(r)
+						p /= s
+						q /= s
+						r /= s
+						if m == l {
+							break
+						}
+						u := math.Abs(a[m][m-1]) * (math.Abs(q) + math.Abs(r))
+						v := math.Abs(p) * (math.Abs(a[m-1][m-1]) + math.Abs(z) + math.Abs(a[m+1][m+1]))
+						if u+v == v {
+							break
+						}
+					}
+					for i := m + 2; i <= nn; i++ {
+						a[i][i-2] = 0
+						if i != m+2 {
+							a[i][i-3] = 0
+						}
+					}
+					for k := m; k <= nn-1; k++ {
+						if k != m {
+							p = a[k][k-1]
+							q = a[k+1][k-1]
+							r = 0
+							if k != nn-1 {
+								r = a[k+2][k-1]
+							}
+							if x = math.Abs(p) + math.Abs(q) + math.Abs(r); x != 0 {
+								p /= x
+								q /= x
+								r /= x
+							}
+						}
+						if s = math.Copysign(math.Sqrt(p*p+q*q+r*r), p); s != 0 {
+							if k == m {
+								if l != m {
+									a[k][k-1] = -a[k][k-1]
+								}
+							} else {
+								a[k][k-1] = -s * x
+							}
+							p += s
+							x = p / s
+							y = q / s
+							z = r / s
+							q /= p
+							r /= p
+							for j := k; j <= nn; j++ {
+								p = a[k][j] + q*a[k+1][j]
+								if k != nn-1 {
+									p += r * a[k+2][j]
+									a[k+2][j] -= p * z
+								}
+								a[k+1][j] -= p * y
+								a[k][j] -= p * x
+							}
+							for i := l; i <= min(nn, k+3); i++ {
+								p = x*a[i][k] + y*a[i][k+1]
+								if k != nn-1 {
+									p += z * a[i][k+2]
+									a[i][k+2] -= p * r
+								}
+								a[i][k+1] -= p * q
+								a[i][k] -= p
+							}
+						}
+					}
+				}
+			}
+			if l >= nn-1 {
+				break
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
+
+func TestMatrixOperations(t *testing.T) {
+	a := matrix{{1, 2}, {3, 4}}
+	b := matrix{{5, 6}, {7, 8}}
+
+	if got := a.add(b); !slices.EqualFunc(got, matrix{{6, 8}, {10, 12}}, slices.Equal) {
+		t.Errorf("add: got %v", got)
+	}
+	if got := a.mul(b); !slices.EqualFunc(got, matrix{{19, 22}, {43, 50}}, slices.Equal) {
+		t.Errorf("mul: got %v", got)
+	}
+	if got := (matrix{{1, 2, 3}}).transpose(); !slices.EqualFunc(got, matrix{{1}, {2}, {3}}, slices.Equal) {
+		t.Errorf("transpose: got %v", got)
+	}
+	if got := a.determinant(); !approxEqual(got, -2) {
+		t.Errorf("determinant: expected -2, got %v", got)
+	}
+	if got := (matrix{{0, 1}, {1, 0}}).determinant(); !approxEqual(got, -1) {
+		t.Errorf("determinant with swap: expected -1, got %v", got)
+	}
+
+	inv, ok := a.inverse()
+	if !ok {
+		t.Fatal("inverse: expected non-singular")
+	}
+	want := matrix{{-2, 1}, {1.5, -0.5}}
+	for i := range want {
+		for j := range want[i] {
+			if !approxEqual(inv[i][j], want[i][j]) {
+				t.Errorf("inverse: expected %v, got %v", want, inv)
+			}
+		}
+	}
+}
+
+func TestMatrixRank(t *testing.T) {
+	tests := []struct {
+		m    matrix
+		want int
+	}{
+		{matrix{{1, 2}, {2, 4}}, 1},
+		{matrix{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}, 2},
+		{matrix{{1, 0, 0}, {0, 1, 0}}, 2},
+		{matrix{{0, 0}, {0, 0}}, 0},
+		// 特異性の判定は要素の大きさに対して相対的に行う
+		{matrix{{1e-13, 0}, {0, 1e-13}}, 2},
+		{matrix{{1e-20, 2e-20}, {2e-20, 4e-20}}, 1},
+		{matrix{{1e200, 2e200}, {2e200, 4e200}}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.m.rank(); got != tt.want {
+			t.Errorf("rank(%v): expected %d, got %d", tt.m, tt.want, got)
+		}
+	}
+}
+
+func TestSolveLinearSystem(t *testing.T) {
+	x, ok := (matrix{{2, 1, -1}, {-3, -1, 2}, {-2, 1, 2}}).solve([]float64{8, -11, -3})
+	if !ok {
+		t.Fatal("expected solution")
+	}
+	for i, want := range []float64{2, 3, -1} {
+		if !approxEqual(x[i], want) {
+			t.Errorf("x%d: expected %v, got %v", i+1, want, x[i])
+		}
+	}
+
+	if _, ok := (matrix{{1, 2}, {2, 4}}).solve([]float64{1, 2}); ok {
+		t.Error("expected singular system to fail")
+	}
+
+	// 要素がすべて小さくても特異でなければ解ける
+	tiny := matrix{{1e-13, 0}, {0, 1e-13}}
+	x, ok = tiny.solve([]float64{1e-13, 2e-13})
+	if !ok || !approxEqual(x[0], 1) || !approxEqual(x[1], 2) {
+		t.Errorf("tiny system: expected [1 2], got %v (%v)", x, ok)
+	}
+	inv, ok := tiny.inverse()
+	if !ok || !approxEqual(inv[0][0]/1e13, 1) || inv[0][1] != 0 {
+		t.Errorf("tiny inverse: expected diag(1e13, 1e13), got %v (%v)", inv, ok)
+	}
+}
+
+func TestEigenvalues(t *testing.T) {
+	tests := []struct {
+		m    matrix
+		want []complexValue
+	}{
+		{matrix{{2, 0}, {0, 3}}, []complexValue{{2, 0}, {3, 0}}},
+		{matrix{{4, 1}, {2, 3}}, []complexValue{{2, 0}, {5, 0}}},
+		{matrix{{0, -1}, {1, 0}}, []complexValue{{0, -1}, {0, 1}}},
+		{matrix{{2, 0, 0}, {0, 3, 4}, {0, 4, 9}}, []complexValue{{1, 0}, {2, 0}, {11, 0}}},
+		{matrix{{1, 2, 3, 4}, {4, 1, 2, 3}, {3, 4, 1, 2}, {2, 3, 4, 1}}, []complexValue{{-2, -2}, {-2, 0}, {-2, 2}, {10, 0}}},
+	}
+
+	for _, tt := range tests {
+		got, ok := tt.m.eigenvalues()
+		if !ok {
+			t.Errorf("%v: did not converge", tt.m)
+			continue
+		}
+		sort.Slice(got, func(i, j int) bool {
+			if !approxEqual(got[i].Re, got[j].Re) {
+				return got[i].Re < got[j].Re
+			}
+			return got[i].Im < got[j].Im
+		})
+		for i := range tt.want {
+			if !approxEqual(got[i].Re, tt.want[i].Re) || !approxEqual(got[i].Im, tt.want[i].Im) {
+				t.Errorf("%v: expected %v, got %v", tt.m, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestMatrixToolErrors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		code int
+	}{
+		{"matrix_add", `{"a":[[1,2]],"b":[[1],[2]]}`, ErrorInvalidParams},
+		{"matrix_multiply", `{"a":[[1,2]],"b":[[1,2]]}`, ErrorInvalidParams},
+		{"matrix_determinant", `{"a":[[1,2,3],[4,5,6]]}`, ErrorInvalidParams},
+		{"matrix_transpose", `{"a":[[1,2],[3]]}`, ErrorInvalidParams},
+		{"matrix_transpose", `{"a":[]}`, ErrorInvalidParams},
+		{"matrix_inverse", `{"a":[[1,2],[2,4]]}`, ErrorMathDomain},
+		{"solve_linear_system", `{"a":[[1,2],[2,4]],"b":[1,2]}`, ErrorMathDomain},
+		{"solve_linear_system", `{"a":[[1,0],[0,1]],"b":[1]}`, ErrorInvalidParams},
+		// float64 の範囲を超える結果
+		{"matrix_determinant", `{"a":[[1e200,0],[0,1e200]]}`, ErrorMathDomain},
+		{"matrix_multiply", `{"a":[[1e200]],"b":[[1e200]]}`, ErrorMathDomain},
+	}
+
+	for _, tt := range tests {
+		_, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err == nil || err.Code != tt.code {
+			t.Errorf("%s %s: expected code %d, got %v", tt.name, tt.args, tt.code, err)
+		}
+	}
+}
+
+func TestMatrixToolStructuredResult(t *testing.T) {
+	s := newTestServer()
+	result, err := s.callTool(context.Background(), "matrix_multiply", json.RawMessage(`{"a":[[1,2],[3,4]],"b":[[1],[1]]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, _ := json.Marshal(result)
+	want := `{"content":[{"type":"text","text":"[3.000000]\n[7.000000]"}],"structuredContent":{"matrix":[[3],[7]]}}`
+	if string(out) != want {
+		t.Errorf("expected %s, got %s", want, out)
+	}
+}
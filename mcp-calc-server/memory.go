@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// メモリの制限
+const (
+	maxHistoryEntries = 1000
+	maxVariables      = 1000
+)
+
+// variableNamePattern は変数名として使える文字列です
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// historyEntry は計算結果の履歴の1件を表します
+type historyEntry struct {
+	ID        int             `json:"id"`
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result"`
+	Value     Number          `json:"value,omitempty"` // 結果が数値の場合のみ
+	Time      time.Time       `json:"time"`
+}
+
+// Ref は結果を参照するための名前（$1 など）を返します
+func (e historyEntry) Ref() string {
+	return "$" + strconv.Itoa(e.ID)
+}
+
+// sessionMemory はセッションごとの計算履歴と名前付き変数を保持します。
+// ツールは並行して呼び出されるため、フィールドへのアクセスは mu で保護します
+type sessionMemory struct {
+	mu sync.Mutex
+
+	History   []historyEntry    `json:"history"`
+	Variables map[string]Number `json:"variables"`
+	NextID    int               `json:"nextId"`
+
+	// path が空でない場合は変更のたびに JSON ファイルへ保存する
+	path string
+}
+
+func newSessionMemory() *sessionMemory {
+	return &sessionMemory{
+		Variables: make(map[string]Number),
+		NextID:    1,
+	}
+}
+
+// loadSessionMemory は path から履歴を読み込みます。ファイルがなければ空のメモリを返します
+func loadSessionMemory(path string) (*sessionMemory, error) {
+	mem := newSessionMemory()
+	mem.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return mem, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, mem); err != nil {
+		return nil, fmt.Errorf("invalid history file %s: %w", path, err)
+	}
+	if mem.Variables == nil {
+		mem.Variables = make(map[string]Number)
+	}
+	if mem.NextID < 1 {
+		mem.NextID = 1
+	}
+	return mem, nil
+}
+
+// clone はファイルに保存しない複製を返します
+func (m *sessionMemory) clone() *sessionMemory {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &sessionMemory{
+		History:   slices.Clone(m.History),
+		Variables: maps.Clone(m.Variables),
+		NextID:    m.NextID,
+	}
+}
+
+// save は履歴をファイルに書き込みます。途中で失敗してもファイルが壊れないよう一時ファイルを経由します。
+// 呼び出し側で mu を保持している必要があります
+func (m *sessionMemory) save() error {
+	if m.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
+
+// record は結果を履歴に追加し、その履歴を返します。value が数値として読める場合は参照用の値として保持します。
+// ファイルへの保存に失敗した場合も履歴には追加され、エラーを返します
+func (m *sessionMemory) record(tool string, args json.RawMessage, result string, value Number) (historyEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := historyEntry{
+		ID:        m.NextID,
+		Tool:      tool,
+		Arguments: args,
+		Result:    result,
+		Time:      time.Now().UTC(),
+	}
+	if _, err := value.Rat(); err == nil {
+		entry.Value = value
+	}
+	m.NextID++
+	m.History = append(m.History, entry)
+	if len(m.History) > maxHistoryEntries {
+		m.History = slices.Clone(m.History[len(m.History)-maxHistoryEntries:])
+	}
+	return entry, m.save()
+}
+
+// entry は ID で履歴を探します
+func (m *sessionMemory) entry(id int) (historyEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lookup(id)
+}
+
+func (m *sessionMemory) lookup(id int) (historyEntry, bool) {
+	for _, e := range m.History {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return historyEntry{}, false
+}
+
+// resolve は $1 のような結果参照や $x のような変数参照を値に解決します
+func (m *sessionMemory) resolve(ref string) (Number, *Error) {
+	name, ok := strings.CutPrefix(ref, "$")
+	if !ok {
+		return "", &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Invalid reference '%s'", ref)}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, err := strconv.Atoi(name); err == nil {
+		e, ok := m.lookup(id)
+		if !ok {
+			return "", &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown result reference '%s'", ref)}
+		}
+		if e.Value == "" {
+			return "", &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Result '%s' is not a number", ref)}
+		}
+		return e.Value, nil
+	}
+	if v, ok := m.Variables[name]; ok {
+		return v, nil
+	}
+	return "", &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown variable reference '%s'", ref)}
+}
+
+// env は式の評価で使う変数（$1、x、$x）を返します
+func (m *sessionMemory) env() *evalEnv {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	vars := make(map[string]float64)
+	for _, e := range m.History {
+		if v, err := e.Value.Float64(); err == nil {
+			vars[e.Ref()] = v
+		}
+	}
+	for name, value := range m.Variables {
+		if v, err := value.Float64(); err == nil {
+			vars[name] = v
+			vars["$"+name] = v
+		}
+	}
+	return &evalEnv{vars: vars}
+}
+
+// snapshot は履歴の写しと次に振る ID を返します
+func (m *sessionMemory) snapshot() ([]historyEntry, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.History), m.NextID
+}
+
+// setVariable は変数に値を設定します。変数の数が上限に達している場合は stored に false を返します
+func (m *sessionMemory) setVariable(name string, value Number) (stored bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.Variables[name]; !exists && len(m.Variables) >= maxVariables {
+		return false, nil
+	}
+	m.Variables[name] = value
+	return true, m.save()
+}
+
+// resolveReferences はスキーマで数値とされている引数のうち、"$1" や "$x" のような参照を値に置き換えます
+func (m *sessionMemory) resolveReferences(args json.RawMessage, schema map[string]any) (json.RawMessage, *Error) {
+	if len(args) == 0 || !strings.Contains(string(args), "$") {
+		return args, nil
+	}
+	// 大きな整数の引数を float64 で丸めないように数値はそのまま保つ
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.UseNumber()
+	var decoded any
+	if err := dec.Decode(&decoded); err != nil {
+		// 不正な引数はツール側でエラーにする
+		return args, nil
+	}
+	resolved, err := m.resolveValue(decoded, schema)
+	if err != nil {
+		return nil, err
+	}
+	out, merr := json.Marshal(resolved)
+	if merr != nil {
+		return nil, &Error{Code: ErrorInternalError, Message: merr.Error()}
+	}
+	return out, nil
+}
+
+func (m *sessionMemory) resolveValue(value any, schema map[string]any) (any, *Error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(v, "$") || !schemaAcceptsNumber(schema) {
+			return v, nil
+		}
+		n, err := m.resolve(v)
+		if err != nil {
+			return nil, err
+		}
+		// 整数の引数には 3.000000 のような結果も整数として渡す
+		if slices.Contains(schemaTypes(schema), "integer") {
+			if r, err := n.Rat(); err == nil && r.IsInt() {
+				return json.Number(r.Num().String()), nil
+			}
+		}
+		// JSON の数値として表せない値（1/3 など）は文字列のまま渡す
+		if json.Valid([]byte(n)) {
+			return json.Number(n), nil
+		}
+		return string(n), nil
+	case []any:
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range v {
+			r, err := m.resolveValue(item, items)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = r
+		}
+		return v, nil
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for k, item := range v {
+			propSchema, _ := props[k].(map[string]any)
+			r, err := m.resolveValue(item, propSchema)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = r
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// schemaAcceptsNumber はスキーマが数値を受け付けるかを返します
+func schemaAcceptsNumber(schema map[string]any) bool {
+	types := schemaTypes(schema)
+	return slices.Contains(types, "number") || slices.Contains(types, "integer")
+}
+
+// StoreParams は store ツールのパラメータを表します
+type StoreParams struct {
+	Name  string `json:"name"`
+	Value Number `json:"value"`
+}
+
+// RecallParams は recall ツールのパラメータを表します
+type RecallParams struct {
+	Name string `json:"name"`
+}
+
+// HistoryParams は history ツールのパラメータを表します
+type HistoryParams struct {
+	Limit int `json:"limit,omitempty"`
+}
+
+// getMemoryTools は変数と履歴のツールの一覧を返します
+func getMemoryTools() []toolDef {
+	defs := bindTools([]Tool{
+		{
+			Name:        "store",
+			Description: "Store a value in a named variable. The value may reference an earlier result such as $1. Variables can be used as $name in numeric arguments and as name in expressions",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": map[string]any{
+						"type":        "string",
+						"pattern":     variableNamePattern.String(),
+						"description": "Variable name",
+					},
+					"value": map[string]any{
+						"type":        []string{"number", "string"},
+						"description": "Value to store, or a reference such as $1",
+					},
+				},
+				"required": []string{"name", "value"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"name":  map[string]any{"type": "string"},
+				"value": map[string]any{"type": "string"},
+			}, "name", "value"),
+		},
+		{
+			Name:        "recall",
+			Description: "Recall the value of a named variable or an earlier result such as $1",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": map[string]any{
+						"type":        "string",
+						"description": "Variable name or result reference such as $1",
+					},
+				},
+				"required": []string{"name"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"value": map[string]any{"type": "string", "description": "Stored value in its original notation"},
+			}, "value"),
+		},
+		{
+			Name:        "history",
+			Description: "List earlier results with their IDs ($1, $2, ...)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"limit": map[string]any{
+						"type":        "integer",
+						"minimum":     1,
+						"description": "Maximum number of most recent results to list",
+					},
+				},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"results": map[string]any{
+					"type": "array",
+					"items": outputSchema(map[string]any{
+						"uri":    map[string]any{"type": "string"},
+						"ref":    map[string]any{"type": "string"},
+						"tool":   map[string]any{"type": "string"},
+						"result": map[string]any{"type": "string"},
+					}, "uri", "ref", "tool", "result"),
+				},
+			}, "results"),
+		},
+	}, func(s *Server, _ context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+		return s.handleMemory(name, args)
+	})
+	// 変数や履歴そのものを扱うツールの結果は履歴に記録しない
+	for i := range defs {
+		defs[i].noHistory = true
+	}
+	return defs
+}
+
+// handleMemory は store / recall / history ツールの呼び出しを処理します
+func (s *Server) handleMemory(name string, args json.RawMessage) (*CallToolResult, *Error) {
+	invalid := func(err error) *Error {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+
+	switch name {
+	case "store":
+		var params StoreParams
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, invalid(err)
+		}
+		if !variableNamePattern.MatchString(params.Name) {
+			return nil, &Error{
+				Code:    ErrorInvalidParams,
+				Message: fmt.Sprintf("Invalid variable name '%s'", params.Name),
+			}
+		}
+		if _, err := params.Value.Rat(); err != nil {
+			return nil, invalid(err)
+		}
+		stored, err := s.memory.setVariable(params.Name, params.Value)
+		if err != nil {
+			s.log(LevelError, "memory", "Error saving history", map[string]any{"error": err.Error()})
+		}
+		if !stored {
+			return nil, &Error{
+				Code:    ErrorInvalidParams,
+				Message: fmt.Sprintf("Too many variables (maximum %d)", maxVariables),
+			}
+		}
+		return newStructuredResult(fmt.Sprintf("%s = %s", params.Name, params.Value),
+			map[string]any{"name": params.Name, "value": params.Value}), nil
+
+	case "recall":
+		var params RecallParams
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, invalid(err)
+		}
+		ref := params.Name
+		if !strings.HasPrefix(ref, "$") {
+			ref = "$" + ref
+		}
+		value, err := s.memory.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		return newStructuredResult(string(value), map[string]any{"value": value}), nil
+
+	default:
+		var params HistoryParams
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, invalid(err)
+		}
+		entries, _ := s.memory.snapshot()
+		if params.Limit > 0 && params.Limit < len(entries) {
+			entries = entries[len(entries)-params.Limit:]
+		}
+		text := "No results yet"
+		lines := make([]string, len(entries))
+		items := make([]historySummaryItem, len(entries))
+		for i, e := range entries {
+			lines[i] = fmt.Sprintf("%s = %s (%s)", e.Ref(), e.Result, e.Tool)
+			items[i] = historySummaryItem{URI: historyEntryURI(e.ID), Ref: e.Ref(), Tool: e.Tool, Result: e.Result}
+		}
+		if len(entries) > 0 {
+			text = strings.Join(lines, "\n")
+		}
+		return newStructuredResult(text, map[string]any{"results": items}), nil
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// callText はツールを呼び出し、最初のテキストを返します
+func callText(t *testing.T, s *Server, name, args string) string {
+	t.Helper()
+	result, err := s.handleToolCall(context.Background(), name, json.RawMessage(args))
+	if err != nil {
+		t.Fatalf("%s %s: unexpected error: %v", name, args, err)
+	}
+	return result.Content[0].Text
+}
+
+func TestResultReferences(t *testing.T) {
+	s := newTestServer()
+
+	callText(t, s, "add", `{"a":1,"b":2}`)
+	callText(t, s, "divide", `{"a":1,"b":3,"precision":"exact"}`)
+
+	if got := callText(t, s, "multiply", `{"a":"$1","b":10}`); got != "30.000000" {
+		t.Errorf("expected $1 to resolve to 3, got %s", got)
+	}
+	if got := callText(t, s, "multiply", `{"a":"$2","b":3,"precision":"exact"}`); got != "1" {
+		t.Errorf("expected exact $2 to be kept, got %s", got)
+	}
+	if got := callText(t, s, "mean", `{"values":["$1", 5]}`); got != "4.000000" {
+		t.Errorf("expected reference inside array, got %s", got)
+	}
+	if got := callText(t, s, "evaluate", `{"expression":"$1 * 2 + $3"}`); got != "36.000000" {
+		t.Errorf("expected references in expression, got %s", got)
+	}
+
+	if _, err := s.handleToolCall(context.Background(), "add", json.RawMessage(`{"a":"$99","b":1}`)); err == nil || err.Code != ErrorInvalidParams {
+		t.Errorf("expected unknown reference error, got %v", err)
+	}
+}
+
+func TestRecordedValues(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want Number
+	}{
+		// 6桁に丸めたテキストではなく float64 の値を記録する
+		{"divide", `{"a":1,"b":3}`, "0.3333333333333333"},
+		// 厳密な結果は float64 に丸めずに記録する
+		{"divide", `{"a":1,"b":3,"precision":"exact"}`, "1/3"},
+		{"multiply", `{"a":"1e300","b":"1e300","precision":"exact"}`, "1" + Number(strings.Repeat("0", 600))},
+		{"add", `{"a":12345,"b":1,"format":{"grouping":"myriad"}}`, "12346"},
+		{"factorial", `{"n":25}`, "15511210043330985984000000"},
+		// 整形したテキストを読み戻さず、ハンドラーが返した値を記録する
+		{"matrix_determinant", `{"a":[[1e-7]]}`, "1e-07"},
+		{"matrix_determinant", `{"a":[[1234567,0],[0,1]],"format":{"notation":"scientific","digits":2}}`, "1.234567e+06"},
+		{"matrix_rank", `{"a":[[1,2],[2,4]]}`, "1"},
+		{"complex_add", `{"a":1e-7,"b":{"re":0}}`, "1e-07"},
+		{"convert_base", `{"value":"ff","from":16,"to":2}`, "255"},
+		{"mode", `{"values":[0.1,0.1,0.3]}`, "0.1"},
+		// 数値でない結果は参照用の値を持たない
+		{"complex_add", `{"a":1,"b":"2i"}`, ""},
+		{"mode", `{"values":[1,2]}`, ""},
+	}
+
+	for i, tt := range tests {
+		callText(t, s, tt.name, tt.args)
+		entry, _ := s.memory.entry(i + 1)
+		if entry.Value != tt.want {
+			t.Errorf("%s %s: expected %s, got %s", tt.name, tt.args, tt.want, entry.Value)
+		}
+	}
+}
+
+func TestResultIDsInContent(t *testing.T) {
+	s := newTestServer()
+	result, err := s.handleToolCall(context.Background(), "add", json.RawMessage(`{"a":1,"b":2}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content := result.Content
+	if len(content) != 2 || content[1].Text != "Stored as $1" {
+		t.Errorf("expected result id in content, got %v", content)
+	}
+}
+
+func TestStoreAndRecall(t *testing.T) {
+	s := newTestServer()
+
+	callText(t, s, "add", `{"a":40,"b":2}`)
+	if got := callText(t, s, "store", `{"name":"answer","value":"$1"}`); got != "answer = 42" {
+		t.Errorf("unexpected store result: %s", got)
+	}
+	if got := callText(t, s, "recall", `{"name":"answer"}`); got != "42" {
+		t.Errorf("unexpected recall result: %s", got)
+	}
+	if got := callText(t, s, "subtract", `{"a":"$answer","b":2}`); got != "40.000000" {
+		t.Errorf("expected variable reference, got %s", got)
+	}
+	if got := callText(t, s, "evaluate", `{"expression":"answer / 2"}`); got != "21.000000" {
+		t.Errorf("expected variable in expression, got %s", got)
+	}
+
+	if _, err := s.handleToolCall(context.Background(), "store", json.RawMessage(`{"name":"1x","value":1}`)); err == nil {
+		t.Error("expected invalid variable name error")
+	}
+	if _, err := s.handleToolCall(context.Background(), "recall", json.RawMessage(`{"name":"missing"}`)); err == nil {
+		t.Error("expected unknown variable error")
+	}
+}
+
+// newServerWithHistory は path の履歴を読み込んだサーバーを生成します
+func newServerWithHistory(t *testing.T, path string) *Server {
+	t.Helper()
+	mem, err := loadSessionMemory(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := newServer(withMemory(mem))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	first := newServerWithHistory(t, path)
+	callText(t, first, "add", `{"a":1,"b":1}`)
+	callText(t, first, "store", `{"name":"x","value":5}`)
+
+	second := newServerWithHistory(t, path)
+	if got := callText(t, second, "recall", `{"name":"$1"}`); got != "2" {
+		t.Errorf("expected $1 to be restored, got %s", got)
+	}
+	if got := callText(t, second, "recall", `{"name":"x"}`); got != "5" {
+		t.Errorf("expected x to be restored, got %s", got)
+	}
+	// ID は前回の続きから振られる
+	callText(t, second, "add", `{"a":2,"b":2}`)
+	if got := callText(t, second, "history", `{"limit":1}`); got != "$2 = 4.000000 (add)" {
+		t.Errorf("unexpected history: %s", got)
+	}
+}
+
+func TestHistoryFileWithHTTPSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	callText(t, newServerWithHistory(t, path), "add", `{"a":1,"b":1}`)
+
+	memories, err := historyMemories(path, "http")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sessions := make([]*Server, 2)
+	for i := range sessions {
+		if sessions[i], err = newServer(withMemory(memories())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// どのセッションもファイルの内容から始まり、その後の結果と変数はセッションごとに分かれる
+	for i, s := range sessions {
+		if got := callText(t, s, "recall", `{"name":"$1"}`); got != "2" {
+			t.Errorf("session %d: expected $1 from the history file, got %s", i, got)
+		}
+		callText(t, s, "add", fmt.Sprintf(`{"a":%d,"b":0}`, 10*(i+1)))
+	}
+	for i, s := range sessions {
+		if got := callText(t, s, "recall", `{"name":"$2"}`); got != fmt.Sprint(10*(i+1)) {
+			t.Errorf("session %d: expected its own $2, got %s", i, got)
+		}
+	}
+	callText(t, sessions[0], "store", `{"name":"x","value":5}`)
+	if _, err := sessions[1].handleToolCall(context.Background(), "recall", json.RawMessage(`{"name":"x"}`)); err == nil {
+		t.Error("expected variables not to leak between sessions")
+	}
+
+	// HTTP のセッションはファイルに保存しない
+	reloaded, err := loadSessionMemory(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if history, next := reloaded.snapshot(); len(history) != 1 || next != 2 {
+		t.Errorf("expected the history file to be unchanged, got %d results (next id %d)", len(history), next)
+	}
+}
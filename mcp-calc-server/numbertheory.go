@@ -0,0 +1,584 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 整数ツールの制限
+const (
+	maxIntegerDigits   = 10000 // 引数の整数の最大桁数
+	maxBinomialN       = 100_000
+	maxFibonacciN      = 1_000_000
+	maxFactorDigits    = 60
+	trialDivisionLimit = 10000
+	maxRhoIterations   = 1 << 20 // 1つの合成数に対する Pollard の ρ 法の反復の上限
+	primalityRounds    = 20      // ProbablyPrime の Miller-Rabin の回数
+)
+
+// bigIntArg は任意精度の整数の引数を表します。JSON の整数と10進数の文字列の両方を受け付け、float64 を経由しません
+type bigIntArg struct {
+	big.Int
+}
+
+// UnmarshalJSON は整数を数値または文字列から読み込みます
+func (b *bigIntArg) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		s = strings.TrimSpace(s)
+	}
+	if len(strings.TrimLeft(s, "+-")) > maxIntegerDigits {
+		return fmt.Errorf("integers must not exceed %d digits", maxIntegerDigits)
+	}
+	// 1e3 や 10.0 のような表記は float64 で正確に表せる範囲の整数に限って受け付ける
+	if strings.ContainsAny(s, ".eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f != math.Trunc(f) || math.Abs(f) > maxExactInteger {
+			return fmt.Errorf("expected an integer, got %s", data)
+		}
+		b.SetInt64(int64(f))
+		return nil
+	}
+	if _, ok := b.SetString(s, 10); !ok {
+		return fmt.Errorf("expected an integer, got %s", data)
+	}
+	return nil
+}
+
+// integerSchema は任意精度の整数を受け付ける入力スキーマを生成します
+func integerSchema(desc string) map[string]any {
+	return map[string]any{
+		"type":        []string{"integer", "string"},
+		"pattern":     `^\s*[+-]?[0-9]+\s*$`,
+		"description": desc + ". Pass large values as decimal strings to keep them exact",
+	}
+}
+
+// IntegerListParams は gcd / lcm ツールのパラメータを表します
+type IntegerListParams struct {
+	Values []bigIntArg `json:"values"`
+}
+
+// ModPowParams は mod_pow ツールのパラメータを表します
+type ModPowParams struct {
+	Base     bigIntArg `json:"base"`
+	Exponent bigIntArg `json:"exponent"`
+	Modulus  bigIntArg `json:"modulus"`
+}
+
+// ModInverseParams は mod_inverse ツールのパラメータを表します
+type ModInverseParams struct {
+	A       bigIntArg `json:"a"`
+	Modulus bigIntArg `json:"modulus"`
+}
+
+// IntegerParams は整数を1つ受け取るツールのパラメータを表します
+type IntegerParams struct {
+	N bigIntArg `json:"n"`
+}
+
+// BinomialParams は binomial ツールのパラメータを表します
+type BinomialParams struct {
+	N int `json:"n"`
+	K int `json:"k"`
+}
+
+// FibonacciParams は fibonacci ツールのパラメータを表します
+type FibonacciParams struct {
+	N int `json:"n"`
+}
+
+// primeFactor は素因数とその指数です
+type primeFactor struct {
+	Prime    string `json:"prime"`
+	Exponent int    `json:"exponent"`
+}
+
+// getNumberTheoryTools は任意精度の整数ツールの一覧を返します
+func getNumberTheoryTools() []toolDef {
+	exact := outputSchema(map[string]any{
+		"value":  map[string]any{"type": "string", "description": "Exact decimal digits of the result"},
+		"digits": map[string]any{"type": "integer"},
+	}, "value", "digits")
+	integers := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"values": map[string]any{
+				"type":     "array",
+				"items":    integerSchema("Integer"),
+				"minItems": 1,
+			},
+		},
+		"required": []string{"values"},
+	}
+
+	return bindTools([]Tool{
+		{
+			Name:         "gcd",
+			Description:  "Greatest common divisor of integers of any size. For two integers the Bézout coefficients x, y with ax + by = gcd are also returned",
+			InputSchema:  integers,
+			OutputSchema: exact,
+		},
+		{
+			Name:         "lcm",
+			Description:  "Least common multiple of integers of any size",
+			InputSchema:  integers,
+			OutputSchema: exact,
+		},
+		{
+			Name:        "mod_pow",
+			Description: "Modular exponentiation base^exponent mod modulus. A negative exponent uses the modular inverse of the base",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"base":     integerSchema("Base"),
+					"exponent": integerSchema("Exponent"),
+					"modulus":  integerSchema("Positive modulus"),
+				},
+				"required": []string{"base", "exponent", "modulus"},
+			},
+			OutputSchema: exact,
+		},
+		{
+			Name:        "mod_inverse",
+			Description: "Modular multiplicative inverse x of a with a·x ≡ 1 (mod modulus)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"a":       integerSchema("Integer to invert"),
+					"modulus": integerSchema("Positive modulus"),
+				},
+				"required": []string{"a", "modulus"},
+			},
+			OutputSchema: exact,
+		},
+		{
+			Name:        "is_prime",
+			Description: "Primality test (Baillie-PSW with Miller-Rabin rounds). The answer is certain below 2^64",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"n": integerSchema("Integer to test")},
+				"required":   []string{"n"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"prime":   map[string]any{"type": "boolean"},
+				"certain": map[string]any{"type": "boolean", "description": "False when the answer is probabilistic (error below 4^-20)"},
+			}, "prime", "certain"),
+		},
+		{
+			Name:        "factorize",
+			Description: fmt.Sprintf("Prime factorisation of an integer of up to %d digits with trial division and Pollard's rho", maxFactorDigits),
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"n": integerSchema("Integer to factor (at least 2 in absolute value)")},
+				"required":   []string{"n"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"factors": map[string]any{
+					"type": "array",
+					"items": outputSchema(map[string]any{
+						"prime":    map[string]any{"type": "string"},
+						"exponent": map[string]any{"type": "integer"},
+					}, "prime", "exponent"),
+				},
+				"complete":   map[string]any{"type": "boolean"},
+				"unfactored": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Composite parts that could not be split within the iteration limit"},
+			}, "factors", "complete"),
+		},
+		{
+			Name:        "binomial",
+			Description: "Exact binomial coefficient C(n, k)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"n": map[string]any{"type": "integer", "minimum": 0, "maximum": maxBinomialN},
+					"k": map[string]any{"type": "integer", "minimum": 0, "maximum": maxBinomialN},
+				},
+				"required": []string{"n", "k"},
+			},
+			OutputSchema: exact,
+		},
+		{
+			Name:        "fibonacci",
+			Description: "Exact n-th Fibonacci number F(n), with F(0) = 0 and F(-n) = (-1)^(n+1) F(n)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"n": map[string]any{"type": "integer", "minimum": -maxFibonacciN, "maximum": maxFibonacciN},
+				},
+				"required": []string{"n"},
+			},
+			OutputSchema: exact,
+		},
+	}, (*Server).handleNumberTheory)
+}
+
+// handleNumberTheory は整数ツールの呼び出しを処理します
+func (s *Server) handleNumberTheory(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	switch name {
+	case "gcd", "lcm":
+		var params IntegerListParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		if name == "gcd" {
+			return gcd(params.Values), nil
+		}
+		return lcm(params.Values), nil
+	case "mod_pow":
+		var params ModPowParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return modPow(&params.Base.Int, &params.Exponent.Int, &params.Modulus.Int)
+	case "mod_inverse":
+		var params ModInverseParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		if err := checkModulus(&params.Modulus.Int); err != nil {
+			return nil, err
+		}
+		inv, err := modInverse(&params.A.Int, &params.Modulus.Int)
+		if err != nil {
+			return nil, err
+		}
+		return exactResult(inv), nil
+	case "is_prime":
+		var params IntegerParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return isPrime(&params.N.Int), nil
+	case "factorize":
+		var params IntegerParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return factorize(ctx, &params.N.Int)
+	case "binomial":
+		var params BinomialParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return binomial(params.N, params.K)
+	case "fibonacci":
+		var params FibonacciParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return fibonacci(ctx, params.N)
+	default:
+		return nil, unknownTool(name)
+	}
+}
+
+// exactResult は整数の結果を10進数の文字列で返します
+func exactResult(v *big.Int) *CallToolResult {
+	text := v.String()
+	return newStructuredResult(text, map[string]any{"value": text, "digits": len(strings.TrimPrefix(text, "-"))})
+}
+
+// gcd は最大公約数を求めます。結果は常に 0 以上です
+func gcd(values []bigIntArg) *CallToolResult {
+	g := new(big.Int).Abs(&values[0].Int)
+	if len(values) == 2 {
+		// 拡張ユークリッドの互除法でベズー係数も求める
+		var x, y big.Int
+		a, b := new(big.Int).Abs(&values[0].Int), new(big.Int).Abs(&values[1].Int)
+		g.GCD(&x, &y, a, b)
+		// 負の引数では係数の符号を合わせる
+		if values[0].Sign() < 0 {
+			x.Neg(&x)
+		}
+		if values[1].Sign() < 0 {
+			y.Neg(&y)
+		}
+		result := exactResult(g)
+		data := result.StructuredContent.(map[string]any)
+		data["x"], data["y"] = x.String(), y.String()
+		return result
+	}
+	for _, v := range values[1:] {
+		g.GCD(nil, nil, g, new(big.Int).Abs(&v.Int))
+	}
+	return exactResult(g)
+}
+
+// lcm は最小公倍数を求めます。0 を含む場合は 0 です
+func lcm(values []bigIntArg) *CallToolResult {
+	l := new(big.Int).Abs(&values[0].Int)
+	var g big.Int
+	for _, v := range values[1:] {
+		if l.Sign() == 0 || v.Sign() == 0 {
+			return exactResult(new(big.Int))
+		}
+		a := new(big.Int).Abs(&v.Int)
+		g.GCD(nil, nil, l, a)
+		l.Mul(l.Quo(l, &g), a)
+	}
+	return exactResult(l)
+}
+
+// checkModulus は法が正の整数であることを確認します
+func checkModulus(m *big.Int) *Error {
+	if m.Sign() <= 0 {
+		return &Error{Code: ErrorInvalidParams, Message: "modulus must be positive"}
+	}
+	return nil
+}
+
+// modInverse は a の法 m での逆元を求めます
+func modInverse(a, m *big.Int) (*big.Int, *Error) {
+	if m.Cmp(big.NewInt(1)) == 0 {
+		return new(big.Int), nil
+	}
+	inv := new(big.Int).ModInverse(new(big.Int).Mod(a, m), m)
+	if inv == nil {
+		return nil, &Error{
+			Code:    ErrorMathDomain,
+			Message: fmt.Sprintf("%s has no inverse modulo %s", a, m),
+			Data:    map[string]any{"gcd": new(big.Int).GCD(nil, nil, new(big.Int).Mod(a, m), m).String()},
+		}
+	}
+	return inv, nil
+}
+
+// modPow は base^exponent mod modulus を求めます
+func modPow(base, exponent, modulus *big.Int) (*CallToolResult, *Error) {
+	if err := checkModulus(modulus); err != nil {
+		return nil, err
+	}
+	b := new(big.Int).Mod(base, modulus)
+	e := new(big.Int).Set(exponent)
+	if e.Sign() < 0 {
+		inv, err := modInverse(b, modulus)
+		if err != nil {
+			return nil, err
+		}
+		b, e = inv, e.Neg(e)
+	}
+	return exactResult(new(big.Int).Exp(b, e, modulus)), nil
+}
+
+// isPrime は n が素数かどうかを判定します
+func isPrime(n *big.Int) *CallToolResult {
+	prime := n.ProbablyPrime(primalityRounds)
+	// ProbablyPrime は 2^64 未満では常に正しい
+	certain := n.Sign() < 0 || n.BitLen() <= 64
+	verdict := "is prime"
+	if !prime {
+		verdict = "is not prime"
+	} else if !certain {
+		verdict = "is probably prime"
+	}
+	return newStructuredResult(fmt.Sprintf("%s %s", n, verdict), map[string]any{"prime": prime, "certain": certain || !prime})
+}
+
+// factorize は n を素因数分解します。分解できなかった合成数は unfactored に残します
+func factorize(ctx context.Context, n *big.Int) (*CallToolResult, *Error) {
+	abs := new(big.Int).Abs(n)
+	if abs.Cmp(big.NewInt(2)) < 0 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "n must be at least 2 in absolute value"}
+	}
+	if len(abs.String()) > maxFactorDigits {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("n must not exceed %d digits", maxFactorDigits),
+		}
+	}
+
+	counts := make(map[string]int)
+	var primes []*big.Int
+	add := func(p *big.Int) {
+		key := p.String()
+		if counts[key] == 0 {
+			primes = append(primes, p)
+		}
+		counts[key]++
+	}
+
+	// 小さい素因数は試し割りで取り除く
+	rest := new(big.Int).Set(abs)
+	var q, r, d big.Int
+	for i := int64(2); i <= trialDivisionLimit && rest.Cmp(big.NewInt(1)) > 0; i++ {
+		d.SetInt64(i)
+		for {
+			q.QuoRem(rest, &d, &r)
+			if r.Sign() != 0 {
+				break
+			}
+			add(big.NewInt(i))
+			rest.Set(&q)
+		}
+	}
+
+	// 残りは Pollard の ρ 法で分割する
+	var unfactored []string
+	var pending []*big.Int
+	if rest.Cmp(big.NewInt(1)) > 0 {
+		pending = append(pending, rest)
+	}
+	for len(pending) > 0 {
+		m := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if m.ProbablyPrime(primalityRounds) {
+			add(m)
+			continue
+		}
+		f, err := pollardRho(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			unfactored = append(unfactored, m.String())
+			continue
+		}
+		pending = append(pending, f, new(big.Int).Quo(m, f))
+	}
+
+	slices.SortFunc(primes, (*big.Int).Cmp)
+	factors := make([]primeFactor, len(primes))
+	parts := make([]string, 0, len(primes)+len(unfactored)+1)
+	if n.Sign() < 0 {
+		parts = append(parts, "-1")
+	}
+	for i, p := range primes {
+		factors[i] = primeFactor{Prime: p.String(), Exponent: counts[p.String()]}
+		if factors[i].Exponent == 1 {
+			parts = append(parts, factors[i].Prime)
+		} else {
+			parts = append(parts, fmt.Sprintf("%s^%d", factors[i].Prime, factors[i].Exponent))
+		}
+	}
+	for _, u := range unfactored {
+		parts = append(parts, "("+u+")")
+	}
+
+	data := map[string]any{"factors": factors, "complete": len(unfactored) == 0}
+	if len(unfactored) > 0 {
+		data["unfactored"] = unfactored
+	}
+	return newStructuredResult(strings.Join(parts, " × "), data), nil
+}
+
+// pollardRho はブレントの改良を加えた Pollard の ρ 法で合成数 n の自明でない約数を探します。
+// 反復の上限までに見つからなければ nil を返します
+func pollardRho(ctx context.Context, n *big.Int) (*big.Int, *Error) {
+	const batch = 128 // gcd をまとめて計算する反復の数
+	one := big.NewInt(1)
+	step := progressInterval(maxRhoIterations)
+	iterations := 0
+	var g, q, diff, ys big.Int
+
+	for c := int64(1); iterations < maxRhoIterations; c++ {
+		cc := big.NewInt(c)
+		next := func(v *big.Int) {
+			v.Mul(v, v)
+			v.Add(v, cc)
+			v.Mod(v, n)
+		}
+		y, x := big.NewInt(2), new(big.Int)
+		g.SetInt64(1)
+		q.SetInt64(1)
+		for r := 1; g.Cmp(one) == 0 && iterations < maxRhoIterations; r *= 2 {
+			x.Set(y)
+			for range r {
+				next(y)
+			}
+			for k := 0; k < r && g.Cmp(one) == 0; k += batch {
+				ys.Set(y)
+				for range min(batch, r-k) {
+					next(y)
+					diff.Sub(x, y)
+					q.Mul(&q, diff.Abs(&diff))
+					q.Mod(&q, n)
+					iterations++
+					if iterations%step == 0 {
+						if err := checkpoint(ctx, iterations, maxRhoIterations); err != nil {
+							return nil, err
+						}
+					}
+				}
+				g.GCD(nil, nil, &q, n)
+			}
+		}
+		if g.Cmp(n) == 0 {
+			// まとめた gcd で行き過ぎた場合は1歩ずつやり直す
+			for {
+				next(&ys)
+				diff.Sub(x, &ys)
+				g.GCD(nil, nil, diff.Abs(&diff), n)
+				if g.Cmp(one) != 0 {
+					break
+				}
+			}
+		}
+		if g.Cmp(one) != 0 && g.Cmp(n) != 0 {
+			return new(big.Int).Set(&g), nil
+		}
+	}
+	return nil, nil
+}
+
+// binomial は二項係数 C(n, k) を求めます。k > n の場合は 0 です
+func binomial(n, k int) (*CallToolResult, *Error) {
+	if n < 0 || k < 0 || n > maxBinomialN || k > maxBinomialN {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("n and k must be between 0 and %d", maxBinomialN),
+		}
+	}
+	if k > n {
+		return exactResult(new(big.Int)), nil
+	}
+	return exactResult(new(big.Int).Binomial(int64(n), int64(k))), nil
+}
+
+// fibonacci は倍化公式 F(2m) = F(m)(2F(m+1) - F(m))、F(2m+1) = F(m)² + F(m+1)² で F(n) を求めます
+func fibonacci(ctx context.Context, n int) (*CallToolResult, *Error) {
+	if n < -maxFibonacciN || n > maxFibonacciN {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("n must be between %d and %d", -maxFibonacciN, maxFibonacciN),
+		}
+	}
+	m := n
+	if m < 0 {
+		m = -m
+	}
+
+	a, b := new(big.Int), big.NewInt(1) // F(0), F(1)
+	var t, u big.Int
+	bits := big.NewInt(int64(m)).BitLen()
+	for i := bits - 1; i >= 0; i-- {
+		t.Lsh(b, 1)
+		t.Sub(&t, a)
+		t.Mul(&t, a) // F(2k)
+		u.Mul(b, b)
+		b.Mul(a, a)
+		b.Add(b, &u) // F(2k+1)
+		a.Set(&t)
+		if m>>i&1 == 1 {
+			a, b = b, a.Add(a, b)
+		}
+		if err := checkpoint(ctx, bits-i, bits); err != nil {
+			return nil, err
+		}
+	}
+	if n < 0 && m%2 == 0 {
+		a.Neg(a)
+	}
+	return exactResult(a), nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestNumberTheoryTools(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"gcd", `{"values":[12,18]}`, "6"},
+		{"gcd", `{"values":[-12,18,"27"]}`, "3"},
+		{"gcd", `{"values":[0,0]}`, "0"},
+		// float64 では表せない桁数でも正確に計算する
+		{"gcd", `{"values":[123456789012345678901234567890,"987654321098765432109876543210"]}`, "9000000000900000000090"},
+		{"lcm", `{"values":[4,6,10]}`, "60"},
+		{"lcm", `{"values":[4,0]}`, "0"},
+		{"mod_pow", `{"base":4,"exponent":13,"modulus":497}`, "445"},
+		{"mod_pow", `{"base":2,"exponent":"1000000000000000000000","modulus":"1000000007"}`, "741583475"},
+		{"mod_pow", `{"base":3,"exponent":-1,"modulus":11}`, "4"},
+		{"mod_pow", `{"base":-2,"exponent":3,"modulus":5}`, "2"},
+		{"mod_inverse", `{"a":17,"modulus":3120}`, "2753"},
+		{"mod_inverse", `{"a":5,"modulus":1}`, "0"},
+		{"is_prime", `{"n":97}`, "97 is prime"},
+		{"is_prime", `{"n":1}`, "1 is not prime"},
+		{"is_prime", `{"n":"170141183460469231731687303715884105727"}`, "170141183460469231731687303715884105727 is probably prime"},
+		{"factorize", `{"n":360}`, "2^3 × 3^2 × 5"},
+		{"factorize", `{"n":-97}`, "-1 × 97"},
+		// 試し割りでは見つからない大きな素因数
+		{"factorize", `{"n":"1000000016000000063"}`, "1000000007 × 1000000009"},
+		{"factorize", `{"n":"18446744073709551617"}`, "274177 × 67280421310721"},
+		{"binomial", `{"n":10,"k":3}`, "120"},
+		{"binomial", `{"n":3,"k":5}`, "0"},
+		{"binomial", `{"n":100,"k":50}`, "100891344545564193334812497256"},
+		{"fibonacci", `{"n":0}`, "0"},
+		{"fibonacci", `{"n":10}`, "55"},
+		{"fibonacci", `{"n":-10}`, "-55"},
+		{"fibonacci", `{"n":100}`, "354224848179261915075"},
+	}
+
+	for _, tt := range tests {
+		if got := callText(t, s, tt.name, tt.args); got != tt.want {
+			t.Errorf("%s %s: expected %s, got %s", tt.name, tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestNumberTheoryReferences(t *testing.T) {
+	s := newTestServer()
+	// 大きな結果を参照しても桁が落ちない
+	callText(t, s, "fibonacci", `{"n":300}`)
+	want := "222232244629420445529739893461909967206666939096499764990979600"
+	if got := callText(t, s, "gcd", `{"values":["$1","$1"]}`); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got := callText(t, s, "lcm", `{"values":[12345678901234567890123,"$1"]}`); len(got) < 80 {
+		t.Errorf("expected exact lcm, got %s", got)
+	}
+}
+
+func TestGCDBezout(t *testing.T) {
+	s := newTestServer()
+	for _, args := range []string{`{"values":[240,46]}`, `{"values":[-240,46]}`, `{"values":[240,-46]}`} {
+		result, err := s.callTool(context.Background(), "gcd", json.RawMessage(args))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", args, err)
+		}
+		var params IntegerListParams
+		json.Unmarshal([]byte(args), &params)
+		data := result.StructuredContent.(map[string]any)
+		x, _ := new(big.Int).SetString(data["x"].(string), 10)
+		y, _ := new(big.Int).SetString(data["y"].(string), 10)
+		// ax + by = gcd
+		sum := new(big.Int).Add(x.Mul(x, &params.Values[0].Int), y.Mul(y, &params.Values[1].Int))
+		if sum.String() != data["value"] {
+			t.Errorf("%s: expected ax + by = %s, got %s", args, data["value"], sum)
+		}
+	}
+}
+
+func TestFibonacciLarge(t *testing.T) {
+	result, err := fibonacci(context.Background(), 10000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := result.StructuredContent.(map[string]any)
+	// F(10000) は 2090 桁
+	if data["digits"] != 2090 {
+		t.Errorf("expected 2090 digits, got %v", data["digits"])
+	}
+}
+
+func TestNumberTheoryErrors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		code int
+	}{
+		{"gcd", `{"values":[]}`, ErrorInvalidParams},
+		{"gcd", `{"values":[1.5,2]}`, ErrorInvalidParams},
+		{"gcd", `{"values":["12abc",2]}`, ErrorInvalidParams},
+		{"gcd", `{"values":[1e400,2]}`, ErrorInvalidParams},
+		{"mod_pow", `{"base":2,"exponent":3,"modulus":0}`, ErrorInvalidParams},
+		{"mod_pow", `{"base":2,"exponent":-1,"modulus":4}`, ErrorMathDomain},
+		{"mod_inverse", `{"a":6,"modulus":9}`, ErrorMathDomain},
+		{"factorize", `{"n":1}`, ErrorInvalidParams},
+		{"factorize", `{"n":"1234567890123456789012345678901234567890123456789012345678901"}`, ErrorInvalidParams},
+		{"binomial", `{"n":-1,"k":0}`, ErrorInvalidParams},
+		{"fibonacci", `{"n":1000001}`, ErrorInvalidParams},
+	}
+
+	for _, tt := range tests {
+		_, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err == nil || err.Code != tt.code {
+			t.Errorf("%s %s: expected error code %d, got %v", tt.name, tt.args, tt.code, err)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// PrecisionMode は計算に使用する数値表現を表します
+type PrecisionMode string
+
+const (
+	PrecisionFloat    PrecisionMode = "float"    // float64（既定）
+	PrecisionExact    PrecisionMode = "exact"    // big.Rat による厳密な有理数計算
+	PrecisionDecimal  PrecisionMode = "decimal"  // 10進小数（割り切れない場合は digits 桁で丸め）
+	PrecisionBigFloat PrecisionMode = "bigfloat" // big.Float による任意精度浮動小数点
+)
+
+// 既定の桁数
+const (
+	defaultDecimalDigits  = 20
+	defaultBigFloatDigits = 50
+	maxPrecisionDigits    = 10000
+)
+
+// PrecisionOptions は精度モードの指定を表します。
+// ツール呼び出しごと、または initialize 時に experimental.calculator として指定できます
+type PrecisionOptions struct {
+	Mode   PrecisionMode `json:"precision,omitempty"`
+	Digits int           `json:"digits,omitempty"`
+}
+
+// validate は精度モードの指定が正しいかを検証します
+func (o PrecisionOptions) validate() *Error {
+	switch o.Mode {
+	case "", PrecisionFloat, PrecisionExact, PrecisionDecimal, PrecisionBigFloat:
+	default:
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("Unknown precision mode '%s'", o.Mode),
+			Data:    []PrecisionMode{PrecisionFloat, PrecisionExact, PrecisionDecimal, PrecisionBigFloat},
+		}
+	}
+	if o.Digits < 0 || o.Digits > maxPrecisionDigits {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("digits must be between 0 and %d", maxPrecisionDigits),
+		}
+	}
+	return nil
+}
+
+// merge は呼び出しごとの指定を優先して既定値と合成します
+func (o PrecisionOptions) merge(defaults PrecisionOptions) PrecisionOptions {
+	if o.Mode == "" {
+		o.Mode = defaults.Mode
+		if o.Digits == 0 {
+			o.Digits = defaults.Digits
+		}
+	}
+	if o.Mode == "" {
+		o.Mode = PrecisionFloat
+	}
+	return o
+}
+
+// Number は数値または数値文字列として受け取った引数を、元の表記のまま保持します。
+// 文字列で渡された値は float64 を経由せずに厳密に解釈されます
+type Number string
+
+// UnmarshalJSON は JSON の数値と文字列の両方を受け付けます
+func (n *Number) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*n = Number(strings.TrimSpace(s))
+		return nil
+	}
+	var num json.Number
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&num); err != nil {
+		return fmt.Errorf("expected a number or numeric string, got %s", data)
+	}
+	*n = Number(num)
+	return nil
+}
+
+// Float64 は値を float64 として返します
+func (n Number) Float64() (float64, error) {
+	if n == "" {
+		return 0, fmt.Errorf("missing number")
+	}
+	v, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		// "1/3" のような分数表記は有理数を経由して変換する
+		r, rerr := n.Rat()
+		if rerr != nil {
+			return 0, fmt.Errorf("invalid number %q", string(n))
+		}
+		v, _ = r.Float64()
+	}
+	// NaN や無限大、float64 の範囲を超える値は計算に使えない
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%q is not a finite float64 number", string(n))
+	}
+	return v, nil
+}
+
+// Rat は値を有理数として返します。"1/3" のような分数表記も受け付けます
+func (n Number) Rat() (*big.Rat, error) {
+	if n == "" {
+		return nil, fmt.Errorf("missing number")
+	}
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", string(n))
+	}
+	return r, nil
+}
+
+// BigFloat は値を指定された精度（ビット数）の big.Float として返します
+func (n Number) BigFloat(prec uint) (*big.Float, error) {
+	if n == "" {
+		return nil, fmt.Errorf("missing number")
+	}
+	if strings.Contains(string(n), "/") {
+		r, err := n.Rat()
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Float).SetPrec(prec).SetRat(r), nil
+	}
+	f, ok := new(big.Float).SetPrec(prec).SetString(string(n))
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", string(n))
+	}
+	return f, nil
+}
+
+// digitsToPrec は10進の有効桁数を big.Float の精度（ビット数）に変換します
+func digitsToPrec(digits int) uint {
+	return uint(math.Ceil(float64(digits)*math.Log2(10))) + 8
+}
+
+// RoundingMode は10進丸めの方式を表します
+type RoundingMode string
+
+const (
+	RoundHalfEven RoundingMode = "half_even" // 銀行丸め
+	RoundHalfUp   RoundingMode = "half_up"   // 四捨五入
+	RoundDown     RoundingMode = "down"      // 切り捨て（0方向）
+	RoundUp       RoundingMode = "up"        // 切り上げ（0から遠ざかる方向）
+)
+
+// roundRat は有理数を小数点以下 scale 桁に丸めます
+func roundRat(r *big.Rat, scale int, mode RoundingMode) *big.Rat {
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow))
+
+	// 0方向に切り捨てた商と余りを求める
+	q, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if rem.Sign() != 0 {
+		// 余りの2倍と分母を比較して端数が 1/2 より大きいかを判定する
+		cmp := new(big.Int).Abs(new(big.Int).Lsh(rem, 1)).Cmp(scaled.Denom())
+		roundAway := false
+		switch mode {
+		case RoundHalfUp:
+			roundAway = cmp >= 0
+		case RoundHalfEven:
+			roundAway = cmp > 0 || (cmp == 0 && q.Bit(0) == 1)
+		case RoundDown:
+			roundAway = false
+		case RoundUp:
+			roundAway = true
+		}
+		if roundAway {
+			q.Add(q, big.NewInt(int64(scaled.Sign())))
+		}
+	}
+	return new(big.Rat).SetFrac(q, pow)
+}
+
+// terminatingScale は有理数が有限小数で表せる場合にその小数点以下の桁数を返します
+func terminatingScale(r *big.Rat) (int, bool) {
+	d := new(big.Int).Set(r.Denom())
+	two, five := big.NewInt(2), big.NewInt(5)
+	var twos, fives int
+	mod := new(big.Int)
+	for {
+		if _, m := new(big.Int).QuoRem(d, two, mod); m.Sign() != 0 {
+			break
+		}
+		d.Quo(d, two)
+		twos++
+	}
+	for {
+		if _, m := new(big.Int).QuoRem(d, five, mod); m.Sign() != 0 {
+			break
+		}
+		d.Quo(d, five)
+		fives++
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	return max(twos, fives), true
+}
+
+// formatRat は有理数を文字列にします。有限小数で表せない場合は分数表記にします
+func formatRat(r *big.Rat) string {
+	if scale, ok := terminatingScale(r); ok {
+		return r.FloatString(scale)
+	}
+	return r.String()
+}
+
+// formatDecimal は有理数を小数点以下 digits 桁以内の10進表記にします
+func formatDecimal(r *big.Rat, digits int, mode RoundingMode) string {
+	if scale, ok := terminatingScale(r); ok && scale <= digits {
+		return r.FloatString(scale)
+	}
+	rounded := roundRat(r, digits, mode)
+	s := rounded.FloatString(digits)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// calculate は二項演算を指定された精度モードで実行し、結果を文字列で返します
+func calculate(op string, a, b Number, opts PrecisionOptions) (string, *Error) {
+	invalid := func(err error) *Error {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+	divideByZero := &Error{
+		Code:    ErrorDivideByZero,
+		Message: "Division by zero",
+	}
+
+	switch opts.Mode {
+	case PrecisionExact, PrecisionDecimal:
+		x, err := a.Rat()
+		if err != nil {
+			return "", invalid(err)
+		}
+		y, err := b.Rat()
+		if err != nil {
+			return "", invalid(err)
+		}
+		z := new(big.Rat)
+		switch op {
+		case "add":
+			z.Add(x, y)
+		case "subtract":
+			z.Sub(x, y)
+		case "multiply":
+			z.Mul(x, y)
+		case "divide":
+			if y.Sign() == 0 {
+				return "", divideByZero
+			}
+			z.Quo(x, y)
+		}
+		if opts.Mode == PrecisionExact {
+			return formatRat(z), nil
+		}
+		digits := opts.Digits
+		if digits == 0 {
+			digits = defaultDecimalDigits
+		}
+		return formatDecimal(z, digits, RoundHalfEven), nil
+
+	case PrecisionBigFloat:
+		digits := opts.Digits
+		if digits == 0 {
+			digits = defaultBigFloatDigits
+		}
+		prec := digitsToPrec(digits)
+		x, err := a.BigFloat(prec)
+		if err != nil {
+			return "", invalid(err)
+		}
+		y, err := b.BigFloat(prec)
+		if err != nil {
+			return "", invalid(err)
+		}
+		z := new(big.Float).SetPrec(prec)
+		switch op {
+		case "add":
+			z.Add(x, y)
+		case "subtract":
+			z.Sub(x, y)
+		case "multiply":
+			z.Mul(x, y)
+		case "divide":
+			if y.Sign() == 0 {
+				return "", divideByZero
+			}
+			z.Quo(x, y)
+		}
+		return z.Text('g', digits), nil
+
+	default:
+		z, err := calculateFloat(op, a, b)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%f", z), nil
+	}
+}
+
+// calculateFloat は二項演算を float64 で実行します
+func calculateFloat(op string, a, b Number) (float64, *Error) {
+	x, err := a.Float64()
+	if err != nil {
+		return 0, &Error{Code: ErrorInvalidParams, Message: "Invalid arguments", Data: err.Error()}
+	}
+	y, err := b.Float64()
+	if err != nil {
+		return 0, &Error{Code: ErrorInvalidParams, Message: "Invalid arguments", Data: err.Error()}
+	}
+	var v float64
+	switch op {
+	case "add":
+		v = x + y
+	case "subtract":
+		v = x - y
+	case "multiply":
+		v = x * y
+	case "divide":
+		if y == 0 {
+			return 0, &Error{Code: ErrorDivideByZero, Message: "Division by zero"}
+		}
+		v = x / y
+	default:
+		return 0, unknownTool(op)
+	}
+	if math.IsInf(v, 0) {
+		return 0, &Error{Code: ErrorMathDomain, Message: fmt.Sprintf("Result of %s is out of range", op)}
+	}
+	return v, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestNumberUnmarshal(t *testing.T) {
+	var params CalcParams
+	if err := json.Unmarshal([]byte(`{"a": "0.1", "b": 12345678901234567890123}`), &params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params.A != "0.1" {
+		t.Errorf("expected a to be %q, got %q", "0.1", params.A)
+	}
+	if params.B != "12345678901234567890123" {
+		t.Errorf("expected b to keep its digits, got %q", params.B)
+	}
+
+	if err := json.Unmarshal([]byte(`{"a": true}`), &params); err == nil {
+		t.Error("expected error for boolean argument")
+	}
+}
+
+func TestCalculatePrecisionModes(t *testing.T) {
+	tests := []struct {
+		op   string
+		a, b Number
+		opts PrecisionOptions
+		want string
+	}{
+		{"add", "0.1", "0.2", PrecisionOptions{Mode: PrecisionFloat}, "0.300000"},
+		{"add", "0.1", "0.2", PrecisionOptions{Mode: PrecisionExact}, "0.3"},
+		{"divide", "1", "3", PrecisionOptions{Mode: PrecisionExact}, "1/3"},
+		{"multiply", "12345678901234567890", "98765432109876543210", PrecisionOptions{Mode: PrecisionExact}, "1219326311370217952237463801111263526900"},
+		{"subtract", "0.0000001", "0.00000001", PrecisionOptions{Mode: PrecisionExact}, "0.00000009"},
+		{"divide", "2", "3", PrecisionOptions{Mode: PrecisionDecimal, Digits: 5}, "0.66667"},
+		{"divide", "1", "8", PrecisionOptions{Mode: PrecisionDecimal, Digits: 2}, "0.12"},
+		{"divide", "1", "4", PrecisionOptions{Mode: PrecisionDecimal}, "0.25"},
+		{"divide", "1", "3", PrecisionOptions{Mode: PrecisionBigFloat, Digits: 30}, "0.333333333333333333333333333333"},
+	}
+
+	for _, tt := range tests {
+		got, err := calculate(tt.op, tt.a, tt.b, tt.opts)
+		if err != nil {
+			t.Errorf("%s(%s, %s) %s: unexpected error: %v", tt.op, tt.a, tt.b, tt.opts.Mode, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s(%s, %s) %s: expected %q, got %q", tt.op, tt.a, tt.b, tt.opts.Mode, tt.want, got)
+		}
+	}
+}
+
+func TestCalculateDivideByZero(t *testing.T) {
+	for _, mode := range []PrecisionMode{PrecisionFloat, PrecisionExact, PrecisionDecimal, PrecisionBigFloat} {
+		_, err := calculate("divide", "1", "0", PrecisionOptions{Mode: mode})
+		if err == nil || err.Code != ErrorDivideByZero {
+			t.Errorf("%s: expected division by zero error, got %v", mode, err)
+		}
+	}
+}
+
+func TestNumberFloat64NonFinite(t *testing.T) {
+	// float64 の範囲を超える値も無限大にせずエラーにする
+	for _, n := range []Number{"NaN", "Inf", "-inf", "1e400", "1e400/3"} {
+		if v, err := n.Float64(); err == nil {
+			t.Errorf("%s: expected error, got %v", n, v)
+		}
+	}
+	if v, err := Number("1e308").Float64(); err != nil || v != 1e308 {
+		t.Errorf("expected 1e308, got %v (%v)", v, err)
+	}
+}
+
+func TestRoundRat(t *testing.T) {
+	tests := []struct {
+		in   string
+		mode RoundingMode
+		want string
+	}{
+		{"2.5", RoundHalfEven, "2"},
+		{"3.5", RoundHalfEven, "4"},
+		{"2.5", RoundHalfUp, "3"},
+		{"-2.5", RoundHalfUp, "-3"},
+		{"2.9", RoundDown, "2"},
+		{"-2.9", RoundDown, "-2"},
+		{"2.1", RoundUp, "3"},
+		{"-2.1", RoundUp, "-3"},
+		{"2", RoundUp, "2"},
+	}
+
+	for _, tt := range tests {
+		r, _ := new(big.Rat).SetString(tt.in)
+		got := roundRat(r, 0, tt.mode).FloatString(0)
+		if got != tt.want {
+			t.Errorf("round(%s, %s): expected %s, got %s", tt.in, tt.mode, tt.want, got)
+		}
+	}
+}
+
+func TestPrecisionOptionsMerge(t *testing.T) {
+	defaults := PrecisionOptions{Mode: PrecisionDecimal, Digits: 4}
+
+	if got := (PrecisionOptions{}).merge(defaults); got != defaults {
+		t.Errorf("expected defaults %+v, got %+v", defaults, got)
+	}
+	if got := (PrecisionOptions{Mode: PrecisionExact}).merge(defaults); got.Mode != PrecisionExact || got.Digits != 0 {
+		t.Errorf("expected per-call mode to win, got %+v", got)
+	}
+	if got := (PrecisionOptions{}).merge(PrecisionOptions{}); got.Mode != PrecisionFloat {
+		t.Errorf("expected float fallback, got %+v", got)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// RequestMeta はリクエストの _meta を表します
+type RequestMeta struct {
+	// ProgressToken が指定されていると、サーバーは notifications/progress で進捗を通知します
+	ProgressToken json.RawMessage `json:"progressToken,omitempty"`
+}
+
+// ProgressParams は notifications/progress のパラメータを表します
+type ProgressParams struct {
+	ProgressToken json.RawMessage `json:"progressToken"`
+	Progress      float64         `json:"progress"`
+	Total         float64         `json:"total,omitempty"`
+	Message       string          `json:"message,omitempty"`
+}
+
+// progressSteps は1回の処理で送る進捗通知のおおよその回数です
+const progressSteps = 100
+
+type progressKey struct{}
+
+// progressReporter は1つのリクエストの進捗を通知します。
+// 仕様に従い、progress は呼び出しごとに必ず増加するようにします
+type progressReporter struct {
+	token  json.RawMessage
+	notify func(method string, params any)
+
+	mu   sync.Mutex
+	last float64
+	sent bool
+}
+
+// withProgress はプログレストークンに進捗を通知するコンテキストを返します
+func (s *Server) withProgress(ctx context.Context, token json.RawMessage) context.Context {
+	if len(token) == 0 {
+		return ctx
+	}
+	notify := func(method string, params any) { s.notifyContext(ctx, method, params) }
+	return context.WithValue(ctx, progressKey{}, &progressReporter{token: token, notify: notify})
+}
+
+// reportProgress は進捗を通知します。プログレストークンがない場合は何もしません
+func reportProgress(ctx context.Context, progress, total float64) {
+	r, ok := ctx.Value(progressKey{}).(*progressReporter)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sent && progress <= r.last {
+		return
+	}
+	r.last, r.sent = progress, true
+	r.notify("notifications/progress", ProgressParams{
+		ProgressToken: r.token,
+		Progress:      progress,
+		Total:         total,
+	})
+}
+
+// progressInterval は total 回の繰り返しのうち何回ごとに進捗を通知するかを返します
+func progressInterval(total int) int {
+	return max(1, total/progressSteps)
+}
+
+// checkpoint は長い計算の途中で進捗を通知し、リクエストがキャンセルされていればエラーを返します
+func checkpoint(ctx context.Context, done, total int) *Error {
+	if err := ctx.Err(); err != nil {
+		return requestCancelled(err)
+	}
+	reportProgress(ctx, float64(done), float64(total))
+	return nil
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxCompletionValues は completion/complete で一度に返す候補の上限です（仕様で 100 件まで）
+const maxCompletionValues = 100
+
+// Prompt はサーバーが提供するプロンプトテンプレートを表します
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument はプロンプトの引数を表します
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// ListPromptsResult はプロンプト一覧のレスポンスを表します
+type ListPromptsResult struct {
+	Prompts    []Prompt `json:"prompts"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// GetPromptParams は prompts/get のパラメータを表します
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// GetPromptResult は展開されたプロンプトを表します
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptMessage はプロンプトに含まれる1つのメッセージを表します
+type PromptMessage struct {
+	Role    string      `json:"role"`
+	Content TextContent `json:"content"`
+}
+
+// CompleteParams は completion/complete のパラメータを表します
+type CompleteParams struct {
+	Ref struct {
+		Type string `json:"type"`
+		Name string `json:"name,omitempty"` // ref/prompt
+		URI  string `json:"uri,omitempty"`  // ref/resource
+	} `json:"ref"`
+	Argument struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"argument"`
+}
+
+// CompleteResult は補完候補を表します
+type CompleteResult struct {
+	Completion Completion `json:"completion"`
+}
+
+// Completion は補完候補の一覧を表します
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
+// promptArgKind はプロンプト引数の値の型を表します。MCP では引数は文字列で渡されるため、サーバー側で検証します
+type promptArgKind int
+
+const (
+	argString promptArgKind = iota
+	argNumber
+	argInteger
+	argExpression
+	argUnit
+	argEnum
+)
+
+// promptArg はプロンプト引数の定義です
+type promptArg struct {
+	name        string
+	description string
+	required    bool
+	kind        promptArgKind
+	choices     []string // argEnum の選択肢、またはその他の型の補完候補
+}
+
+// promptDef は組み込みプロンプトの定義です
+type promptDef struct {
+	name        string
+	description string
+	args        []promptArg
+	render      func(s *Server, args map[string]string) (string, *Error)
+}
+
+// builtinPrompts は組み込みのプロンプトの一覧です
+var builtinPrompts = []promptDef{
+	{
+		name:        "explain_calculation",
+		description: "Explain how an expression is evaluated step by step, then check the answer with the evaluate tool",
+		args: []promptArg{
+			{name: "expression", description: "Expression to explain, e.g. (3+4)*2^5/7", required: true, kind: argExpression},
+			{name: "precision", description: "Numeric mode to use when checking intermediate results", kind: argEnum,
+				choices: []string{string(PrecisionFloat), string(PrecisionExact), string(PrecisionDecimal), string(PrecisionBigFloat)}},
+		},
+		render: renderExplainCalculation,
+	},
+	{
+		name:        "loan_amortization",
+		description: "Build a loan amortization worksheet with the payment, interest and remaining balance for each period",
+		args: []promptArg{
+			{name: "principal", description: "Amount borrowed", required: true, kind: argNumber},
+			{name: "annual_rate", description: "Annual interest rate in percent, e.g. 1.5", required: true, kind: argNumber},
+			{name: "years", description: "Loan term in years", required: true, kind: argInteger},
+			{name: "payments_per_year", description: "Number of payments per year (default 12)", kind: argInteger,
+				choices: []string{"12", "4", "2", "1"}},
+		},
+		render: renderLoanAmortization,
+	},
+	{
+		name:        "unit_conversion_check",
+		description: "Check a unit conversion by reasoning through the dimensions and comparing with the convert tool",
+		args: []promptArg{
+			{name: "value", description: "Value to convert", required: true, kind: argNumber},
+			{name: "from", description: "Source unit, e.g. 坪 or km/h", required: true, kind: argUnit},
+			{name: "to", description: "Target unit", required: true, kind: argUnit},
+			{name: "expected", description: "Result you expect, to be confirmed or corrected", kind: argNumber},
+		},
+		render: renderUnitConversionCheck,
+	},
+}
+
+// findPrompt は名前で組み込みプロンプトを探します
+func findPrompt(name string) (promptDef, bool) {
+	for _, p := range builtinPrompts {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return promptDef{}, false
+}
+
+// getAvailablePrompts は prompts/list で返すプロンプトの一覧を返します
+func getAvailablePrompts() []Prompt {
+	prompts := make([]Prompt, len(builtinPrompts))
+	for i, p := range builtinPrompts {
+		prompts[i] = Prompt{Name: p.name, Description: p.description}
+		for _, a := range p.args {
+			prompts[i].Arguments = append(prompts[i].Arguments, PromptArgument{
+				Name:        a.name,
+				Description: a.description,
+				Required:    a.required,
+			})
+		}
+	}
+	return prompts
+}
+
+// validate は引数の値を型に従って検証します
+func (a promptArg) validate(value string) *Error {
+	invalid := func(format string, args ...any) *Error {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("Invalid argument '%s': %s", a.name, fmt.Sprintf(format, args...)),
+		}
+	}
+
+	switch a.kind {
+	case argNumber:
+		if _, err := Number(value).Rat(); err != nil {
+			return invalid("expected a number, got %q", value)
+		}
+	case argInteger:
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return invalid("expected a positive integer, got %q", value)
+		}
+	case argExpression:
+		if _, err := parseExpression(value); err != nil {
+			return err.toRPCError(value)
+		}
+	case argUnit:
+		if _, err := parseUnit(value); err != nil {
+			return invalid("%v", err)
+		}
+	case argEnum:
+		if !slices.Contains(a.choices, value) {
+			return invalid("expected one of %s", strings.Join(a.choices, ", "))
+		}
+	}
+	return nil
+}
+
+// handleGetPrompt は prompts/get を処理し、引数を埋め込んだメッセージを返します
+func (s *Server) handleGetPrompt(raw json.RawMessage) (any, *Error) {
+	var params GetPromptParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Invalid params", Data: err.Error()}
+	}
+	p, ok := findPrompt(params.Name)
+	if !ok {
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Prompt '%s' not found", params.Name)}
+	}
+
+	for _, a := range p.args {
+		value, ok := params.Arguments[a.name]
+		if !ok || value == "" {
+			if a.required {
+				return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Missing required argument '%s'", a.name)}
+			}
+			continue
+		}
+		if err := a.validate(value); err != nil {
+			return nil, err
+		}
+	}
+
+	text, err := p.render(s, params.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	return GetPromptResult{
+		Description: p.description,
+		Messages: []PromptMessage{
+			{Role: "user", Content: TextContent{Type: "text", Text: text}},
+		},
+	}, nil
+}
+
+func renderExplainCalculation(s *Server, args map[string]string) (string, *Error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Explain step by step how to evaluate the expression `%s`.\n\n", args["expression"])
+	b.WriteString("Follow operator precedence: parentheses first, then exponentiation (right to left), " +
+		"then multiplication and division, then addition and subtraction (left to right). " +
+		"Show each intermediate result on its own line.\n\n")
+	if mode := args["precision"]; mode != "" {
+		fmt.Fprintf(&b, "Check each intermediate step with the add, subtract, multiply and divide tools using precision \"%s\". ", mode)
+	}
+	b.WriteString("Finally, call the evaluate tool with the whole expression and confirm that it matches your answer.")
+	return b.String(), nil
+}
+
+func renderLoanAmortization(s *Server, args map[string]string) (string, *Error) {
+	principal, _ := Number(args["principal"]).Float64()
+	rate, _ := Number(args["annual_rate"]).Float64()
+	years, _ := strconv.Atoi(args["years"])
+	perYear := 12
+	if v := args["payments_per_year"]; v != "" {
+		perYear, _ = strconv.Atoi(v)
+	}
+
+	n := years * perYear
+	r := rate / 100 / float64(perYear)
+	payment := principal / float64(n)
+	if r != 0 {
+		payment = principal * r / (1 - math.Pow(1+r, -float64(n)))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Prepare a loan amortization worksheet for a loan of %s at %s%% annual interest over %d years with %d payments per year.\n\n",
+		args["principal"], args["annual_rate"], years, perYear)
+	fmt.Fprintf(&b, "- Number of payments: n = %d\n", n)
+	fmt.Fprintf(&b, "- Periodic rate: r = %s / 100 / %d = %g\n", args["annual_rate"], perYear, r)
+	b.WriteString("- Payment: P = principal × r / (1 − (1 + r)^−n)")
+	if r == 0 {
+		b.WriteString(" (with r = 0, P = principal / n)")
+	}
+	fmt.Fprintf(&b, " ≈ %.2f\n\n", payment)
+	b.WriteString("Build a table with the columns period, payment, interest, principal and remaining balance. " +
+		"For each period, interest = balance × r and principal = payment − interest. " +
+		"Use the calculator tools with precision \"decimal\" for each row so that rounding does not accumulate, " +
+		"and adjust the final payment so that the remaining balance is exactly 0. " +
+		"End with the total of all payments and the total interest paid.")
+	return b.String(), nil
+}
+
+func renderUnitConversionCheck(s *Server, args map[string]string) (string, *Error) {
+	value, _ := Number(args["value"]).Float64()
+	from, to := args["from"], args["to"]
+	converted, err := convertUnit(value, from, to)
+	if err != nil {
+		return "", err
+	}
+	fu, _ := parseUnit(from)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Check the conversion of %s %s to %s.\n\n", args["value"], from, to)
+	fmt.Fprintf(&b, "Both units measure %s. ", fu.dim.describe())
+	b.WriteString("Explain the conversion factor between the two units by reducing each of them to SI base units, " +
+		"and show the arithmetic.\n\n")
+	// 既定の小数点以下6桁では小さな値が 0 になるので、サーバーの表記の指定がなければ有効数字で示す
+	opts := s.format.merge(FormatOptions{Notation: NotationSignificant})
+	fmt.Fprintf(&b, "The convert tool gives %s %s.", opts.format(converted), to)
+	if expected := args["expected"]; expected != "" {
+		fmt.Fprintf(&b, " The expected result was %s %s. State whether it is correct, and if not, where the mistake is likely to be.", expected, to)
+	}
+	return b.String(), nil
+}
+
+// handleComplete は completion/complete を処理します。
+// プロンプト引数と calc://history/{id} の id を前方一致で補完します
+func (s *Server) handleComplete(raw json.RawMessage) (any, *Error) {
+	var params CompleteParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Invalid params", Data: err.Error()}
+	}
+
+	var candidates []string
+	switch params.Ref.Type {
+	case "ref/prompt":
+		p, ok := findPrompt(params.Ref.Name)
+		if !ok {
+			return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Prompt '%s' not found", params.Ref.Name)}
+		}
+		idx := slices.IndexFunc(p.args, func(a promptArg) bool { return a.name == params.Argument.Name })
+		if idx < 0 {
+			return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown argument '%s'", params.Argument.Name)}
+		}
+		candidates = s.argumentCandidates(p.args[idx])
+	case "ref/resource":
+		if params.Ref.URI != historyURITemplate {
+			return nil, resourceNotFound(params.Ref.URI)
+		}
+		if params.Argument.Name == "id" {
+			// 新しい結果ほど先に候補に出す
+			history, _ := s.memory.snapshot()
+			for i := len(history) - 1; i >= 0; i-- {
+				candidates = append(candidates, strconv.Itoa(history[i].ID))
+			}
+		}
+	default:
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown reference type '%s'", params.Ref.Type)}
+	}
+
+	var values []string
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToLower(c), strings.ToLower(params.Argument.Value)) {
+			values = append(values, c)
+		}
+	}
+	completion := Completion{Values: values, Total: len(values)}
+	if len(values) > maxCompletionValues {
+		completion.Values = values[:maxCompletionValues]
+		completion.HasMore = true
+	}
+	if completion.Values == nil {
+		completion.Values = []string{}
+	}
+	return CompleteResult{Completion: completion}, nil
+}
+
+// argumentCandidates はプロンプト引数の補完候補を返します
+func (s *Server) argumentCandidates(a promptArg) []string {
+	switch a.kind {
+	case argUnit:
+		return slices.Sorted(maps.Keys(units))
+	case argExpression:
+		// 以前に評価した式を新しい順に候補にする
+		var exprs []string
+		history, _ := s.memory.snapshot()
+		for i := len(history) - 1; i >= 0; i-- {
+			e := history[i]
+			if e.Tool != "evaluate" {
+				continue
+			}
+			var params EvaluateParams
+			if json.Unmarshal(e.Arguments, &params) == nil && !slices.Contains(exprs, params.Expression) {
+				exprs = append(exprs, params.Expression)
+			}
+		}
+		return exprs
+	case argNumber:
+		// 以前の数値の結果を新しい順に候補にする
+		var nums []string
+		history, _ := s.memory.snapshot()
+		for i := len(history) - 1; i >= 0; i-- {
+			if v := string(history[i].Value); v != "" && !slices.Contains(nums, v) {
+				nums = append(nums, v)
+			}
+		}
+		return nums
+	default:
+		return a.choices
+	}
+}
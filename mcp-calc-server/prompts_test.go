@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestListPrompts(t *testing.T) {
+	s := newReadyServer(t)
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	for _, name := range []string{"explain_calculation", "loan_amortization", "unit_conversion_check"} {
+		if !strings.Contains(out, `"name":"`+name+`"`) {
+			t.Errorf("expected prompt %s, got %s", name, out)
+		}
+	}
+	if !strings.Contains(out, `{"name":"expression","description":"Expression to explain, e.g. (3+4)*2^5/7","required":true}`) {
+		t.Errorf("expected typed arguments, got %s", out)
+	}
+}
+
+func TestGetPrompt(t *testing.T) {
+	s := newReadyServer(t)
+
+	tests := []struct {
+		name     string
+		args     string
+		contains string
+	}{
+		{"explain_calculation", `{"expression":"(3+4)*2^5/7","precision":"exact"}`, "`(3+4)*2^5/7`"},
+		{"loan_amortization", `{"principal":"1000000","annual_rate":"1.2","years":"1"}`, "≈ 83875.99"},
+		{"loan_amortization", `{"principal":"1200","annual_rate":"0","years":"1"}`, "≈ 100.00"},
+		{"unit_conversion_check", `{"value":"1","from":"坪","to":"m^2","expected":"3.3"}`, "The convert tool gives 3.30579 m^2"},
+		// 小さな値も 0.000000 に丸めない
+		{"unit_conversion_check", `{"value":"1","from":"nm","to":"m"}`, "The convert tool gives 0.00000000100000 m."},
+	}
+
+	for _, tt := range tests {
+		result, err := s.handleGetPrompt(json.RawMessage(`{"name":"` + tt.name + `","arguments":` + tt.args + `}`))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		msgs := result.(GetPromptResult).Messages
+		if len(msgs) != 1 || msgs[0].Role != "user" || !strings.Contains(msgs[0].Content.Text, tt.contains) {
+			t.Errorf("%s: expected message containing %q, got %+v", tt.name, tt.contains, msgs)
+		}
+	}
+}
+
+func TestGetPromptInvalidArguments(t *testing.T) {
+	s := newReadyServer(t)
+
+	tests := []struct {
+		params string
+		code   int
+	}{
+		{`{"name":"unknown"}`, ErrorInvalidParams},
+		{`{"name":"explain_calculation"}`, ErrorInvalidParams},
+		{`{"name":"explain_calculation","arguments":{"expression":"1 +"}}`, ErrorInvalidParams},
+		{`{"name":"explain_calculation","arguments":{"expression":"1","precision":"fast"}}`, ErrorInvalidParams},
+		{`{"name":"loan_amortization","arguments":{"principal":"abc","annual_rate":"1","years":"1"}}`, ErrorInvalidParams},
+		{`{"name":"loan_amortization","arguments":{"principal":"1","annual_rate":"1","years":"1.5"}}`, ErrorInvalidParams},
+		{`{"name":"unit_conversion_check","arguments":{"value":"1","from":"parsec","to":"m"}}`, ErrorInvalidParams},
+		// 次元が異なる単位は変換エラーになる
+		{`{"name":"unit_conversion_check","arguments":{"value":"1","from":"m","to":"kg"}}`, ErrorUnitMismatch},
+	}
+
+	for _, tt := range tests {
+		if _, err := s.handleGetPrompt(json.RawMessage(tt.params)); err == nil || err.Code != tt.code {
+			t.Errorf("%s: expected code %d, got %v", tt.params, tt.code, err)
+		}
+	}
+}
+
+func TestCompletePromptArguments(t *testing.T) {
+	s := newReadyServer(t)
+	callText(t, s, "evaluate", `{"expression":"2^10"}`)
+	callText(t, s, "evaluate", `{"expression":"sqrt(2)"}`)
+
+	tests := []struct {
+		params string
+		want   []string
+	}{
+		{`{"ref":{"type":"ref/prompt","name":"explain_calculation"},"argument":{"name":"precision","value":"b"}}`, []string{"bigfloat"}},
+		{`{"ref":{"type":"ref/prompt","name":"explain_calculation"},"argument":{"name":"expression","value":""}}`, []string{"sqrt(2)", "2^10"}},
+		{`{"ref":{"type":"ref/prompt","name":"loan_amortization"},"argument":{"name":"payments_per_year","value":"1"}}`, []string{"12", "1"}},
+		{`{"ref":{"type":"ref/prompt","name":"unit_conversion_check"},"argument":{"name":"to","value":"坪"}}`, []string{"坪"}},
+		{`{"ref":{"type":"ref/prompt","name":"unit_conversion_check"},"argument":{"name":"from","value":"km"}}`, []string{"km"}},
+		{`{"ref":{"type":"ref/resource","uri":"calc://history/{id}"},"argument":{"name":"id","value":""}}`, []string{"2", "1"}},
+	}
+
+	for _, tt := range tests {
+		result, err := s.handleComplete(json.RawMessage(tt.params))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.params, err)
+			continue
+		}
+		got := result.(CompleteResult).Completion.Values
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: expected %v, got %v", tt.params, tt.want, got)
+		}
+	}
+}
+
+func TestCompleteGatedByVersion(t *testing.T) {
+	s := newTestServer()
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"completion/complete","params":{"ref":{"type":"ref/prompt","name":"loan_amortization"},"argument":{"name":"years","value":""}}}`)
+	if !strings.Contains(out, `"code":-32601`) {
+		t.Errorf("expected method not found before 2025-03-26, got %s", out)
+	}
+}
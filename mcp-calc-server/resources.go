@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 計算履歴のリソース
+const (
+	historyURI         = "calc://history"
+	historyURITemplate = "calc://history/{id}"
+	resourcePageSize   = 100
+)
+
+// Resource はサーバーが読み取りを提供するリソースを表します
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate は URI テンプレートで表されるリソースの集まりを表します
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ListResourcesParams は resources/list のパラメータを表します
+type ListResourcesParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// ListResourcesResult はリソース一覧のレスポンスを表します
+type ListResourcesResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// ListResourceTemplatesResult はリソーステンプレート一覧のレスポンスを表します
+type ListResourceTemplatesResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+	NextCursor        string             `json:"nextCursor,omitempty"`
+}
+
+// ResourceParams は resources/read と resources/subscribe のパラメータを表します
+type ResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// ReadResourceResult はリソースの内容を表します
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// ResourceContents はテキストで表されたリソースの内容を表します
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// historySummary は calc://history の内容です
+type historySummary struct {
+	Count   int                  `json:"count"`
+	NextID  int                  `json:"nextId"`
+	Results []historySummaryItem `json:"results"`
+}
+
+type historySummaryItem struct {
+	URI    string `json:"uri"`
+	Ref    string `json:"ref"`
+	Tool   string `json:"tool"`
+	Result string `json:"result"`
+}
+
+// historyEntryURI は履歴の1件を表すリソースの URI を返します
+func historyEntryURI(id int) string {
+	return fmt.Sprintf("%s/%d", historyURI, id)
+}
+
+// parseHistoryURI はリソースの URI を解釈します。calc://history の場合は id に 0 を返します
+func parseHistoryURI(uri string) (id int, ok bool) {
+	if uri == historyURI {
+		return 0, true
+	}
+	rest, found := strings.CutPrefix(uri, historyURI+"/")
+	if !found {
+		return 0, false
+	}
+	id, err := strconv.Atoi(rest)
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+// historyResourceTemplates はリソーステンプレートの一覧を返します
+func historyResourceTemplates() []ResourceTemplate {
+	return []ResourceTemplate{
+		{
+			URITemplate: historyURITemplate,
+			Name:        "Calculation result",
+			Description: "A single earlier result with its tool, arguments and value. The id matches the $n reference",
+			MimeType:    "application/json",
+		},
+	}
+}
+
+// handleListResources は計算履歴の要約と各結果をリソースとして返します。
+// cursor は前のページで最後に返した結果の ID です
+func (s *Server) handleListResources(raw json.RawMessage) (any, *Error) {
+	var params ListResourcesParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &Error{Code: ErrorInvalidParams, Message: "Invalid params", Data: err.Error()}
+		}
+	}
+
+	var resources []Resource
+	after := 0
+	if params.Cursor == "" {
+		resources = append(resources, Resource{
+			URI:         historyURI,
+			Name:        "Calculation history",
+			Description: "Summary of all results in this session",
+			MimeType:    "application/json",
+		})
+	} else {
+		n, err := strconv.Atoi(params.Cursor)
+		if err != nil || n < 1 {
+			return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Invalid cursor '%s'", params.Cursor)}
+		}
+		after = n
+	}
+
+	result := ListResourcesResult{Resources: resources}
+	history, _ := s.memory.snapshot()
+	count, last := 0, 0
+	for _, e := range history {
+		if e.ID <= after {
+			continue
+		}
+		if count == resourcePageSize {
+			result.NextCursor = strconv.Itoa(last)
+			break
+		}
+		result.Resources = append(result.Resources, Resource{
+			URI:         historyEntryURI(e.ID),
+			Name:        fmt.Sprintf("%s = %s", e.Ref(), e.Result),
+			Description: fmt.Sprintf("Result of %s at %s", e.Tool, e.Time.Format(time.RFC3339)),
+			MimeType:    "application/json",
+		})
+		count++
+		last = e.ID
+	}
+	if result.Resources == nil {
+		result.Resources = []Resource{}
+	}
+	return result, nil
+}
+
+// handleReadResource は calc://history と calc://history/{id} の内容を JSON で返します
+func (s *Server) handleReadResource(raw json.RawMessage) (any, *Error) {
+	var params ResourceParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Invalid params", Data: err.Error()}
+	}
+
+	id, ok := parseHistoryURI(params.URI)
+	if !ok {
+		return nil, resourceNotFound(params.URI)
+	}
+
+	var v any
+	if id == 0 {
+		history, nextID := s.memory.snapshot()
+		summary := historySummary{
+			Count:   len(history),
+			NextID:  nextID,
+			Results: make([]historySummaryItem, len(history)),
+		}
+		for i, e := range history {
+			summary.Results[i] = historySummaryItem{
+				URI:    historyEntryURI(e.ID),
+				Ref:    e.Ref(),
+				Tool:   e.Tool,
+				Result: e.Result,
+			}
+		}
+		v = summary
+	} else {
+		e, ok := s.memory.entry(id)
+		if !ok {
+			return nil, resourceNotFound(params.URI)
+		}
+		v = e
+	}
+
+	text, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, &Error{Code: ErrorInternalError, Message: err.Error()}
+	}
+	return ReadResourceResult{
+		Contents: []ResourceContents{
+			{
+				URI:      params.URI,
+				MimeType: "application/json",
+				Text:     string(text),
+			},
+		},
+	}, nil
+}
+
+// handleSubscribe は resources/subscribe と resources/unsubscribe を処理します
+func (s *Server) handleSubscribe(method string, raw json.RawMessage) (any, *Error) {
+	var params ResourceParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "Invalid params", Data: err.Error()}
+	}
+	if _, ok := parseHistoryURI(params.URI); !ok {
+		return nil, resourceNotFound(params.URI)
+	}
+
+	if method == "resources/subscribe" {
+		s.subscriptions[params.URI] = true
+	} else {
+		delete(s.subscriptions, params.URI)
+	}
+	return struct{}{}, nil
+}
+
+// notifyResourceUpdated は購読されているリソースが変化したことを通知します
+func (s *Server) notifyResourceUpdated(uri string) {
+	s.mu.Lock()
+	subscribed := s.subscriptions[uri]
+	s.mu.Unlock()
+	if !subscribed {
+		return
+	}
+	s.notify("notifications/resources/updated", ResourceParams{URI: uri})
+}
+
+func resourceNotFound(uri string) *Error {
+	return &Error{
+		Code:    ErrorResourceNotFound,
+		Message: "Resource not found",
+		Data:    map[string]string{"uri": uri},
+	}
+}
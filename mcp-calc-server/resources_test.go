@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// newReadyServer は初期化済みのサーバーを返します
+func newReadyServer(t *testing.T) *Server {
+	t.Helper()
+	s := newTestServer()
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	roundTrip(t, s, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	return s
+}
+
+func TestListResources(t *testing.T) {
+	s := newReadyServer(t)
+	callText(t, s, "add", `{"a":1,"b":2}`)
+	callText(t, s, "multiply", `{"a":"$1","b":2}`)
+
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"resources/list"}`)
+	var resp struct {
+		Result ListResourcesResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("failed to decode %s: %v", out, err)
+	}
+	var uris []string
+	for _, r := range resp.Result.Resources {
+		uris = append(uris, r.URI)
+	}
+	want := "calc://history calc://history/1 calc://history/2"
+	if got := strings.Join(uris, " "); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if resp.Result.NextCursor != "" {
+		t.Errorf("expected no next cursor, got %q", resp.Result.NextCursor)
+	}
+}
+
+func TestListResourcesPagination(t *testing.T) {
+	s := newReadyServer(t)
+	for range resourcePageSize + 5 {
+		callText(t, s, "add", `{"a":1,"b":1}`)
+	}
+
+	first, err := s.handleListResources(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	page := first.(ListResourcesResult)
+	if len(page.Resources) != resourcePageSize+1 || page.NextCursor != "100" {
+		t.Fatalf("expected %d resources and cursor 100, got %d and %q", resourcePageSize+1, len(page.Resources), page.NextCursor)
+	}
+
+	second, err := s.handleListResources(json.RawMessage(`{"cursor":"100"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	page = second.(ListResourcesResult)
+	if len(page.Resources) != 5 || page.Resources[0].URI != "calc://history/101" || page.NextCursor != "" {
+		t.Errorf("unexpected second page: %+v", page)
+	}
+
+	if _, err := s.handleListResources(json.RawMessage(`{"cursor":"bogus"}`)); err == nil || err.Code != ErrorInvalidParams {
+		t.Errorf("expected invalid cursor error, got %v", err)
+	}
+}
+
+func TestListResourceTemplates(t *testing.T) {
+	s := newReadyServer(t)
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"resources/templates/list"}`)
+	if !strings.Contains(out, `"uriTemplate":"calc://history/{id}"`) {
+		t.Errorf("expected history template, got %s", out)
+	}
+}
+
+func TestReadResource(t *testing.T) {
+	s := newReadyServer(t)
+	callText(t, s, "add", `{"a":1,"b":2}`)
+
+	result, err := s.handleReadResource(json.RawMessage(`{"uri":"calc://history/1"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	contents := result.(ReadResourceResult).Contents
+	var entry historyEntry
+	if err := json.Unmarshal([]byte(contents[0].Text), &entry); err != nil {
+		t.Fatalf("failed to decode entry: %v", err)
+	}
+	if entry.Tool != "add" || entry.Value != "3" || contents[0].URI != "calc://history/1" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	result, err = s.handleReadResource(json.RawMessage(`{"uri":"calc://history"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var summary historySummary
+	if err := json.Unmarshal([]byte(result.(ReadResourceResult).Contents[0].Text), &summary); err != nil {
+		t.Fatalf("failed to decode summary: %v", err)
+	}
+	if summary.Count != 1 || summary.Results[0].Ref != "$1" || summary.NextID != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	for _, uri := range []string{"calc://history/2", "calc://history/x", "file:///etc/passwd"} {
+		if _, err := s.handleReadResource(json.RawMessage(`{"uri":"` + uri + `"}`)); err == nil || err.Code != ErrorResourceNotFound {
+			t.Errorf("%s: expected resource not found, got %v", uri, err)
+		}
+	}
+}
+
+func TestResourceSubscription(t *testing.T) {
+	s := newReadyServer(t)
+	var sent []Notification
+	s.send = func(msg any) { sent = append(sent, msg.(Notification)) }
+
+	// 購読前は通知しない
+	callText(t, s, "add", `{"a":1,"b":2}`)
+	if len(sent) != 0 {
+		t.Fatalf("expected no notifications before subscribe, got %v", sent)
+	}
+
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"resources/subscribe","params":{"uri":"calc://history"}}`)
+	if out != `{"jsonrpc":"2.0","result":{},"id":1}` {
+		t.Fatalf("unexpected subscribe response: %s", out)
+	}
+	callText(t, s, "add", `{"a":1,"b":2}`)
+	if len(sent) != 1 || sent[0].Method != "notifications/resources/updated" || sent[0].Params.(ResourceParams).URI != historyURI {
+		t.Errorf("expected updated notification, got %v", sent)
+	}
+
+	roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"resources/unsubscribe","params":{"uri":"calc://history"}}`)
+	callText(t, s, "add", `{"a":1,"b":2}`)
+	if len(sent) != 1 {
+		t.Errorf("expected no notifications after unsubscribe, got %v", sent)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// normalizeSchema は Go の値で書かれたスキーマを JSON の型（[]any や float64）に揃えます
+func normalizeSchema(schema map[string]any) (map[string]any, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// validateArguments はツールの引数を入力スキーマで検証します。
+// 対応するキーワードは type、properties、required、enum、minimum、maximum、
+// minItems、maxItems、items、pattern で、それ以外は無視します
+func validateArguments(args json.RawMessage, schema map[string]any) *Error {
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = json.RawMessage("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+	if err := validateValue(value, schema, ""); err != nil {
+		return &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+	return nil
+}
+
+// validateValue は値を1つのスキーマで検証します。path はエラーに含める引数の位置です
+func validateValue(value any, schema map[string]any, path string) error {
+	if schema == nil {
+		return nil
+	}
+	if types := schemaTypes(schema); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return matchesType(value, t) }) {
+		return fmt.Errorf("%s: expected %s, got %s", describePath(path), strings.Join(types, " or "), jsonType(value))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return jsonEqual(e, value) }) {
+		return fmt.Errorf("%s: must be one of %v", describePath(path), enum)
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil && !math.IsInf(f, 0) {
+			return fmt.Errorf("%s: %v", describePath(path), err)
+		}
+		if min, ok := schema["minimum"].(float64); ok && f < min {
+			return fmt.Errorf("%s: must be at least %v", describePath(path), min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && f > max {
+			return fmt.Errorf("%s: must be at most %v", describePath(path), max)
+		}
+	case string:
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern in schema: %v", describePath(path), err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s: must match %s", describePath(path), pattern)
+			}
+		}
+	case []any:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			return fmt.Errorf("%s: must contain at least %v items", describePath(path), min)
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			return fmt.Errorf("%s: must contain at most %v items", describePath(path), max)
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range v {
+			if err := validateValue(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		required, _ := schema["required"].([]any)
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, present := v[name]; !present {
+					return fmt.Errorf("%s: missing required property %q", describePath(path), name)
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]any)
+		// エラーの報告順を安定させるため名前順に検証する
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			propSchema, _ := props[name].(map[string]any)
+			if err := validateValue(v[name], propSchema, joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// schemaTypes はスキーマの type を文字列の一覧で返します
+func schemaTypes(schema map[string]any) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// matchesType は値が JSON スキーマの型に一致するかを返します
+func matchesType(value any, typ string) bool {
+	switch v := value.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case string:
+		return typ == "string"
+	case []any:
+		return typ == "array"
+	case map[string]any:
+		return typ == "object"
+	case json.Number:
+		if typ == "number" {
+			return true
+		}
+		if typ != "integer" {
+			return false
+		}
+		// 1.0 のような小数部が0の値も整数として受け付ける。float64 の範囲を超える整数もそのまま扱う
+		if f, err := v.Float64(); err == nil {
+			return f == math.Trunc(f)
+		}
+		return !strings.ContainsAny(v.String(), ".eE")
+	}
+	return false
+}
+
+// jsonType は値の JSON の型の名前を返します
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "number"
+	}
+}
+
+// jsonEqual は enum の値と引数の値を比較します
+func jsonEqual(a, b any) bool {
+	switch v := b.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && a == f
+	case string, bool, nil:
+		return a == v
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "arguments"
+	}
+	return path
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateArguments(t *testing.T) {
+	schema, err := normalizeSchema(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"n":    map[string]any{"type": "integer", "minimum": 1, "maximum": 10},
+			"x":    map[string]any{"type": []string{"number", "string"}},
+			"mode": map[string]any{"type": "string", "enum": []PrecisionMode{PrecisionFloat, PrecisionExact}},
+			"name": map[string]any{"type": "string", "pattern": "^[a-z]+$"},
+			"rows": map[string]any{
+				"type":     "array",
+				"minItems": 1,
+				"items":    map[string]any{"type": "array", "items": map[string]any{"type": "number"}},
+			},
+		},
+		"required": []string{"n"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		args string
+		want string // 空の場合は検証に成功する
+	}{
+		{`{"n":3}`, ""},
+		{`{"n":3.0,"x":"1/3","mode":"exact","name":"abc","rows":[[1,2],[3]]}`, ""},
+		{``, `arguments: missing required property "n"`},
+		{`[]`, "arguments: expected object, got array"},
+		{`{"n":2.5}`, "n: expected integer, got number"},
+		{`{"n":0}`, "n: must be at least 1"},
+		{`{"n":11}`, "n: must be at most 10"},
+		{`{"n":1,"x":true}`, "x: expected number or string, got boolean"},
+		{`{"n":1,"mode":"fast"}`, "mode: must be one of [float exact]"},
+		{`{"n":1,"name":"A1"}`, "name: must match ^[a-z]+$"},
+		{`{"n":1,"rows":[]}`, "rows: must contain at least 1 items"},
+		{`{"n":1,"rows":[[1],[2,"x"]]}`, "rows[1][1]: expected number, got string"},
+	}
+
+	for _, tt := range tests {
+		err := validateArguments(json.RawMessage(tt.args), schema)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.args, err.Data)
+		case tt.want != "" && (err == nil || err.Code != ErrorInvalidParams || err.Data != tt.want):
+			t.Errorf("%s: expected %q, got %v", tt.args, tt.want, err)
+		}
+	}
+}
+
+func TestToolCallValidatesInput(t *testing.T) {
+	s := newTestServer()
+
+	// ハンドラーに渡る前にスキーマで検証する
+	_, err := s.handleToolCall(context.Background(), "mean", json.RawMessage(`{"values":[1,"2"]}`))
+	if err == nil || err.Data != "values[1]: expected number, got string" {
+		t.Errorf("expected schema validation error, got %v", err)
+	}
+	// 参照は検証の前に解決される
+	callText(t, s, "add", `{"a":1,"b":2}`)
+	if got := callText(t, s, "factorial", `{"n":"$1"}`); got != "6" {
+		t.Errorf("expected 6, got %s", got)
+	}
+}
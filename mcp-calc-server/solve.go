@@ -0,0 +1,706 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 方程式ツールの既定値と制限
+const (
+	defaultTolerance     = 1e-12
+	defaultMaxIterations = 100
+	maxRootIterations    = 10000
+	maxPolynomialDegree  = 4
+	polishIterations     = 3 // 解析解を元の多項式で磨く Newton 法の回数
+)
+
+// RootFindingMethod は数値的な求根法を表します
+type RootFindingMethod string
+
+const (
+	MethodBisection RootFindingMethod = "bisection"
+	MethodNewton    RootFindingMethod = "newton"
+	MethodBrent     RootFindingMethod = "brent"
+)
+
+// rootStatus は反復計算の終了理由を表します
+type rootStatus string
+
+const (
+	statusConverged     rootStatus = "converged"
+	statusMaxIterations rootStatus = "max_iterations"
+	statusLeftInterval  rootStatus = "left_interval"
+)
+
+// PolynomialParams は solve_polynomial ツールのパラメータを表します
+type PolynomialParams struct {
+	Coefficients []float64 `json:"coefficients"` // 次数の高い順
+}
+
+// RootParams は find_root ツールのパラメータを表します
+type RootParams struct {
+	Expression    string            `json:"expression"`
+	Variable      string            `json:"variable,omitempty"`
+	A             float64           `json:"a"`
+	B             float64           `json:"b"`
+	Method        RootFindingMethod `json:"method,omitempty"`
+	X0            *float64          `json:"x0,omitempty"` // newton の初期値
+	Tolerance     float64           `json:"tolerance,omitempty"`
+	MaxIterations int               `json:"maxIterations,omitempty"`
+}
+
+// DifferentiateParams は differentiate ツールのパラメータを表します
+type DifferentiateParams struct {
+	Expression string  `json:"expression"`
+	Variable   string  `json:"variable,omitempty"`
+	At         float64 `json:"at"`
+	Order      int     `json:"order,omitempty"`
+	Step       float64 `json:"step,omitempty"` // 最初の刻み幅
+}
+
+// rootResult は求根の結果です
+type rootResult struct {
+	Root          float64    `json:"root"`
+	Value         float64    `json:"value"`    // root と同じ値。履歴から $n で参照される
+	Residual      float64    `json:"residual"` // f(root)
+	Iterations    int        `json:"iterations"`
+	Converged     bool       `json:"converged"`
+	Status        rootStatus `json:"status"`
+	ErrorEstimate float64    `json:"errorEstimate"`
+	Method        string     `json:"method"`
+}
+
+// getSolverTools は方程式の求解と数値微分のツールの一覧を返します
+func getSolverTools() []toolDef {
+	convergence := map[string]any{
+		"iterations":    map[string]any{"type": "integer"},
+		"converged":     map[string]any{"type": "boolean"},
+		"status":        map[string]any{"type": "string", "enum": []rootStatus{statusConverged, statusMaxIterations, statusLeftInterval}},
+		"errorEstimate": map[string]any{"type": "number"},
+	}
+	rootOutput := map[string]any{
+		"root":     map[string]any{"type": "number"},
+		"value":    map[string]any{"type": "number", "description": "The root, as recorded in the history"},
+		"residual": map[string]any{"type": "number", "description": "Value of the expression at the root"},
+		"method":   map[string]any{"type": "string"},
+	}
+	for k, v := range convergence {
+		rootOutput[k] = v
+	}
+
+	return bindTools([]Tool{
+		{
+			Name:        "solve_polynomial",
+			Description: fmt.Sprintf("Solve a polynomial equation of degree 1 to %d analytically, including complex roots", maxPolynomialDegree),
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"coefficients": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "number"},
+						"minItems":    2,
+						"maxItems":    maxPolynomialDegree + 1,
+						"description": "Coefficients from the highest degree down, e.g. [1, 0, -2] for x^2 - 2 = 0",
+					},
+					"format": formatSchema,
+				},
+				"required": []string{"coefficients"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"degree": map[string]any{"type": "integer"},
+				"roots": map[string]any{
+					"type": "array",
+					"items": outputSchema(map[string]any{
+						"re": map[string]any{"type": "number"},
+						"im": map[string]any{"type": "number"},
+					}, "re", "im"),
+				},
+				"errorEstimate": map[string]any{"type": "number", "description": "Largest |p(root)| over the roots"},
+			}, "degree", "roots", "errorEstimate"),
+		},
+		{
+			Name:        "find_root",
+			Description: "Find a root of a single-variable expression in [a, b] numerically with bisection, Newton or Brent's method",
+			InputSchema: rootSchema(),
+			OutputSchema: outputSchema(rootOutput,
+				"root", "value", "residual", "iterations", "converged", "status", "errorEstimate", "method"),
+		},
+		{
+			Name:        "differentiate",
+			Description: "Numeric first or second derivative of an expression at a point using Richardson extrapolation",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"expression": map[string]any{"type": "string", "description": "Expression in the variable, e.g. sin(x)*x^2"},
+					"variable":   map[string]any{"type": "string", "description": "Name of the variable (defaults to x)"},
+					"at":         map[string]any{"type": "number", "description": "Point at which to differentiate"},
+					"order":      map[string]any{"type": "integer", "minimum": 1, "maximum": 2, "description": "Order of the derivative (defaults to 1)"},
+					"step":       map[string]any{"type": "number", "description": "Initial step size (defaults to 0.1·max(1, |at|))"},
+					"format":     formatSchema,
+				},
+				"required": []string{"expression", "at"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"value":         map[string]any{"type": "number"},
+				"order":         map[string]any{"type": "integer"},
+				"iterations":    map[string]any{"type": "integer"},
+				"errorEstimate": map[string]any{"type": "number"},
+			}, "value", "order", "iterations", "errorEstimate"),
+		},
+	}, (*Server).handleSolver)
+}
+
+// rootSchema は find_root の入力スキーマです
+func rootSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"expression": map[string]any{"type": "string", "description": "Expression in the variable, e.g. x^3 - 2*x - 5"},
+			"variable":   map[string]any{"type": "string", "description": "Name of the variable (defaults to x)"},
+			"a":          map[string]any{"type": "number", "description": "Lower bound of the search interval"},
+			"b":          map[string]any{"type": "number", "description": "Upper bound of the search interval"},
+			"method": map[string]any{
+				"type":        "string",
+				"enum":        []RootFindingMethod{MethodBisection, MethodNewton, MethodBrent},
+				"description": "Root-finding method (defaults to brent). Bisection and Brent need a sign change over [a, b]",
+			},
+			"x0":            map[string]any{"type": "number", "description": "Starting point for Newton's method (defaults to the midpoint)"},
+			"tolerance":     map[string]any{"type": "number", "minimum": 0, "description": fmt.Sprintf("Absolute tolerance on the root (defaults to %g)", defaultTolerance)},
+			"maxIterations": map[string]any{"type": "integer", "minimum": 1, "maximum": maxRootIterations, "description": fmt.Sprintf("Iteration limit (defaults to %d)", defaultMaxIterations)},
+			"format":        formatSchema,
+		},
+		"required": []string{"expression", "a", "b"},
+	}
+}
+
+// handleSolver は方程式ツールの呼び出しを処理します
+func (s *Server) handleSolver(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	switch name {
+	case "solve_polynomial":
+		var params PolynomialParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		return solvePolynomial(ctx, params.Coefficients)
+	case "find_root":
+		var params RootParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		f, err := compileFunction(params.Expression, params.Variable, s.memory.env())
+		if err != nil {
+			return nil, err
+		}
+		return findRoot(ctx, f, params)
+	case "differentiate":
+		var params DifferentiateParams
+		if err := unmarshalArgs(args, &params); err != nil {
+			return nil, err
+		}
+		f, err := compileFunction(params.Expression, params.Variable, s.memory.env())
+		if err != nil {
+			return nil, err
+		}
+		return differentiate(ctx, f, params)
+	default:
+		return nil, unknownTool(name)
+	}
+}
+
+// solvePolynomial は4次以下の多項式の根を解析的に求めます
+func solvePolynomial(ctx context.Context, coefficients []float64) (*CallToolResult, *Error) {
+	// 先頭の 0 の係数は次数を下げる
+	i := slices.IndexFunc(coefficients, func(c float64) bool { return c != 0 })
+	if i < 0 || i == len(coefficients)-1 {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Polynomial must have degree at least 1",
+		}
+	}
+	coeffs := coefficients[i:]
+	if len(coeffs) > maxPolynomialDegree+1 {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("Polynomials are supported up to degree %d", maxPolynomialDegree),
+		}
+	}
+	for _, c := range coeffs {
+		if math.IsInf(c, 0) || math.IsNaN(c) {
+			return nil, &Error{Code: ErrorInvalidParams, Message: "Coefficients must be finite"}
+		}
+	}
+
+	roots := polynomialRoots(coeffs)
+	var residual float64
+	for i, r := range roots {
+		roots[i] = polishRoot(coeffs, r)
+		residual = max(residual, cmplx.Abs(evalPolynomial(coeffs, roots[i])))
+	}
+	// 実部の小さい順に並べる
+	slices.SortFunc(roots, func(a, b complex128) int {
+		if c := compareFloat(real(a), real(b)); c != 0 {
+			return c
+		}
+		return compareFloat(imag(a), imag(b))
+	})
+
+	values := make([]complexValue, len(roots))
+	parts := make([]string, len(roots))
+	opts := numberFormat(ctx)
+	for i, r := range roots {
+		values[i] = complexValue{Re: real(r) + 0, Im: imag(r) + 0}
+		parts[i] = fmt.Sprintf("x%d = %s", i+1, values[i].format(opts))
+	}
+	return newStructuredResult(strings.Join(parts, "\n"), map[string]any{
+		"degree":        len(coeffs) - 1,
+		"roots":         values,
+		"errorEstimate": residual,
+	}), nil
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// polynomialRoots は次数に応じた公式で根を求めます。coeffs[0] は 0 ではありません
+func polynomialRoots(coeffs []float64) []complex128 {
+	a := coeffs[0]
+	switch len(coeffs) - 1 {
+	case 1:
+		return []complex128{complex(-coeffs[1]/a, 0)}
+	case 2:
+		return quadraticRoots(complex(a, 0), complex(coeffs[1], 0), complex(coeffs[2], 0))
+	case 3:
+		return cubicRoots(coeffs[1]/a, coeffs[2]/a, coeffs[3]/a)
+	default:
+		return quarticRoots(coeffs[1]/a, coeffs[2]/a, coeffs[3]/a, coeffs[4]/a)
+	}
+}
+
+// quadraticRoots は ax² + bx + c = 0 の根を桁落ちしにくい形で求めます
+func quadraticRoots(a, b, c complex128) []complex128 {
+	d := cmplx.Sqrt(b*b - 4*a*c)
+	// b と同じ向きの平方根を選んで引き算による桁落ちを避ける
+	if real(cmplx.Conj(b)*d) < 0 {
+		d = -d
+	}
+	q := -(b + d) / 2
+	if q == 0 {
+		return []complex128{0, 0}
+	}
+	return []complex128{q / a, c / q}
+}
+
+// cubicRoots は x³ + bx² + cx + d = 0 の根をカルダノの公式（3実根の場合は三角関数解）で求めます
+func cubicRoots(b, c, d float64) []complex128 {
+	// x = t - b/3 で t³ + pt + q = 0 に変形する
+	shift := b / 3
+	p := c - b*b/3
+	q := 2*b*b*b/27 - b*c/3 + d
+	disc := q*q/4 + p*p*p/27
+
+	var ts []complex128
+	switch {
+	case p == 0 && q == 0:
+		ts = []complex128{0, 0, 0}
+	case disc > 0:
+		sq := math.Sqrt(disc)
+		u, v := math.Cbrt(-q/2+sq), math.Cbrt(-q/2-sq)
+		re, im := -(u+v)/2, math.Sqrt(3)/2*(u-v)
+		ts = []complex128{complex(u+v, 0), complex(re, im), complex(re, -im)}
+	case disc == 0:
+		ts = []complex128{complex(3*q/p, 0), complex(-3*q/(2*p), 0), complex(-3*q/(2*p), 0)}
+	default:
+		r := 2 * math.Sqrt(-p/3)
+		phi := math.Acos(math.Max(-1, math.Min(1, 3*q/(2*p)*math.Sqrt(-3/p)))) / 3
+		for k := range 3 {
+			ts = append(ts, complex(r*math.Cos(phi-2*math.Pi*float64(k)/3), 0))
+		}
+	}
+	for i := range ts {
+		ts[i] -= complex(shift, 0)
+	}
+	return ts
+}
+
+// quarticRoots は x⁴ + bx³ + cx² + dx + e = 0 の根をフェラーリの方法で求めます
+func quarticRoots(b, c, d, e float64) []complex128 {
+	// x = y - b/4 で y⁴ + py² + qy + r = 0 に変形する
+	shift := b / 4
+	p := c - 3*b*b/8
+	q := b*b*b/8 - b*c/2 + d
+	r := -3*b*b*b*b/256 + b*b*c/16 - b*d/4 + e
+
+	var ys []complex128
+	if math.Abs(q) < 1e-14*(1+math.Abs(p)+math.Abs(r)) {
+		// 複二次式 z² + pz + r = 0（z = y²）
+		for _, z := range quadraticRoots(1, complex(p, 0), complex(r, 0)) {
+			w := cmplx.Sqrt(z)
+			ys = append(ys, w, -w)
+		}
+	} else {
+		// 分解方程式 8m³ + 8pm² + (2p² - 8r)m - q² = 0 の正の実根を使う
+		var m float64
+		for _, root := range cubicRoots(p, p*p/4-r, -q*q/8) {
+			if imag(root) == 0 && real(root) > m {
+				m = real(root)
+			}
+		}
+		s := math.Sqrt(2 * m)
+		ys = append(ys, quadraticRoots(1, complex(s, 0), complex(p/2+m-q/(2*s), 0))...)
+		ys = append(ys, quadraticRoots(1, complex(-s, 0), complex(p/2+m+q/(2*s), 0))...)
+	}
+	for i := range ys {
+		ys[i] -= complex(shift, 0)
+	}
+	return ys
+}
+
+// evalPolynomial はホーナー法で多項式の値を求めます
+func evalPolynomial(coeffs []float64, x complex128) complex128 {
+	var v complex128
+	for _, c := range coeffs {
+		v = v*x + complex(c, 0)
+	}
+	return v
+}
+
+// polishRoot は公式による丸め誤差を Newton 法で減らします。残差が減らない場合は元の値を返します
+func polishRoot(coeffs []float64, x complex128) complex128 {
+	derivative := make([]float64, len(coeffs)-1)
+	n := len(coeffs) - 1
+	for i := range derivative {
+		derivative[i] = coeffs[i] * float64(n-i)
+	}
+	best, bestResidual := x, cmplx.Abs(evalPolynomial(coeffs, x))
+	for range polishIterations {
+		d := evalPolynomial(derivative, x)
+		if d == 0 {
+			break
+		}
+		x -= evalPolynomial(coeffs, x) / d
+		if res := cmplx.Abs(evalPolynomial(coeffs, x)); res < bestResidual {
+			best, bestResidual = x, res
+		}
+	}
+	// 実係数の多項式で虚部が丸め誤差程度なら実根とする
+	if math.Abs(imag(best)) <= 1e-12*math.Max(1, math.Abs(real(best))) {
+		if r := complex(real(best), 0); cmplx.Abs(evalPolynomial(coeffs, r)) <= bestResidual*(1+1e-9)+1e-15 {
+			best = r
+		}
+	}
+	return best
+}
+
+// findRoot は指定された方法で区間 [a, b] 内の根を探します
+func findRoot(ctx context.Context, f func(float64) (float64, *exprError), params RootParams) (*CallToolResult, *Error) {
+	if math.IsInf(params.A, 0) || math.IsInf(params.B, 0) || params.A >= params.B {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "a and b must be finite with a < b"}
+	}
+	if params.Tolerance == 0 {
+		params.Tolerance = defaultTolerance
+	}
+	if params.MaxIterations == 0 {
+		params.MaxIterations = defaultMaxIterations
+	}
+	if params.Method == "" {
+		params.Method = MethodBrent
+	}
+	eval := func(x float64) (float64, *Error) { return evalAt(f, x, params.Expression) }
+
+	var result rootResult
+	var err *Error
+	switch params.Method {
+	case MethodBisection:
+		result, err = bisection(eval, params)
+	case MethodNewton:
+		result, err = newton(eval, params)
+	case MethodBrent:
+		result, err = brent(eval, params)
+	default:
+		return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Unknown method '%s'", params.Method)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Value, result.Method = result.Root, string(params.Method)
+
+	status := fmt.Sprintf("converged in %d iterations", result.Iterations)
+	if !result.Converged {
+		status = fmt.Sprintf("not converged: %s after %d iterations", strings.ReplaceAll(string(result.Status), "_", " "), result.Iterations)
+	}
+	text := fmt.Sprintf("x = %s (%s, error estimate %s)", formatNumber(ctx, result.Root), status, formatEstimate(result.ErrorEstimate))
+	res := newStructuredResult(text, result)
+	res.value = floatNumber(result.Root)
+	return res, nil
+}
+
+// bracket は区間の両端の値を求め、符号が変わらない場合はエラーを返します
+func bracket(eval func(float64) (float64, *Error), a, b float64) (fa, fb float64, err *Error) {
+	if fa, err = eval(a); err != nil {
+		return 0, 0, err
+	}
+	if fb, err = eval(b); err != nil {
+		return 0, 0, err
+	}
+	if fa*fb > 0 {
+		return 0, 0, &Error{
+			Code:    ErrorInvalidParams,
+			Message: "The expression must change sign over [a, b]",
+			Data:    map[string]any{"f(a)": fa, "f(b)": fb},
+		}
+	}
+	return fa, fb, nil
+}
+
+// bisection は二分法で根を求めます
+func bisection(eval func(float64) (float64, *Error), params RootParams) (rootResult, *Error) {
+	a, b := params.A, params.B
+	fa, fb, err := bracket(eval, a, b)
+	if err != nil {
+		return rootResult{}, err
+	}
+	if fa == 0 {
+		return rootResult{Root: a, Converged: true, Status: statusConverged}, nil
+	}
+	if fb == 0 {
+		return rootResult{Root: b, Converged: true, Status: statusConverged}, nil
+	}
+
+	res := rootResult{Status: statusMaxIterations}
+	for res.Iterations < params.MaxIterations {
+		res.Iterations++
+		mid := a + (b-a)/2
+		fm, err := eval(mid)
+		if err != nil {
+			return rootResult{}, err
+		}
+		res.Root, res.Residual, res.ErrorEstimate = mid, fm, (b-a)/2
+		if fm == 0 || (b-a)/2 <= params.Tolerance {
+			res.Converged, res.Status = true, statusConverged
+			break
+		}
+		if (fm < 0) == (fa < 0) {
+			a, fa = mid, fm
+		} else {
+			b = mid
+		}
+	}
+	return res, nil
+}
+
+// newton は中心差分で微分を近似した Newton 法で根を求めます
+func newton(eval func(float64) (float64, *Error), params RootParams) (rootResult, *Error) {
+	x := params.A + (params.B-params.A)/2
+	if params.X0 != nil {
+		x = *params.X0
+	}
+	res := rootResult{Root: x, Status: statusMaxIterations}
+	for res.Iterations < params.MaxIterations {
+		fx, err := eval(x)
+		if err != nil {
+			return rootResult{}, err
+		}
+		res.Root, res.Residual = x, fx
+		if fx == 0 {
+			res.Converged, res.Status, res.ErrorEstimate = true, statusConverged, 0
+			break
+		}
+		h := 1e-6 * math.Max(1, math.Abs(x))
+		fp, err := eval(x + h)
+		if err != nil {
+			return rootResult{}, err
+		}
+		fm, err := eval(x - h)
+		if err != nil {
+			return rootResult{}, err
+		}
+		d := (fp - fm) / (2 * h)
+		// 接線が水平だと次の点が決まらず、誤差の見積もりもできない
+		if d == 0 {
+			return rootResult{}, &Error{
+				Code:    ErrorMathDomain,
+				Message: "Newton's method hit a zero derivative",
+				Data:    map[string]any{"x": x, "iterations": res.Iterations},
+			}
+		}
+		res.Iterations++
+		next := x - fx/d
+		res.ErrorEstimate = math.Abs(next - x)
+		if next < params.A || next > params.B {
+			res.Status = statusLeftInterval
+			break
+		}
+		x = next
+		if res.ErrorEstimate <= params.Tolerance {
+			fx, err := eval(x)
+			if err != nil {
+				return rootResult{}, err
+			}
+			res.Root, res.Residual = x, fx
+			res.Converged, res.Status = true, statusConverged
+			break
+		}
+	}
+	return res, nil
+}
+
+// brent はブレント法（二分法、割線法、逆二次補間の組み合わせ）で根を求めます
+func brent(eval func(float64) (float64, *Error), params RootParams) (rootResult, *Error) {
+	a, b := params.A, params.B
+	fa, fb, err := bracket(eval, a, b)
+	if err != nil {
+		return rootResult{}, err
+	}
+	c, fc := b, fb
+	var d, e float64
+	res := rootResult{Root: b, Residual: fb, Status: statusMaxIterations}
+	for res.Iterations < params.MaxIterations {
+		if (fb > 0) == (fc > 0) {
+			c, fc = a, fa
+			d = b - a
+			e = d
+		}
+		if math.Abs(fc) < math.Abs(fb) {
+			a, b, c = b, c, b
+			fa, fb, fc = fb, fc, fb
+		}
+		tol := 2*math.SmallestNonzeroFloat64 + params.Tolerance/2
+		m := (c - b) / 2
+		res.Root, res.Residual, res.ErrorEstimate = b, fb, math.Abs(m)
+		if math.Abs(m) <= tol || fb == 0 {
+			res.Converged, res.Status = true, statusConverged
+			break
+		}
+		res.Iterations++
+		if math.Abs(e) >= tol && math.Abs(fa) > math.Abs(fb) {
+			// 補間を試みる
+			s := fb / fa
+			var p, q float64
+			if a == c {
+				p, q = 2*m*s, 1-s
+			} else {
+				qa, r := fa/fc, fb/fc
+				p = s * (2*m*qa*(qa-r) - (b-a)*(r-1))
+				q = (qa - 1) * (r - 1) * (s - 1)
+			}
+			if p > 0 {
+				q = -q
+			} else {
+				p = -p
+			}
+			if 2*p < math.Min(3*m*q-math.Abs(tol*q), math.Abs(e*q)) {
+				e, d = d, p/q
+			} else {
+				d, e = m, m
+			}
+		} else {
+			d, e = m, m
+		}
+		a, fa = b, fb
+		if math.Abs(d) > tol {
+			b += d
+		} else {
+			b += math.Copysign(tol, m)
+		}
+		if fb, err = eval(b); err != nil {
+			return rootResult{}, err
+		}
+	}
+	return res, nil
+}
+
+// differentiate はリチャードソン補外（Ridders の方法）で中心差分の誤差を減らして微分係数を求めます
+func differentiate(ctx context.Context, f func(float64) (float64, *exprError), params DifferentiateParams) (*CallToolResult, *Error) {
+	const (
+		con     = 1.4
+		tableau = 10
+	)
+	if params.Order == 0 {
+		params.Order = 1
+	}
+	if params.Order != 1 && params.Order != 2 {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "order must be 1 or 2"}
+	}
+	h := params.Step
+	if h == 0 {
+		h = 0.1 * math.Max(1, math.Abs(params.At))
+	}
+	if h < 0 || math.IsInf(h, 0) || math.IsNaN(h) {
+		return nil, &Error{Code: ErrorInvalidParams, Message: "step must be positive"}
+	}
+
+	eval := func(x float64) (float64, *Error) { return evalAt(f, x, params.Expression) }
+	f0, err := eval(params.At)
+	if err != nil {
+		return nil, err
+	}
+	difference := func(h float64) (float64, *Error) {
+		fp, err := eval(params.At + h)
+		if err != nil {
+			return 0, err
+		}
+		fm, err := eval(params.At - h)
+		if err != nil {
+			return 0, err
+		}
+		if params.Order == 1 {
+			return (fp - fm) / (2 * h), nil
+		}
+		return (fp - 2*f0 + fm) / (h * h), nil
+	}
+
+	var a [tableau][tableau]float64
+	if a[0][0], err = difference(h); err != nil {
+		return nil, err
+	}
+	value, estimate, iterations := a[0][0], math.Inf(1), 1
+	for i := 1; i < tableau; i++ {
+		h /= con
+		if a[0][i], err = difference(h); err != nil {
+			return nil, err
+		}
+		iterations++
+		factor := con * con
+		for j := 1; j <= i; j++ {
+			a[j][i] = (a[j-1][i]*factor - a[j-1][i-1]) / (factor - 1)
+			factor *= con * con
+			e := math.Max(math.Abs(a[j][i]-a[j-1][i]), math.Abs(a[j][i]-a[j-1][i-1]))
+			if e <= estimate {
+				estimate, value = e, a[j][i]
+			}
+		}
+		// 高次の補外で誤差が大きくなり始めたら打ち切る
+		if math.Abs(a[i][i]-a[i-1][i-1]) >= 2*estimate {
+			break
+		}
+	}
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return nil, &Error{Code: ErrorMathDomain, Message: "Result of differentiate is out of range"}
+	}
+
+	text := fmt.Sprintf("%s (error estimate %s)", formatNumber(ctx, value), formatEstimate(estimate))
+	return newStructuredResult(text, map[string]any{
+		"value":         value,
+		"order":         params.Order,
+		"iterations":    iterations,
+		"errorEstimate": estimate,
+	}), nil
+}
+
+// formatEstimate は誤差の見積もりを有効数字2桁で表します
+func formatEstimate(e float64) string {
+	return strconv.FormatFloat(e, 'g', 2, 64)
+}
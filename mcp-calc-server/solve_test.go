@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestSolvePolynomial(t *testing.T) {
+	tests := []struct {
+		coeffs []float64
+		want   []complex128
+	}{
+		{[]float64{2, -4}, []complex128{2}},
+		// 先頭の 0 は次数を下げる
+		{[]float64{0, 1, -3, 2}, []complex128{1, 2}},
+		{[]float64{1, 0, 1}, []complex128{-1i, 1i}},
+		// 桁落ちしやすい二次方程式
+		{[]float64{1, -1e8, 1}, []complex128{1e-8, 1e8}},
+		{[]float64{1, -6, 11, -6}, []complex128{1, 2, 3}},
+		{[]float64{1, 0, 0, -8}, []complex128{complex(-1, -math.Sqrt(3)), complex(-1, math.Sqrt(3)), 2}},
+		{[]float64{1, -3, 3, -1}, []complex128{1, 1, 1}},
+		{[]float64{1, -10, 35, -50, 24}, []complex128{1, 2, 3, 4}},
+		// 複二次式
+		{[]float64{1, 0, -5, 0, 4}, []complex128{-2, -1, 1, 2}},
+		{[]float64{1, 0, 0, 0, 1}, []complex128{
+			complex(-math.Sqrt2/2, -math.Sqrt2/2), complex(-math.Sqrt2/2, math.Sqrt2/2),
+			complex(math.Sqrt2/2, -math.Sqrt2/2), complex(math.Sqrt2/2, math.Sqrt2/2),
+		}},
+		{[]float64{2, -4, -22, 24, 0}, []complex128{-3, 0, 1, 4}},
+	}
+
+	for _, tt := range tests {
+		result, err := solvePolynomial(context.Background(), tt.coeffs)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.coeffs, err)
+			continue
+		}
+		roots := result.StructuredContent.(map[string]any)["roots"].([]complexValue)
+		if len(roots) != len(tt.want) {
+			t.Errorf("%v: expected %v, got %v", tt.coeffs, tt.want, roots)
+			continue
+		}
+		for i, r := range roots {
+			if got := complex(r.Re, r.Im); cmplx.Abs(got-tt.want[i]) > 1e-9*math.Max(1, cmplx.Abs(tt.want[i])) {
+				t.Errorf("%v: expected %v, got %v", tt.coeffs, tt.want, roots)
+				break
+			}
+		}
+	}
+}
+
+func TestSolverTools(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"solve_polynomial", `{"coefficients":[1,0,-2]}`, "x1 = -1.414214\nx2 = 1.414214"},
+		{"solve_polynomial", `{"coefficients":[1,2,5]}`, "x1 = -1.000000 - 2.000000i\nx2 = -1.000000 + 2.000000i"},
+		{"find_root", `{"expression":"x^2-2","a":0,"b":2,"method":"bisection","tolerance":1e-6}`, "x = 1.414214 (converged in 21 iterations, error estimate 9.5e-07)"},
+		{"find_root", `{"expression":"x^2-2","a":0,"b":2,"method":"bisection","maxIterations":5}`, "x = 1.437500 (not converged: max iterations after 5 iterations, error estimate 0.062)"},
+		{"differentiate", `{"expression":"sin(x)","at":0}`, "1.000000 (error estimate 0)"},
+	}
+
+	for _, tt := range tests {
+		if got := callText(t, s, tt.name, tt.args); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.name, tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestFindRootConvergence(t *testing.T) {
+	s := newTestServer()
+
+	for _, method := range []string{"bisection", "newton", "brent"} {
+		args := `{"expression":"exp(x)-3","a":0,"b":2,"method":"` + method + `"}`
+		result, err := s.callTool(context.Background(), "find_root", json.RawMessage(args))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", method, err)
+		}
+		r := result.StructuredContent.(rootResult)
+		if !r.Converged || r.Status != statusConverged || r.Method != method {
+			t.Errorf("%s: expected convergence, got %+v", method, r)
+		}
+		if math.Abs(r.Root-math.Log(3)) > 1e-10 || r.ErrorEstimate > defaultTolerance {
+			t.Errorf("%s: expected ln 3, got %+v", method, r)
+		}
+		// value は根、residual は根での式の値
+		if r.Value != r.Root || math.Abs(r.Residual) > 1e-9 {
+			t.Errorf("%s: expected value to be the root with a small residual, got %+v", method, r)
+		}
+	}
+
+	// Newton 法は微分が 0 になると定義域エラーにする
+	for _, args := range []string{
+		`{"expression":"x^2+1","a":-1,"b":1,"method":"newton","x0":0}`,
+		`{"expression":"x^2+1","a":-1,"b":1,"method":"newton"}`,
+	} {
+		if _, err := s.callTool(context.Background(), "find_root", json.RawMessage(args)); err == nil || err.Code != ErrorMathDomain {
+			t.Errorf("%s: expected zero derivative error, got %v", args, err)
+		}
+	}
+
+	// 根は履歴から参照できる
+	text := callText(t, s, "find_root", `{"expression":"x^2-2","a":0,"b":2}`)
+	entry, _ := s.memory.entry(1)
+	if root, err := entry.Value.Float64(); err != nil || math.Abs(root-math.Sqrt2) > 1e-10 {
+		t.Errorf("expected the root to be recorded, got %q for %s", entry.Value, text)
+	}
+	if got := callText(t, s, "multiply", `{"a":"$1","b":"$1"}`); got != "2.000000" {
+		t.Errorf("expected $1 to refer to the root, got %s", got)
+	}
+
+	// 区間の外に出た場合
+	result, _ := s.callTool(context.Background(), "find_root", json.RawMessage(`{"expression":"atan(x)","a":-10,"b":10,"method":"newton","x0":2}`))
+	if r := result.StructuredContent.(rootResult); r.Converged || r.Status != statusLeftInterval {
+		t.Errorf("expected left interval, got %+v", r)
+	}
+}
+
+func TestDifferentiate(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		args string
+		want float64
+	}{
+		{`{"expression":"x^3","at":2}`, 12},
+		{`{"expression":"x^3","at":2,"order":2}`, 12},
+		{`{"expression":"exp(t)","variable":"t","at":1}`, math.E},
+		{`{"expression":"sqrt(x)","at":4,"step":0.5}`, 0.25},
+		{`{"expression":"sin(x)","at":1,"order":2}`, -math.Sin(1)},
+	}
+
+	for _, tt := range tests {
+		result, err := s.callTool(context.Background(), "differentiate", json.RawMessage(tt.args))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.args, err)
+			continue
+		}
+		data := result.StructuredContent.(map[string]any)
+		value, estimate := data["value"].(float64), data["errorEstimate"].(float64)
+		// 誤差の見積もりも十分に小さい
+		if math.Abs(value-tt.want) > 1e-7 || estimate > 1e-6 {
+			t.Errorf("%s: expected %v, got %v ± %v", tt.args, tt.want, value, estimate)
+		}
+	}
+}
+
+func TestSolverToolErrors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		code int
+	}{
+		{"solve_polynomial", `{"coefficients":[0,0,1]}`, ErrorInvalidParams},
+		{"solve_polynomial", `{"coefficients":[1,2,3,4,5,6]}`, ErrorInvalidParams},
+		{"find_root", `{"expression":"x^2+1","a":-1,"b":1}`, ErrorInvalidParams},
+		{"find_root", `{"expression":"x","a":1,"b":0}`, ErrorInvalidParams},
+		{"find_root", `{"expression":"log(x)","a":-1,"b":1,"method":"bisection"}`, ErrorMathDomain},
+		{"differentiate", `{"expression":"x","at":0,"order":3}`, ErrorInvalidParams},
+		{"differentiate", `{"expression":"y","at":0}`, ErrorInvalidParams},
+	}
+
+	for _, tt := range tests {
+		_, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err == nil || err.Code != tt.code {
+			t.Errorf("%s %s: expected error code %d, got %v", tt.name, tt.args, tt.code, err)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// StatsParams は統計ツールのパラメータを表します
+type StatsParams struct {
+	Values     []float64 `json:"values"`
+	Sample     bool      `json:"sample,omitempty"`     // variance / stddev で標本（n-1）を使う
+	Percentile *float64  `json:"percentile,omitempty"` // percentile で使う 0〜100 の値
+	Bins       int       `json:"bins,omitempty"`       // histogram の階級数
+	Min        *float64  `json:"min,omitempty"`        // histogram の範囲の下限
+	Max        *float64  `json:"max,omitempty"`        // histogram の範囲の上限
+}
+
+// 統計ツールの制限
+const (
+	maxHistogramBins = 1000
+)
+
+// valuesSchema は数値配列の入力スキーマです
+var valuesSchema = map[string]any{
+	"type":        "array",
+	"items":       map[string]any{"type": "number"},
+	"minItems":    1,
+	"description": "Array of numbers",
+}
+
+// statsSchema は統計ツールの入力スキーマを生成します
+func statsSchema(extra map[string]any, required ...string) map[string]any {
+	properties := map[string]any{
+		"values": valuesSchema,
+		"format": formatSchema,
+	}
+	for k, v := range extra {
+		properties[k] = v
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   append([]string{"values"}, required...),
+	}
+}
+
+// getStatisticsTools は統計ツールの一覧を返します
+func getStatisticsTools() []toolDef {
+	sampleSchema := map[string]any{
+		"sample": map[string]any{
+			"type":        "boolean",
+			"description": "Use the sample (n-1) estimator instead of the population formula",
+		},
+	}
+	return bindTools([]Tool{
+		{Name: "sum", Description: "Sum of an array of numbers", InputSchema: statsSchema(nil), OutputSchema: valueOutputSchema},
+		{Name: "mean", Description: "Arithmetic mean of an array of numbers", InputSchema: statsSchema(nil), OutputSchema: valueOutputSchema},
+		{Name: "median", Description: "Median of an array of numbers", InputSchema: statsSchema(nil), OutputSchema: valueOutputSchema},
+		{
+			Name:        "mode",
+			Description: "Most frequent value(s) of an array of numbers",
+			InputSchema: statsSchema(nil),
+			OutputSchema: outputSchema(map[string]any{
+				"modes": map[string]any{"type": "array", "items": map[string]any{"type": "number"}},
+			}, "modes"),
+		},
+		{Name: "min", Description: "Minimum of an array of numbers", InputSchema: statsSchema(nil), OutputSchema: valueOutputSchema},
+		{Name: "max", Description: "Maximum of an array of numbers", InputSchema: statsSchema(nil), OutputSchema: valueOutputSchema},
+		{Name: "variance", Description: "Variance of an array of numbers", InputSchema: statsSchema(sampleSchema), OutputSchema: valueOutputSchema},
+		{Name: "stddev", Description: "Standard deviation of an array of numbers", InputSchema: statsSchema(sampleSchema), OutputSchema: valueOutputSchema},
+		{
+			Name:        "percentile",
+			Description: "Percentile of an array of numbers using linear interpolation",
+			InputSchema: statsSchema(map[string]any{
+				"percentile": map[string]any{
+					"type":        "number",
+					"minimum":     0,
+					"maximum":     100,
+					"description": "Percentile to compute (0-100)",
+				},
+			}, "percentile"),
+			OutputSchema: valueOutputSchema,
+		},
+		{
+			Name:        "histogram",
+			Description: "Histogram of an array of numbers with equal-width bins",
+			InputSchema: statsSchema(map[string]any{
+				"bins": map[string]any{
+					"type":        "integer",
+					"minimum":     1,
+					"maximum":     maxHistogramBins,
+					"description": "Number of bins (defaults to Sturges' rule)",
+				},
+				"min": map[string]any{
+					"type":        "number",
+					"description": "Lower bound of the histogram range (defaults to the minimum value)",
+				},
+				"max": map[string]any{
+					"type":        "number",
+					"description": "Upper bound of the histogram range (defaults to the maximum value)",
+				},
+			}),
+			OutputSchema: outputSchema(map[string]any{
+				"bins": map[string]any{
+					"type": "array",
+					"items": outputSchema(map[string]any{
+						"lower": map[string]any{"type": "number"},
+						"upper": map[string]any{"type": "number"},
+						"count": map[string]any{"type": "integer"},
+					}, "lower", "upper", "count"),
+				},
+			}, "bins"),
+		},
+	}, (*Server).handleStatistics)
+}
+
+// handleStatistics は統計ツールの呼び出しを処理します
+func (s *Server) handleStatistics(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error) {
+	var params StatsParams
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+	if len(params.Values) == 0 {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: "values must contain at least one number",
+		}
+	}
+
+	var value float64
+	switch name {
+	case "sum":
+		value = sum(params.Values)
+	case "mean":
+		value = mean(params.Values)
+	case "median":
+		value = percentile(sorted(params.Values), 50)
+	case "mode":
+		modes := mode(params.Values)
+		result := newStructuredResult(strings.Join(formatNumbers(ctx, modes), ", "), map[string]any{"modes": modes})
+		if len(modes) == 1 {
+			result.value = floatNumber(modes[0])
+		}
+		return result, nil
+	case "min":
+		value = slices.Min(params.Values)
+	case "max":
+		value = slices.Max(params.Values)
+	case "variance", "stddev":
+		if params.Sample && len(params.Values) < 2 {
+			return nil, &Error{
+				Code:    ErrorInvalidParams,
+				Message: "sample variance requires at least two values",
+			}
+		}
+		value = variance(params.Values, params.Sample)
+		if name == "stddev" {
+			value = math.Sqrt(value)
+		}
+	case "percentile":
+		if params.Percentile == nil || *params.Percentile < 0 || *params.Percentile > 100 {
+			return nil, &Error{
+				Code:    ErrorInvalidParams,
+				Message: "percentile must be between 0 and 100",
+			}
+		}
+		value = percentile(sorted(params.Values), *params.Percentile)
+	case "histogram":
+		bins, err := histogram(params)
+		if err != nil {
+			return nil, err
+		}
+		lines := make([]string, len(bins))
+		for i, b := range bins {
+			closing := ")"
+			if i == len(bins)-1 {
+				closing = "]"
+			}
+			lines[i] = fmt.Sprintf("[%s, %s%s: %d", formatNumber(ctx, b.Lower), formatNumber(ctx, b.Upper), closing, b.Count)
+		}
+		return newStructuredResult(strings.Join(lines, "\n"), map[string]any{"bins": bins}), nil
+	}
+
+	// 非常に大きな値の合計や分散は float64 の範囲を超えることがある
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return nil, &Error{
+			Code:    ErrorMathDomain,
+			Message: fmt.Sprintf("Result of %s is out of range", name),
+		}
+	}
+	return newStructuredResult(formatNumber(ctx, value), map[string]any{"value": value}), nil
+}
+
+func sum(values []float64) float64 {
+	// 桁落ちを抑えるため Kahan の加算を使う
+	var total, c float64
+	for _, v := range values {
+		y := v - c
+		t := total + y
+		c = (t - total) - y
+		total = t
+	}
+	return total
+}
+
+// mean は平均を求めます。合計が float64 の範囲を超える場合は逐次平均で求めます
+func mean(values []float64) float64 {
+	if total := sum(values); finite(total) {
+		return total / float64(len(values))
+	}
+	// v - m も範囲を超えることがあるので、先に割ってから差を取る
+	var m float64
+	for i, v := range values {
+		n := float64(i + 1)
+		m += v/n - m/n
+	}
+	return m
+}
+
+func sorted(values []float64) []float64 {
+	out := slices.Clone(values)
+	slices.Sort(out)
+	return out
+}
+
+// percentile は昇順に並んだ値から線形補間で p パーセンタイルを求めます
+func percentile(sortedValues []float64, p float64) float64 {
+	if len(sortedValues) == 1 {
+		return sortedValues[0]
+	}
+	rank := p / 100 * float64(len(sortedValues)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	frac := rank - float64(lower)
+	// 差を取ると符号の異なる大きな値で float64 の範囲を超えるので、重み付きの和で補間する
+	return (1-frac)*sortedValues[lower] + frac*sortedValues[upper]
+}
+
+// mode は最頻値を昇順で返します。複数ある場合はすべて返します
+func mode(values []float64) []float64 {
+	counts := make(map[float64]int)
+	best := 0
+	for _, v := range values {
+		counts[v]++
+		best = max(best, counts[v])
+	}
+	var modes []float64
+	for v, c := range counts {
+		if c == best {
+			modes = append(modes, v)
+		}
+	}
+	slices.Sort(modes)
+	return modes
+}
+
+// variance は分散を求めます。sample が true の場合は不偏分散を返します
+func variance(values []float64, sample bool) float64 {
+	m := mean(values)
+	squares := make([]float64, len(values))
+	for i, v := range values {
+		squares[i] = (v - m) * (v - m)
+	}
+	n := float64(len(values))
+	if sample {
+		n--
+	}
+	return sum(squares) / n
+}
+
+// histogramBin はヒストグラムの1つの階級を表します
+type histogramBin struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}
+
+// histogram は等幅の階級で度数を数えます。範囲外の値は数えません
+func histogram(params StatsParams) ([]histogramBin, *Error) {
+	lo, hi := slices.Min(params.Values), slices.Max(params.Values)
+	if params.Min != nil {
+		lo = *params.Min
+	}
+	if params.Max != nil {
+		hi = *params.Max
+	}
+	if lo > hi {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: "min must not be greater than max",
+		}
+	}
+
+	bins := params.Bins
+	if bins == 0 {
+		// Sturges の公式
+		bins = int(math.Ceil(math.Log2(float64(len(params.Values))))) + 1
+	}
+	if bins < 1 || bins > maxHistogramBins {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("bins must be between 1 and %d", maxHistogramBins),
+		}
+	}
+	// すべての値が同じ場合は幅1の階級にする
+	if lo == hi {
+		lo, hi = lo-0.5, hi+0.5
+	}
+
+	width := (hi - lo) / float64(bins)
+	result := make([]histogramBin, bins)
+	for i := range result {
+		result[i].Lower = lo + float64(i)*width
+		result[i].Upper = lo + float64(i+1)*width
+	}
+	result[bins-1].Upper = hi
+	for _, v := range params.Values {
+		if v < lo || v > hi {
+			continue
+		}
+		// 最後の階級だけは上限を含む
+		i := min(int((v-lo)/width), bins-1)
+		result[i].Count++
+	}
+	return result, nil
+}
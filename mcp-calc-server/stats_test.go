@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestStatisticsTools(t *testing.T) {
+	s := newTestServer()
+	values := `[2, 4, 4, 4, 5, 5, 7, 9]`
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"sum", `{"values":` + values + `}`, "40.000000"},
+		{"mean", `{"values":` + values + `}`, "5.000000"},
+		{"median", `{"values":` + values + `}`, "4.500000"},
+		{"mode", `{"values":` + values + `}`, "4.000000"},
+		{"mode", `{"values":[1, 1, 2, 2, 3]}`, "1.000000, 2.000000"},
+		{"min", `{"values":` + values + `}`, "2.000000"},
+		{"max", `{"values":` + values + `}`, "9.000000"},
+		{"variance", `{"values":` + values + `}`, "4.000000"},
+		{"stddev", `{"values":` + values + `}`, "2.000000"},
+		{"variance", `{"values":` + values + `,"sample":true}`, "4.571429"},
+		{"percentile", `{"values":[1, 2, 3, 4, 5],"percentile":25}`, "2.000000"},
+		{"percentile", `{"values":[1, 2, 3, 4],"percentile":50}`, "2.500000"},
+		{"histogram", `{"values":[1, 2, 2, 3, 4],"bins":3,"min":1,"max":4}`, "[1.000000, 2.000000): 1\n[2.000000, 3.000000): 2\n[3.000000, 4.000000]: 2"},
+	}
+
+	for _, tt := range tests {
+		result, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err != nil {
+			t.Errorf("%s %s: unexpected error: %v", tt.name, tt.args, err)
+			continue
+		}
+		got := result.Content[0].Text
+		if got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.name, tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestStatisticsToolErrors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+	}{
+		{"mean", `{"values":[]}`},
+		{"mean", `{"values":"1,2,3"}`},
+		{"variance", `{"values":[1],"sample":true}`},
+		{"percentile", `{"values":[1, 2]}`},
+		{"percentile", `{"values":[1, 2],"percentile":101}`},
+		{"histogram", `{"values":[1, 2],"bins":1001}`},
+		{"histogram", `{"values":[1, 2],"min":3,"max":2}`},
+	}
+
+	for _, tt := range tests {
+		_, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args))
+		if err == nil || err.Code != ErrorInvalidParams {
+			t.Errorf("%s %s: expected invalid params error, got %v", tt.name, tt.args, err)
+		}
+	}
+}
+
+func TestHistogramDefaultBins(t *testing.T) {
+	bins, err := histogram(StatsParams{Values: []float64{1, 2, 3, 4, 5, 6, 7, 8}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Sturges の公式: ceil(log2(8)) + 1 = 4
+	if len(bins) != 4 {
+		t.Fatalf("expected 4 bins, got %d", len(bins))
+	}
+	counts := make([]int, len(bins))
+	for i, b := range bins {
+		counts[i] = b.Count
+	}
+	if !slices.Equal(counts, []int{2, 2, 2, 2}) {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+}
+
+func TestSumCompensated(t *testing.T) {
+	values := make([]float64, 10)
+	for i := range values {
+		values[i] = 0.1
+	}
+	if got := sum(values); math.Abs(got-1) > 1e-15 {
+		t.Errorf("expected 1, got %.17g", got)
+	}
+}
+
+func TestStatisticsLargeValues(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		// 合計は float64 の範囲を超えても平均は表せる
+		{"mean", `{"values":[1e308, 1e308],"format":{"notation":"scientific","digits":3}}`, "1.00e+308"},
+		{"mean", `{"values":[1.5e308, 1.7e308, -0.2e308],"format":{"notation":"scientific","digits":3}}`, "1.00e+308"},
+		{"median", `{"values":[-1e308, 1e308]}`, "0.000000"},
+		{"percentile", `{"values":[-1e308, 1e308],"percentile":75,"format":{"notation":"scientific","digits":3}}`, "5.00e+307"},
+	}
+
+	for _, tt := range tests {
+		if got := callText(t, s, tt.name, tt.args); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.name, tt.args, tt.want, got)
+		}
+	}
+
+	// 合計そのものは範囲外なのでエラーにする
+	if _, err := s.handleToolCall(context.Background(), "sum", json.RawMessage(`{"values":[1e308, 1e308]}`)); err == nil || err.Code != ErrorMathDomain {
+		t.Errorf("expected out of range error for sum, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// maxStdioMessageSize は stdio で受け付ける1メッセージ（1行）の最大バイト数です
+const maxStdioMessageSize = 4 << 20
+
+// serveStdio は改行区切りの JSON-RPC メッセージを r から読み、応答を w に書き込みます。
+// 1行を1メッセージとして扱うため、不正な行があっても次の行から読み直せます。
+// r が EOF に達するか shutdown を受け取ると nil を返します
+func (s *Server) serveStdio(r io.Reader, w io.Writer) error {
+	return s.serveLines(r, w, maxStdioMessageSize)
+}
+
+func (s *Server) serveLines(r io.Reader, w io.Writer, maxSize int) error {
+	reader := bufio.NewReader(r)
+	encoder := json.NewEncoder(w)
+
+	// 応答とサーバーからの通知が混ざらないように書き込みを直列化する
+	var mu sync.Mutex
+	write := func(msg any) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := encoder.Encode(msg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding response: %v\n", err)
+		}
+	}
+	s.send = write
+
+	// ツールの呼び出しは別の goroutine で実行し、終了前にすべての応答を書き終える
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		line, tooLarge, err := readLine(reader, maxSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		atEOF := err != nil
+
+		switch {
+		case tooLarge:
+			s.log(LevelError, "stdio", "Message too large", map[string]any{"limit": maxSize})
+			write(newErrorResponse(nil, &Error{
+				Code:    ErrorInvalidRequest,
+				Message: "Message too large",
+				Data:    fmt.Sprintf("messages must not exceed %d bytes", maxSize),
+			}))
+		case len(bytes.TrimSpace(line)) > 0:
+			if !json.Valid(line) {
+				s.log(LevelError, "stdio", "Error decoding request", map[string]any{"bytes": len(line)})
+			}
+			if req, ok := toolCallRequest(line); ok {
+				// 直後の notifications/cancelled を取りこぼさないよう、goroutine を起動する前に登録する
+				ctx, done := s.startRequest(context.Background(), req.ID)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer done()
+					if resp := s.handleStartedToolsCall(ctx, req); !resp.cancelled {
+						write(&resp)
+					}
+				}()
+				break
+			}
+			// バッチに含まれる tools/call も同じように登録してから実行し、応答がそろったらまとめて書き込む
+			if batch, err := decodeBatch(line); err == nil {
+				wait, pending := s.dispatchBatch(context.Background(), batch)
+				if !pending {
+					if reply := wait(); reply != nil {
+						write(reply)
+					}
+					break
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if reply := wait(); reply != nil {
+						write(reply)
+					}
+				}()
+				break
+			}
+			if reply := s.handleMessage(line); reply != nil {
+				write(reply)
+			}
+		}
+
+		// 標準入力が閉じられたら正常終了する
+		if atEOF || s.currentState() == stateShuttingDown {
+			return nil
+		}
+	}
+}
+
+// toolCallRequest は1行が単独の tools/call リクエストであればそれを返します。
+// 初期化などの他のメッセージは順序を保つため読み込んだ goroutine でそのまま処理します
+func toolCallRequest(line []byte) (Request, bool) {
+	req, err := decodeRequest(line)
+	return req, err == nil && req.Method == "tools/call" && len(req.ID) > 0
+}
+
+// readLine は改行までの1行を読みます。maxSize を超える行は改行まで読み捨てて tooLarge を返します。
+// 最後の行が改行で終わっていない場合は、その行と io.EOF を同時に返します
+func readLine(r *bufio.Reader, maxSize int) (line []byte, tooLarge bool, err error) {
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLarge {
+			if len(line)+len(chunk) > maxSize+1 { // +1 は末尾の改行の分
+				tooLarge = true
+				line = nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if tooLarge {
+			return nil, true, err
+		}
+		return line, false, err
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeStdioExitsOnEOF(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n")
+	var out bytes.Buffer
+
+	if err := newTestServer().serveStdio(in, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != `{"jsonrpc":"2.0","result":{},"id":1}` {
+		t.Errorf("unexpected output: %s", got)
+	}
+}
+
+func TestServeStdioStopsAfterShutdown(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}
+{"jsonrpc":"2.0","id":2,"method":"shutdown"}
+{"jsonrpc":"2.0","id":3,"method":"ping"}
+`)
+	var out bytes.Buffer
+
+	if err := newTestServer().serveStdio(in, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 responses before exit, got %d: %s", len(lines), out.String())
+	}
+}
+
+// runStdio はパイプ経由でサーバーに入力を送り、応答の行を返します
+func runStdio(t *testing.T, input string, maxSize int) []string {
+	t.Helper()
+	pr, pw := io.Pipe()
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- newTestServer().serveLines(pr, &out, maxSize)
+	}()
+
+	if _, err := io.WriteString(pw, input); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	pw.Close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not exit after EOF")
+	}
+	if out.Len() == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func TestServeStdioRecoversFromMalformedInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "garbage",
+			input: "hello world\n" + `{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n",
+			want: []string{
+				`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+				`{"jsonrpc":"2.0","result":{},"id":1}`,
+			},
+		},
+		{
+			name:  "partial JSON",
+			input: `{"jsonrpc":"2.0","id":1,"method":` + "\n" + `{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\n",
+			want: []string{
+				`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+				`{"jsonrpc":"2.0","result":{},"id":2}`,
+			},
+		},
+		{
+			name:  "binary and blank lines",
+			input: "\x00\xff\xfe\n\n   \n" + `{"jsonrpc":"2.0","id":3,"method":"ping"}` + "\n",
+			want: []string{
+				`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+				`{"jsonrpc":"2.0","result":{},"id":3}`,
+			},
+		},
+		{
+			// 最後の行に改行がなくても処理してから終了する
+			name:  "EOF without newline",
+			input: `{"jsonrpc":"2.0","id":4,"method":"ping"}`,
+			want:  []string{`{"jsonrpc":"2.0","result":{},"id":4}`},
+		},
+		{
+			name:  "EOF in the middle of a message",
+			input: `{"jsonrpc":"2.0","id":5,"me`,
+			want:  []string{`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+		},
+	}
+
+	for _, tt := range tests {
+		got := runStdio(t, tt.input, maxStdioMessageSize)
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestServeStdioRejectsOversizedMessages(t *testing.T) {
+	// bufio の既定のバッファ（4096 バイト）より長い行で読み捨てを確認する
+	const limit = 64
+	oversized := `{"jsonrpc":"2.0","id":1,"method":"ping","params":{"pad":"` + strings.Repeat("x", 100*limit) + `"}}`
+
+	got := runStdio(t, oversized+"\n"+`{"jsonrpc":"2.0","id":2,"method":"ping"}`+"\n", limit)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 responses, got %v", got)
+	}
+	if !strings.Contains(got[0], `"code":-32600,"message":"Message too large"`) || !strings.HasSuffix(got[0], `"id":null}`) {
+		t.Errorf("expected message too large error, got %s", got[0])
+	}
+	// 読み捨てた後の次の行から再開できる
+	if got[1] != `{"jsonrpc":"2.0","result":{},"id":2}` {
+		t.Errorf("expected server to resynchronize, got %s", got[1])
+	}
+
+	// 上限ちょうどのメッセージは受け付ける
+	exact := `{"jsonrpc":"2.0","id":3,"method":"ping"}`
+	if got := runStdio(t, exact+"\n", len(exact)); len(got) != 1 || got[0] != `{"jsonrpc":"2.0","result":{},"id":3}` {
+		t.Errorf("expected message at the limit to be accepted, got %v", got)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// toolHandler はツールの処理を表します。args は参照の解決とスキーマの検証が済んだ引数です。
+// (*Server).handleEvaluate のようなメソッド式をそのまま使えるようにサーバーを最初の引数にします
+type toolHandler func(s *Server, ctx context.Context, args json.RawMessage) (*CallToolResult, *Error)
+
+// toolDef はツールの定義（名前、説明、スキーマ）と処理をまとめたものです
+type toolDef struct {
+	Tool
+	handler toolHandler
+	// noHistory は結果を履歴に記録しないツールであることを示します
+	noHistory bool
+}
+
+// bindTools は名前で処理を切り替えるハンドラーを各ツールに割り当てます
+func bindTools(tools []Tool, handle func(s *Server, ctx context.Context, name string, args json.RawMessage) (*CallToolResult, *Error)) []toolDef {
+	defs := make([]toolDef, len(tools))
+	for i, tool := range tools {
+		name := tool.Name
+		defs[i] = toolDef{
+			Tool: tool,
+			handler: func(s *Server, ctx context.Context, args json.RawMessage) (*CallToolResult, *Error) {
+				return handle(s, ctx, name, args)
+			},
+		}
+	}
+	return defs
+}
+
+// builtinTools はサーバーに組み込まれたツールの一覧を返します
+func builtinTools() []toolDef {
+	var defs []toolDef
+	defs = append(defs, getArithmeticTools()...)
+	defs = append(defs, getStatisticsTools()...)
+	defs = append(defs, getUnitTools()...)
+	defs = append(defs, getMatrixTools()...)
+	defs = append(defs, getComputeTools()...)
+	defs = append(defs, getComplexTools()...)
+	defs = append(defs, getSolverTools()...)
+	defs = append(defs, getNumberTheoryTools()...)
+	defs = append(defs, getDateTools()...)
+	defs = append(defs, getFinanceTools()...)
+	defs = append(defs, getBitwiseTools()...)
+	defs = append(defs, getMemoryTools()...)
+	return append(defs, getToolConfigTools()...)
+}
+
+// registeredTool は登録済みのツールです。schema は検証用に JSON の型へ正規化した入力スキーマです
+type registeredTool struct {
+	toolDef
+	schema  map[string]any
+	enabled bool
+}
+
+// toolRegistry はセッションで利用できるツールを管理します。
+// ツールは登録順に一覧され、実行時に有効・無効を切り替えられます
+type toolRegistry struct {
+	mu     sync.RWMutex
+	tools  []*registeredTool
+	byName map[string]*registeredTool
+}
+
+func newToolRegistry(defs ...toolDef) (*toolRegistry, error) {
+	r := &toolRegistry{byName: make(map[string]*registeredTool)}
+	for _, def := range defs {
+		if err := r.register(def); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// register はツールを有効な状態で登録します
+func (r *toolRegistry) register(def toolDef) error {
+	if def.Name == "" || def.handler == nil {
+		return fmt.Errorf("tool %q must have a name and a handler", def.Name)
+	}
+	schema, err := normalizeSchema(def.InputSchema)
+	if err != nil {
+		return fmt.Errorf("tool %s: invalid input schema: %w", def.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.byName[def.Name]; exists {
+		return fmt.Errorf("tool %s is already registered", def.Name)
+	}
+	t := &registeredTool{toolDef: def, schema: schema, enabled: true}
+	r.tools = append(r.tools, t)
+	r.byName[def.Name] = t
+	return nil
+}
+
+// remove はツールを登録から外します。外したツールは setEnabled でも有効にできません
+func (r *toolRegistry) remove(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byName[name]; !ok {
+		return fmt.Errorf("unknown tool %q", name)
+	}
+	delete(r.byName, name)
+	r.tools = slices.DeleteFunc(r.tools, func(t *registeredTool) bool { return t.Name == name })
+	return nil
+}
+
+// lookup は有効なツールを名前で探します
+func (r *toolRegistry) lookup(name string) (*registeredTool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.byName[name]
+	if !ok || !t.enabled {
+		return nil, false
+	}
+	return t, true
+}
+
+// has はツールが登録されているかを有効・無効にかかわらず返します
+func (r *toolRegistry) has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.byName[name]
+	return ok
+}
+
+// disabled は無効にしたツールの名前を登録順に返します
+func (r *toolRegistry) disabled() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := []string{}
+	for _, t := range r.tools {
+		if !t.enabled {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// list は有効なツールの定義を登録順に返します
+func (r *toolRegistry) list() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]Tool, 0, len(r.tools))
+	for _, t := range r.tools {
+		if t.enabled {
+			tools = append(tools, t.Tool)
+		}
+	}
+	return tools
+}
+
+// setEnabled はツールの有効・無効を切り替えます。状態が変わった場合は changed が true になります
+func (r *toolRegistry) setEnabled(name string, enabled bool) (changed bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.byName[name]
+	if !ok {
+		return false, fmt.Errorf("unknown tool %q", name)
+	}
+	changed = t.enabled != enabled
+	t.enabled = enabled
+	return changed, nil
+}
+
+// withDisabledTools は指定したツールを登録から外した状態でサーバーを開始します。
+// 外したツールはクライアントが configure_tools で有効にすることもできません
+func withDisabledTools(names ...string) serverOption {
+	return func(s *Server) error {
+		for _, name := range names {
+			if name == configureToolsName {
+				return fmt.Errorf("tool %s cannot be disabled", name)
+			}
+			if err := s.tools.remove(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// toolListChanged はツールの一覧が変わったことを記録し、初期化済みのクライアントに通知します
+func (s *Server) toolListChanged(fields map[string]any) {
+	s.log(LevelInfo, "tools", "Tool list changed", fields)
+	if s.currentState() == stateReady {
+		s.notify("notifications/tools/list_changed", nil)
+	}
+}
+
+// configureToolsName はツールの有効・無効を切り替えるツールの名前です。このツール自身は無効にできません
+const configureToolsName = "configure_tools"
+
+// ConfigureToolsParams は configure_tools ツールのパラメータを表します
+type ConfigureToolsParams struct {
+	Enable  []string `json:"enable,omitempty"`
+	Disable []string `json:"disable,omitempty"`
+}
+
+// getToolConfigTools はセッションで使うツールを切り替えるツールの一覧を返します
+func getToolConfigTools() []toolDef {
+	names := map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+	return []toolDef{{
+		Tool: Tool{
+			Name:        configureToolsName,
+			Description: "Enable or disable tools for this session. Disabled tools disappear from tools/list until they are enabled again",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"enable":  names,
+					"disable": names,
+				},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"changed":  names,
+				"disabled": names,
+			}, "changed", "disabled"),
+			Annotations: &ToolAnnotations{IdempotentHint: true},
+		},
+		handler:   (*Server).handleConfigureTools,
+		noHistory: true,
+	}}
+}
+
+// handleConfigureTools はツールの有効・無効をまとめて切り替え、一覧が変わった場合に1回だけ通知します
+func (s *Server) handleConfigureTools(_ context.Context, args json.RawMessage) (*CallToolResult, *Error) {
+	var params ConfigureToolsParams
+	if err := unmarshalArgs(args, &params); err != nil {
+		return nil, err
+	}
+	// 一部だけ切り替わらないように、先にすべての名前を確かめる
+	for _, name := range slices.Concat(params.Enable, params.Disable) {
+		if name == configureToolsName {
+			return nil, &Error{Code: ErrorInvalidParams, Message: fmt.Sprintf("Tool %s cannot be disabled", name)}
+		}
+		if !s.tools.has(name) {
+			return nil, unknownTool(name)
+		}
+	}
+
+	changed := []string{}
+	for _, change := range []struct {
+		names   []string
+		enabled bool
+	}{{params.Enable, true}, {params.Disable, false}} {
+		for _, name := range change.names {
+			if ok, _ := s.tools.setEnabled(name, change.enabled); ok {
+				changed = append(changed, name)
+			}
+		}
+	}
+	if len(changed) > 0 {
+		s.toolListChanged(map[string]any{"enabled": params.Enable, "disabled": params.Disable})
+	}
+
+	disabled := s.tools.disabled()
+	text := fmt.Sprintf("%d tools changed", len(changed))
+	if len(disabled) > 0 {
+		text += "; disabled: " + strings.Join(disabled, ", ")
+	}
+	return newStructuredResult(text, map[string]any{"changed": changed, "disabled": disabled}), nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestToolRegistry(t *testing.T) {
+	echo := func(_ *Server, _ context.Context, args json.RawMessage) (*CallToolResult, *Error) {
+		return newTextResult(string(args)), nil
+	}
+	r, err := newToolRegistry(
+		toolDef{Tool: Tool{Name: "first", InputSchema: map[string]any{"type": "object"}}, handler: echo},
+		toolDef{Tool: Tool{Name: "second", InputSchema: map[string]any{"type": "object"}}, handler: echo},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 同じ名前やハンドラーのないツールは登録できない
+	if err := r.register(toolDef{Tool: Tool{Name: "first"}, handler: echo}); err == nil {
+		t.Error("expected error for duplicate tool")
+	}
+	if err := r.register(toolDef{Tool: Tool{Name: "third"}}); err == nil {
+		t.Error("expected error for tool without handler")
+	}
+
+	if changed, err := r.setEnabled("first", false); err != nil || !changed {
+		t.Fatalf("expected first to be disabled, got changed=%v err=%v", changed, err)
+	}
+	if changed, _ := r.setEnabled("first", false); changed {
+		t.Error("expected no change when disabling twice")
+	}
+	if _, ok := r.lookup("first"); ok {
+		t.Error("expected disabled tool to be hidden")
+	}
+	if tools := r.list(); len(tools) != 1 || tools[0].Name != "second" {
+		t.Errorf("expected only second, got %v", tools)
+	}
+	if _, err := r.setEnabled("missing", true); err == nil {
+		t.Error("expected error for unknown tool")
+	}
+}
+
+func TestToolListChanged(t *testing.T) {
+	s := newReadyServer(t)
+	sent := captureNotifications(s)
+
+	configure := func(args string) string {
+		return roundTrip(t, s, `{"jsonrpc":"2.0","id":0,"method":"tools/call","params":{"name":"configure_tools","arguments":`+args+`}}`)
+	}
+
+	if out := configure(`{"disable":["divide"]}`); !strings.Contains(out, "1 tools changed; disabled: divide") {
+		t.Fatalf("unexpected configure_tools response: %s", out)
+	}
+	if len(*sent) != 1 || (*sent)[0].Method != "notifications/tools/list_changed" {
+		t.Fatalf("expected list_changed notification, got %v", *sent)
+	}
+	if out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`); strings.Contains(out, `"name":"divide"`) {
+		t.Errorf("expected divide to be removed from tools/list, got %s", out)
+	}
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"divide","arguments":{"a":1,"b":2}}}`)
+	if !strings.Contains(out, `"code":-32602`) || !strings.Contains(out, "Unknown tool 'divide'") {
+		t.Errorf("expected unknown tool error, got %s", out)
+	}
+
+	// 状態が変わらない場合は通知しない
+	configure(`{"disable":["divide"]}`)
+	configure(`{"enable":["divide"]}`)
+	if len(*sent) != 2 {
+		t.Errorf("expected one more notification after re-enabling, got %v", *sent)
+	}
+}
+
+func TestDisabledToolsOption(t *testing.T) {
+	s, err := newServer(withDisabledTools("monte_carlo", "prime_sieve"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tool := range s.tools.list() {
+		if tool.Name == "monte_carlo" || tool.Name == "prime_sieve" {
+			t.Errorf("expected %s to be disabled", tool.Name)
+		}
+	}
+	if _, err := newServer(withDisabledTools("nope")); err == nil {
+		t.Error("expected error for unknown tool")
+	}
+}
+
+func TestToolAnnotationsPerTool(t *testing.T) {
+	s := newReadyServer(t)
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	var resp struct {
+		Result ListToolsResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("failed to decode %s: %v", out, err)
+	}
+	for _, tool := range resp.Result.Tools {
+		if tool.Annotations == nil {
+			t.Errorf("%s: missing annotations", tool.Name)
+			continue
+		}
+		// シードのない monte_carlo は同じ入力でも結果が変わる
+		if want := tool.Name != "monte_carlo"; tool.Annotations.IdempotentHint != want {
+			t.Errorf("%s: expected idempotentHint=%v", tool.Name, want)
+		}
+	}
+}
+
+func TestDisabledToolsCannotBeEnabled(t *testing.T) {
+	s, err := newServer(withDisabledTools("monte_carlo"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 起動時に外したツールはクライアントから有効にできない
+	if _, err := s.handleToolCall(context.Background(), "configure_tools", json.RawMessage(`{"enable":["monte_carlo"]}`)); err == nil {
+		t.Error("expected error when enabling a removed tool")
+	}
+	if _, err := newServer(withDisabledTools("configure_tools")); err == nil {
+		t.Error("expected error when disabling configure_tools")
+	}
+}
+
+func TestConfigureToolsErrors(t *testing.T) {
+	s := newReadyServer(t)
+	sent := captureNotifications(s)
+
+	for _, args := range []string{
+		`{"disable":["configure_tools"]}`,
+		`{"disable":["divide","nope"]}`,
+	} {
+		if _, err := s.handleToolCall(context.Background(), "configure_tools", json.RawMessage(args)); err == nil || err.Code != ErrorInvalidParams {
+			t.Errorf("%s: expected invalid params error, got %v", args, err)
+		}
+	}
+	// 名前の誤りがあれば何も切り替えない
+	if _, ok := s.tools.lookup("divide"); !ok || len(*sent) != 0 {
+		t.Errorf("expected no change, got notifications %v", *sent)
+	}
+}
+
+func TestConfigureToolsOverStdio(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- newTestServer().serveStdio(inR, outW)
+		outW.Close()
+	}()
+	lines := bufio.NewScanner(outR)
+	lines.Buffer(nil, maxStdioMessageSize)
+
+	// send はメッセージを送り、id の応答までに届いた行を返します
+	send := func(msg string, id string) []string {
+		t.Helper()
+		if _, err := io.WriteString(inW, msg+"\n"); err != nil {
+			t.Fatalf("failed to write %s: %v", msg, err)
+		}
+		if id == "" {
+			return nil
+		}
+		var got []string
+		for lines.Scan() {
+			got = append(got, lines.Text())
+			if strings.HasSuffix(lines.Text(), `"id":`+id+`}`) {
+				return got
+			}
+		}
+		t.Fatalf("no response for id %s, got %v", id, got)
+		return nil
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`, "1")
+	send(`{"jsonrpc":"2.0","method":"notifications/initialized"}`, "")
+
+	got := send(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"configure_tools","arguments":{"disable":["divide","multiply"]}}}`, "2")
+	// 2つのツールを無効にしても通知は1回だけ
+	if len(got) != 2 || got[0] != `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}` {
+		t.Fatalf("expected one list_changed notification before the result, got %v", got)
+	}
+	if !strings.Contains(got[1], `"disabled":["multiply","divide"]`) {
+		t.Errorf("unexpected result: %s", got[1])
+	}
+	list := send(`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`, "3")
+	if out := strings.Join(list, "\n"); strings.Contains(out, `"name":"divide"`) || !strings.Contains(out, `"name":"add"`) {
+		t.Errorf("expected divide to be removed from tools/list, got %s", out)
+	}
+
+	// 有効に戻すと一覧に再び現れる
+	send(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"configure_tools","arguments":{"enable":["divide"]}}}`, "4")
+	out := send(`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"divide","arguments":{"a":1,"b":4}}}`, "5")
+	if !strings.Contains(out[len(out)-1], `"text":"0.250000"`) {
+		t.Errorf("expected divide to work again, got %v", out)
+	}
+
+	inW.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
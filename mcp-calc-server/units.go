@@ -0,0 +1,635 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 次元ベクトルの各成分
+const (
+	dimLength = iota
+	dimMass
+	dimTime
+	dimCurrent
+	dimTemperature
+	dimAmount
+	dimLuminosity
+	dimData
+	numDims
+)
+
+// dimension は各基本量の指数を表します（例: 速度は length:1, time:-1）
+type dimension [numDims]int
+
+// dimensionSymbols は各基本量の基準単位の記号です
+var dimensionSymbols = [numDims]string{"m", "kg", "s", "A", "K", "mol", "cd", "B"}
+
+// dimensionNames は各基本量の名前です
+var dimensionNames = [numDims]string{"length", "mass", "time", "current", "temperature", "amount", "luminosity", "data"}
+
+func (d dimension) add(o dimension) dimension {
+	for i := range d {
+		d[i] += o[i]
+	}
+	return d
+}
+
+func (d dimension) scale(n int) dimension {
+	for i := range d {
+		d[i] *= n
+	}
+	return d
+}
+
+func (d dimension) isDimensionless() bool {
+	return d == dimension{}
+}
+
+// String は次元を基準単位の組み合わせで表します（例: kg·m²/s²）
+func (d dimension) String() string {
+	var num, den []string
+	for i, exp := range d {
+		switch {
+		case exp > 0:
+			num = append(num, dimensionSymbols[i]+superscript(exp))
+		case exp < 0:
+			den = append(den, dimensionSymbols[i]+superscript(-exp))
+		}
+	}
+	if len(num) == 0 && len(den) == 0 {
+		return ""
+	}
+	s := strings.Join(num, "·")
+	if s == "" {
+		s = "1"
+	}
+	if len(den) > 0 {
+		s += "/" + strings.Join(den, "·")
+	}
+	return s
+}
+
+// describe は次元を基本量の名前で表します（エラーメッセージ用）
+func (d dimension) describe() string {
+	var parts []string
+	for i, exp := range d {
+		if exp == 0 {
+			continue
+		}
+		if exp == 1 {
+			parts = append(parts, dimensionNames[i])
+		} else {
+			parts = append(parts, fmt.Sprintf("%s^%d", dimensionNames[i], exp))
+		}
+	}
+	if len(parts) == 0 {
+		return "dimensionless"
+	}
+	return strings.Join(parts, "·")
+}
+
+// superscript は1以外の指数を上付き文字で表します
+func superscript(n int) string {
+	if n == 1 {
+		return ""
+	}
+	digits := map[rune]rune{
+		'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴',
+		'5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹', '-': '⁻',
+	}
+	var b strings.Builder
+	for _, r := range strconv.Itoa(n) {
+		b.WriteRune(digits[r])
+	}
+	return b.String()
+}
+
+// unitDef は単位を SI 基本単位への換算で表します。SI での値 = value*factor + offset
+type unitDef struct {
+	factor float64
+	offset float64
+	dim    dimension
+}
+
+// 主な次元
+var (
+	dLength      = dimension{dimLength: 1}
+	dArea        = dimension{dimLength: 2}
+	dVolume      = dimension{dimLength: 3}
+	dMass        = dimension{dimMass: 1}
+	dTime        = dimension{dimTime: 1}
+	dTemperature = dimension{dimTemperature: 1}
+	dData        = dimension{dimData: 1}
+	dSpeed       = dimension{dimLength: 1, dimTime: -1}
+	dFrequency   = dimension{dimTime: -1}
+	dForce       = dimension{dimMass: 1, dimLength: 1, dimTime: -2}
+	dEnergy      = dimension{dimMass: 1, dimLength: 2, dimTime: -2}
+	dPower       = dimension{dimMass: 1, dimLength: 2, dimTime: -3}
+	dPressure    = dimension{dimMass: 1, dimLength: -1, dimTime: -2}
+)
+
+// 尺貫法の基準（1尺 = 10/33 m、1升 = 64827/35937 L）
+const (
+	shaku = 10.0 / 33
+	tsubo = 400.0 / 121
+	shou  = 64827.0 / 35937 * 1e-3
+)
+
+// units は使用できる単位の一覧です
+var units = map[string]unitDef{
+	// 長さ
+	"m":   {factor: 1, dim: dLength},
+	"km":  {factor: 1e3, dim: dLength},
+	"cm":  {factor: 1e-2, dim: dLength},
+	"mm":  {factor: 1e-3, dim: dLength},
+	"um":  {factor: 1e-6, dim: dLength},
+	"μm":  {factor: 1e-6, dim: dLength},
+	"nm":  {factor: 1e-9, dim: dLength},
+	"in":  {factor: 0.0254, dim: dLength},
+	"ft":  {factor: 0.3048, dim: dLength},
+	"yd":  {factor: 0.9144, dim: dLength},
+	"mi":  {factor: 1609.344, dim: dLength},
+	"nmi": {factor: 1852, dim: dLength},
+	"寸":   {factor: shaku / 10, dim: dLength},
+	"尺":   {factor: shaku, dim: dLength},
+	"間":   {factor: shaku * 6, dim: dLength},
+	"丈":   {factor: shaku * 10, dim: dLength},
+	"町":   {factor: shaku * 360, dim: dLength},
+	"里":   {factor: shaku * 12960, dim: dLength},
+
+	// 面積（畳は不動産の表示に関する公正競争規約の 1.62m² を基準とし、地域ごとの畳も用意する）
+	"a":    {factor: 100, dim: dArea},
+	"ha":   {factor: 1e4, dim: dArea},
+	"acre": {factor: 4046.8564224, dim: dArea},
+	"坪":    {factor: tsubo, dim: dArea},
+	"畝":    {factor: tsubo * 30, dim: dArea},
+	"反":    {factor: tsubo * 300, dim: dArea},
+	"町歩":   {factor: tsubo * 3000, dim: dArea},
+	"畳":    {factor: 1.62, dim: dArea},
+	"京間畳":  {factor: 0.955 * 1.91, dim: dArea},
+	"中京間畳": {factor: 0.91 * 1.82, dim: dArea},
+	"江戸間畳": {factor: 0.88 * 1.76, dim: dArea},
+	"団地間畳": {factor: 0.85 * 1.7, dim: dArea},
+
+	// 体積
+	"L":   {factor: 1e-3, dim: dVolume},
+	"l":   {factor: 1e-3, dim: dVolume},
+	"mL":  {factor: 1e-6, dim: dVolume},
+	"ml":  {factor: 1e-6, dim: dVolume},
+	"gal": {factor: 3.785411784e-3, dim: dVolume},
+	"合":   {factor: shou / 10, dim: dVolume},
+	"升":   {factor: shou, dim: dVolume},
+	"斗":   {factor: shou * 10, dim: dVolume},
+	"石":   {factor: shou * 100, dim: dVolume},
+
+	// 質量
+	"kg": {factor: 1, dim: dMass},
+	"g":  {factor: 1e-3, dim: dMass},
+	"mg": {factor: 1e-6, dim: dMass},
+	"t":  {factor: 1e3, dim: dMass},
+	"lb": {factor: 0.45359237, dim: dMass},
+	"oz": {factor: 0.028349523125, dim: dMass},
+	"匁":  {factor: 3.75e-3, dim: dMass},
+	"斤":  {factor: 0.6, dim: dMass},
+	"貫":  {factor: 3.75, dim: dMass},
+
+	// 時間
+	"s":   {factor: 1, dim: dTime},
+	"ms":  {factor: 1e-3, dim: dTime},
+	"us":  {factor: 1e-6, dim: dTime},
+	"μs":  {factor: 1e-6, dim: dTime},
+	"ns":  {factor: 1e-9, dim: dTime},
+	"min": {factor: 60, dim: dTime},
+	"h":   {factor: 3600, dim: dTime},
+	"d":   {factor: 86400, dim: dTime},
+	"wk":  {factor: 604800, dim: dTime},
+	"yr":  {factor: 31557600, dim: dTime}, // ユリウス年
+
+	// 温度（°C と °F は原点がずれているため単独でのみ使用できる）
+	"K":    {factor: 1, dim: dTemperature},
+	"degC": {factor: 1, offset: 273.15, dim: dTemperature},
+	"°C":   {factor: 1, offset: 273.15, dim: dTemperature},
+	"℃":    {factor: 1, offset: 273.15, dim: dTemperature},
+	"degF": {factor: 5.0 / 9, offset: 459.67 * 5 / 9, dim: dTemperature},
+	"°F":   {factor: 5.0 / 9, offset: 459.67 * 5 / 9, dim: dTemperature},
+	"℉":    {factor: 5.0 / 9, offset: 459.67 * 5 / 9, dim: dTemperature},
+
+	// データ量
+	"bit":  {factor: 0.125, dim: dData},
+	"kbit": {factor: 125, dim: dData},
+	"Mbit": {factor: 125e3, dim: dData},
+	"Gbit": {factor: 125e6, dim: dData},
+	"B":    {factor: 1, dim: dData},
+	"kB":   {factor: 1e3, dim: dData},
+	"MB":   {factor: 1e6, dim: dData},
+	"GB":   {factor: 1e9, dim: dData},
+	"TB":   {factor: 1e12, dim: dData},
+	"PB":   {factor: 1e15, dim: dData},
+	"KiB":  {factor: 1 << 10, dim: dData},
+	"MiB":  {factor: 1 << 20, dim: dData},
+	"GiB":  {factor: 1 << 30, dim: dData},
+	"TiB":  {factor: 1 << 40, dim: dData},
+	"PiB":  {factor: 1 << 50, dim: dData},
+
+	// 組立単位
+	"kn":   {factor: 1852.0 / 3600, dim: dSpeed},
+	"mph":  {factor: 0.44704, dim: dSpeed},
+	"Hz":   {factor: 1, dim: dFrequency},
+	"kHz":  {factor: 1e3, dim: dFrequency},
+	"MHz":  {factor: 1e6, dim: dFrequency},
+	"GHz":  {factor: 1e9, dim: dFrequency},
+	"N":    {factor: 1, dim: dForce},
+	"kN":   {factor: 1e3, dim: dForce},
+	"J":    {factor: 1, dim: dEnergy},
+	"kJ":   {factor: 1e3, dim: dEnergy},
+	"cal":  {factor: 4.184, dim: dEnergy},
+	"kcal": {factor: 4184, dim: dEnergy},
+	"Wh":   {factor: 3600, dim: dEnergy},
+	"kWh":  {factor: 3.6e6, dim: dEnergy},
+	"W":    {factor: 1, dim: dPower},
+	"kW":   {factor: 1e3, dim: dPower},
+	"Pa":   {factor: 1, dim: dPressure},
+	"hPa":  {factor: 100, dim: dPressure},
+	"kPa":  {factor: 1e3, dim: dPressure},
+	"bar":  {factor: 1e5, dim: dPressure},
+	"atm":  {factor: 101325, dim: dPressure},
+}
+
+// superscriptDigits は上付き文字の指数を通常の文字に対応付けます
+var superscriptDigits = map[rune]rune{
+	'⁰': '0', '¹': '1', '²': '2', '³': '3', '⁴': '4',
+	'⁵': '5', '⁶': '6', '⁷': '7', '⁸': '8', '⁹': '9', '⁻': '-',
+}
+
+// parseUnit は "km/h" や "kg·m/s^2"、"m²" のような単位表記を解釈します。
+// '/' は直後の1項だけに掛かります（a/b*c = (a/b)·c）
+func parseUnit(s string) (unitDef, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return unitDef{}, fmt.Errorf("empty unit")
+	}
+	if u, ok := units[s]; ok {
+		return u, nil
+	}
+
+	result := unitDef{factor: 1}
+	rs := []rune(s)
+	sign := 1
+	terms := 0
+	for i := 0; i < len(rs); {
+		// 項の名前を読む
+		start := i
+		for i < len(rs) && !strings.ContainsRune("*·/^ ", rs[i]) && superscriptDigits[rs[i]] == 0 {
+			i++
+		}
+		name := string(rs[start:i])
+		if name == "" {
+			return unitDef{}, fmt.Errorf("invalid unit %q", s)
+		}
+
+		// 指数を読む（^2, ^-1, ² など）
+		exp := 1
+		if i < len(rs) && rs[i] == '^' {
+			i++
+			start := i
+			if i < len(rs) && rs[i] == '-' {
+				i++
+			}
+			for i < len(rs) && unicode.IsDigit(rs[i]) {
+				i++
+			}
+			n, err := strconv.Atoi(string(rs[start:i]))
+			if err != nil {
+				return unitDef{}, fmt.Errorf("invalid exponent in unit %q", s)
+			}
+			exp = n
+		} else if i < len(rs) && superscriptDigits[rs[i]] != 0 {
+			var digits []rune
+			for i < len(rs) && superscriptDigits[rs[i]] != 0 {
+				digits = append(digits, superscriptDigits[rs[i]])
+				i++
+			}
+			n, err := strconv.Atoi(string(digits))
+			if err != nil {
+				return unitDef{}, fmt.Errorf("invalid exponent in unit %q", s)
+			}
+			exp = n
+		}
+
+		var u unitDef
+		if name == "1" {
+			u = unitDef{factor: 1}
+		} else {
+			var ok bool
+			if u, ok = units[name]; !ok {
+				return unitDef{}, fmt.Errorf("unknown unit %q", name)
+			}
+		}
+		if u.offset != 0 {
+			return unitDef{}, fmt.Errorf("unit %q has an offset and cannot be combined with other units", name)
+		}
+		exp *= sign
+		result.factor *= math.Pow(u.factor, float64(exp))
+		result.dim = result.dim.add(u.dim.scale(exp))
+		terms++
+
+		// 区切りを読む
+		for i < len(rs) && rs[i] == ' ' {
+			i++
+		}
+		sign = 1
+		if i < len(rs) {
+			switch rs[i] {
+			case '/':
+				sign = -1
+			case '*', '·':
+			default:
+				// 空白区切りは積として扱う
+				continue
+			}
+			i++
+			for i < len(rs) && rs[i] == ' ' {
+				i++
+			}
+			if i == len(rs) {
+				return unitDef{}, fmt.Errorf("invalid unit %q", s)
+			}
+		}
+	}
+	return result, nil
+}
+
+// unitMismatchError は次元の異なる単位どうしの変換・演算に対するエラーを生成します
+func unitMismatchError(from, to string, fromDim, toDim dimension) *Error {
+	return &Error{
+		Code:    ErrorUnitMismatch,
+		Message: fmt.Sprintf("Cannot convert %s (%s) to %s (%s)", from, fromDim.describe(), to, toDim.describe()),
+		Data: map[string]any{
+			"from":          from,
+			"to":            to,
+			"fromDimension": fromDim.describe(),
+			"toDimension":   toDim.describe(),
+		},
+	}
+}
+
+// convertUnit は値を from の単位から to の単位に変換します
+func convertUnit(value float64, from, to string) (float64, *Error) {
+	fu, err := parseUnit(from)
+	if err != nil {
+		return 0, &Error{
+			Code:    ErrorInvalidParams,
+			Message: err.Error(),
+			Data:    map[string]any{"unit": from},
+		}
+	}
+	tu, err := parseUnit(to)
+	if err != nil {
+		return 0, &Error{
+			Code:    ErrorInvalidParams,
+			Message: err.Error(),
+			Data:    map[string]any{"unit": to},
+		}
+	}
+	if fu.dim != tu.dim {
+		return 0, unitMismatchError(from, to, fu.dim, tu.dim)
+	}
+	si := value*fu.factor + fu.offset
+	return (si - tu.offset) / tu.factor, nil
+}
+
+// ConvertParams は単位変換ツールのパラメータを表します
+type ConvertParams struct {
+	Value float64 `json:"value"`
+	From  string  `json:"from"`
+	To    string  `json:"to"`
+}
+
+// getUnitTools は単位変換ツールの一覧を返します
+func getUnitTools() []toolDef {
+	return bindTools([]Tool{
+		{
+			Name:        "convert",
+			Description: "Convert a value between units of length, area, volume, mass, time, temperature, data size, speed, energy and more, including Japanese traditional units (尺, 坪, 畳, 合, 貫). Compound units such as km/h or kg·m/s^2 are supported",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"value": map[string]any{
+						"type":        "number",
+						"description": "Value to convert",
+					},
+					"from": map[string]any{
+						"type":        "string",
+						"description": "Source unit, e.g. m, km/h, °C, 坪",
+					},
+					"to": map[string]any{
+						"type":        "string",
+						"description": "Target unit with the same dimension as the source unit",
+					},
+					"format": formatSchema,
+				},
+				"required": []string{"value", "from", "to"},
+			},
+			OutputSchema: outputSchema(map[string]any{
+				"value": map[string]any{"type": "number"},
+				"unit":  map[string]any{"type": "string"},
+			}, "value", "unit"),
+		},
+	}, func(s *Server, ctx context.Context, _ string, args json.RawMessage) (*CallToolResult, *Error) {
+		return s.handleConvert(ctx, args)
+	})
+}
+
+// handleConvert は convert ツールの呼び出しを処理します
+func (s *Server) handleConvert(ctx context.Context, args json.RawMessage) (*CallToolResult, *Error) {
+	var params ConvertParams
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, &Error{
+			Code:    ErrorInvalidParams,
+			Message: "Invalid arguments",
+			Data:    err.Error(),
+		}
+	}
+
+	value, err := convertUnit(params.Value, params.From, params.To)
+	if err != nil {
+		return nil, err
+	}
+	if !finite(value) {
+		return nil, outOfRange("convert")
+	}
+	return newStructuredResult(formatNumber(ctx, value)+" "+params.To, map[string]any{"value": value, "unit": params.To}), nil
+}
+
+// quantity は単位付きの値を SI 基本単位で表します
+type quantity struct {
+	value float64
+	dim   dimension
+}
+
+// String は値を SI 基本単位の組み合わせで表します（例: 12.000000 m²）
+func (q quantity) String() string {
+	return q.format(FormatOptions{})
+}
+
+// format は値を表記の指定に従って整形し、SI 基本単位を付けます
+func (q quantity) format(opts FormatOptions) string {
+	if q.dim.isDimensionless() {
+		return opts.format(q.value)
+	}
+	return opts.format(q.value) + " " + q.dim.String()
+}
+
+func mismatchAt(pos int, op string, l, r dimension) *exprError {
+	return &exprError{
+		Code: ErrorUnitMismatch,
+		Pos:  pos,
+		Msg:  fmt.Sprintf("dimension mismatch in '%s': %s vs %s", op, l.describe(), r.describe()),
+	}
+}
+
+func (n *numberNode) evalQuantity(env *evalEnv) (quantity, *exprError) {
+	return quantity{value: n.value}, nil
+}
+
+func (n *identNode) evalQuantity(env *evalEnv) (quantity, *exprError) {
+	// 変数と定数は単位より優先する
+	if v, err := n.eval(env); err == nil {
+		return quantity{value: v}, nil
+	}
+	u, ok := units[n.name]
+	if !ok {
+		return quantity{}, parseErrorAt(n.pos, "unknown identifier or unit %q", n.name)
+	}
+	if u.offset != 0 {
+		return quantity{}, parseErrorAt(n.pos, "unit %q has an offset and cannot be used in arithmetic; use K or the convert tool", n.name)
+	}
+	return quantity{value: u.factor, dim: u.dim}, nil
+}
+
+func (n *unaryNode) evalQuantity(env *evalEnv) (quantity, *exprError) {
+	q, err := n.operand.evalQuantity(env)
+	if err != nil {
+		return quantity{}, err
+	}
+	if n.op == "-" {
+		q.value = -q.value
+	}
+	return q, nil
+}
+
+func (n *binaryNode) evalQuantity(env *evalEnv) (quantity, *exprError) {
+	l, err := n.left.evalQuantity(env)
+	if err != nil {
+		return quantity{}, err
+	}
+	r, err := n.right.evalQuantity(env)
+	if err != nil {
+		return quantity{}, err
+	}
+
+	var dim dimension
+	switch n.op {
+	case "+", "-", "%":
+		if l.dim != r.dim {
+			return quantity{}, mismatchAt(n.pos, n.op, l.dim, r.dim)
+		}
+		dim = l.dim
+	case "*":
+		dim = l.dim.add(r.dim)
+	case "/":
+		dim = l.dim.add(r.dim.scale(-1))
+	case "^":
+		if !r.dim.isDimensionless() {
+			return quantity{}, &exprError{Code: ErrorUnitMismatch, Pos: n.pos, Msg: "exponent must be dimensionless"}
+		}
+		if !l.dim.isDimensionless() {
+			if r.value != math.Trunc(r.value) {
+				return quantity{}, &exprError{Code: ErrorUnitMismatch, Pos: n.pos, Msg: "a quantity with units can only be raised to an integer power"}
+			}
+			dim = l.dim.scale(int(r.value))
+		}
+	}
+
+	// 数値部分の計算は通常の評価と共通にする
+	v, err := (&binaryNode{op: n.op, left: &numberNode{value: l.value}, right: &numberNode{value: r.value}, pos: n.pos}).eval(env)
+	if err != nil {
+		return quantity{}, err
+	}
+	return quantity{value: v, dim: dim}, nil
+}
+
+func (n *callNode) evalQuantity(env *evalEnv) (quantity, *exprError) {
+	// 次元の検査は args[0] を使うので、先に引数の数を確かめる
+	f, ok := exprFunctions[n.name]
+	if !ok {
+		return quantity{}, parseErrorAt(n.pos, "unknown function %q", n.name)
+	}
+	if len(n.args) < f.minArgs || (f.maxArgs >= 0 && len(n.args) > f.maxArgs) {
+		return quantity{}, parseErrorAt(n.pos, "wrong number of arguments to %s: got %d", n.name, len(n.args))
+	}
+	args := make([]quantity, len(n.args))
+	for i, a := range n.args {
+		q, err := a.evalQuantity(env)
+		if err != nil {
+			return quantity{}, err
+		}
+		args[i] = q
+	}
+
+	// 次元を保つ関数と、次元を変える根号だけが単位付きの引数を受け付ける
+	var dim dimension
+	switch n.name {
+	case "abs", "min", "max", "hypot":
+		for _, a := range args[1:] {
+			if a.dim != args[0].dim {
+				return quantity{}, mismatchAt(n.pos, n.name, args[0].dim, a.dim)
+			}
+		}
+		dim = args[0].dim
+	case "sqrt", "cbrt":
+		root := 2
+		if n.name == "cbrt" {
+			root = 3
+		}
+		if len(args) == 1 {
+			for i, exp := range args[0].dim {
+				if exp%root != 0 {
+					return quantity{}, &exprError{Code: ErrorUnitMismatch, Pos: n.pos, Msg: fmt.Sprintf("%s of %s is not a whole unit", n.name, args[0].dim)}
+				}
+				dim[i] = exp / root
+			}
+		}
+	default:
+		for _, a := range args {
+			if !a.dim.isDimensionless() {
+				return quantity{}, &exprError{Code: ErrorUnitMismatch, Pos: n.pos, Msg: fmt.Sprintf("%s requires a dimensionless argument, got %s", n.name, a.dim.describe())}
+			}
+		}
+	}
+
+	plain := make([]exprNode, len(args))
+	for i, a := range args {
+		plain[i] = &numberNode{value: a.value}
+	}
+	v, err := (&callNode{name: n.name, args: plain, pos: n.pos}).eval(env)
+	if err != nil {
+		return quantity{}, err
+	}
+	return quantity{value: v, dim: dim}, nil
+}
+
+// evaluateQuantity は単位付きの式を解析して評価します。
+// 数値の直後の単位（3 m）は暗黙の積として扱います
+func evaluateQuantity(src string, env *evalEnv) (quantity, *exprError) {
+	node, err := parseExpressionWithUnits(src)
+	if err != nil {
+		return quantity{}, err
+	}
+	return node.evalQuantity(env)
+}
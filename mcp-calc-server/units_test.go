@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{1, "km", "m", 1000},
+		{1, "mi", "km", 1.609344},
+		{100, "°C", "°F", 212},
+		{-40, "degF", "degC", -40},
+		{0, "℃", "K", 273.15},
+		{1, "坪", "m²", 400.0 / 121},
+		{6, "畳", "m^2", 9.72},
+		{2, "畳", "坪", 3.24 * 121 / 400},
+		{1, "升", "合", 10},
+		{1, "貫", "kg", 3.75},
+		{1, "GiB", "MiB", 1024},
+		{8, "bit", "B", 1},
+		{100, "km/h", "m/s", 100.0 / 3.6},
+		{1, "kWh", "J", 3.6e6},
+		{1, "N", "kg*m/s^2", 1},
+		{2, "h", "min", 120},
+		{1, "Hz", "1/s", 1},
+	}
+
+	for _, tt := range tests {
+		got, err := convertUnit(tt.value, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("%v %s -> %s: unexpected error: %v", tt.value, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("%v %s -> %s: expected %v, got %v", tt.value, tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestConvertUnitErrors(t *testing.T) {
+	tests := []struct {
+		from, to string
+		code     int
+	}{
+		{"m", "kg", ErrorUnitMismatch},
+		{"km/h", "m", ErrorUnitMismatch},
+		{"坪", "m", ErrorUnitMismatch},
+		{"furlong", "m", ErrorInvalidParams},
+		{"°C/s", "K/s", ErrorInvalidParams},
+		{"m/", "m", ErrorInvalidParams},
+	}
+
+	for _, tt := range tests {
+		_, err := convertUnit(1, tt.from, tt.to)
+		if err == nil || err.Code != tt.code {
+			t.Errorf("%s -> %s: expected code %d, got %v", tt.from, tt.to, tt.code, err)
+		}
+	}
+}
+
+func TestConvertOutOfRange(t *testing.T) {
+	s := newTestServer()
+	for _, tt := range []struct{ name, args string }{
+		{"convert", `{"value":1e308,"from":"km","to":"mm"}`},
+		{"evaluate", `{"expression":"1e308 km","to":"mm"}`},
+	} {
+		if _, err := s.handleToolCall(context.Background(), tt.name, json.RawMessage(tt.args)); err == nil || err.Code != ErrorMathDomain {
+			t.Errorf("%s %s: expected out of range error, got %v", tt.name, tt.args, err)
+		}
+	}
+}
+
+func TestConvertMismatchData(t *testing.T) {
+	s := newTestServer()
+	_, err := s.handleToolCall(context.Background(), "convert", json.RawMessage(`{"value":1,"from":"m","to":"kg"}`))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	data := err.Data.(map[string]any)
+	if data["fromDimension"] != "length" || data["toDimension"] != "mass" {
+		t.Errorf("unexpected error data: %v", data)
+	}
+}
+
+func TestEvaluateQuantity(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"3 m * 4 m", "12.000000 m²"},
+		{"5 km + 300 m", "5300.000000 m"},
+		{"9.8 m/s^2 * 2 kg", "19.600000 m·kg/s²"},
+		{"10 m / 2 s", "5.000000 m/s"},
+		{"sqrt(16 m²)", "4.000000 m"},
+		{"(2 m)^3", "8.000000 m³"},
+		{"6 m / 3 m", "2.000000"},
+		{"1 坪 + 1 m^2", "4.305785 m²"},
+	}
+
+	for _, tt := range tests {
+		q, err := evaluateQuantity(tt.expr, nil)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if got := q.String(); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestEvaluateQuantityErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		code int
+		pos  int
+	}{
+		{"3 m + 2 s", ErrorUnitMismatch, 4},
+		{"sin(3 m)", ErrorUnitMismatch, 0},
+		{"sqrt(2 m)", ErrorUnitMismatch, 0},
+		{"2 m ^ 1.5", ErrorUnitMismatch, 4},
+		{"20 degC + 1 K", ErrorInvalidParams, 3},
+		// 引数のない min や max は次元を比べる前にエラーにする
+		{"min()", ErrorInvalidParams, 0},
+		{"max()", ErrorInvalidParams, 0},
+	}
+
+	for _, tt := range tests {
+		_, err := evaluateQuantity(tt.expr, nil)
+		if err == nil {
+			t.Errorf("%q: expected error", tt.expr)
+			continue
+		}
+		if err.Code != tt.code || err.Pos != tt.pos {
+			t.Errorf("%q: expected code %d at %d, got %d at %d (%s)", tt.expr, tt.code, tt.pos, err.Code, err.Pos, err.Msg)
+		}
+	}
+}
+
+func TestEvaluateToolWithUnits(t *testing.T) {
+	s := newTestServer()
+	result, err := s.handleToolCall(context.Background(), "evaluate", json.RawMessage(`{"expression":"3 m * 4 m","to":"坪"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := result.Content[0].Text
+	if got != "3.630000 坪" {
+		t.Errorf("unexpected result: %v", got)
+	}
+
+	if _, err := s.handleToolCall(context.Background(), "evaluate", json.RawMessage(`{"expression":"3 m * 4 m","to":"m"}`)); err == nil || err.Code != ErrorUnitMismatch {
+		t.Errorf("expected unit mismatch error, got %v", err)
+	}
+
+	for _, expr := range []string{"min()", "max()"} {
+		args := `{"expression":"` + expr + `","units":true}`
+		if _, err := s.handleToolCall(context.Background(), "evaluate", json.RawMessage(args)); err == nil || err.Code != ErrorInvalidParams {
+			t.Errorf("%s: expected invalid params error, got %v", args, err)
+		}
+	}
+}
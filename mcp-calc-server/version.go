@@ -0,0 +1,75 @@
+package main
+
+import (
+	"time"
+)
+
+// protocolFeatures はプロトコルバージョンごとに利用できる機能を表します
+type protocolFeatures struct {
+	toolAnnotations  bool // Tool.annotations（2025-03-26 以降）
+	completions      bool // completion/complete と completions 機能（2025-03-26 以降）
+	structuredOutput bool // structuredContent と outputSchema（2025-06-18 以降）
+}
+
+// supportedProtocolVersions はサーバーが実装しているプロトコルバージョンの一覧です（古い順）
+var supportedProtocolVersions = []string{
+	"2024-11-05",
+	"2025-03-26",
+	"2025-06-18",
+}
+
+// protocolFeatureTable はバージョンごとの機能の対応表です
+var protocolFeatureTable = map[string]protocolFeatures{
+	"2024-11-05": {},
+	"2025-03-26": {toolAnnotations: true, completions: true},
+	"2025-06-18": {toolAnnotations: true, completions: true, structuredOutput: true},
+}
+
+// negotiateProtocolVersion はクライアントの要求バージョン以下でサーバーが対応する最新のバージョンを選びます。
+// クライアントは要求したバージョンより古いバージョンにも対応している可能性があるため、
+// 要求バージョン以下で最も新しいものが双方の対応する最新バージョンになります
+func negotiateProtocolVersion(requested string) (string, *Error) {
+	unsupported := &Error{
+		Code:    ErrorInvalidParams,
+		Message: "Unsupported protocol version",
+		Data: map[string]any{
+			"supported": supportedProtocolVersions,
+			"requested": requested,
+		},
+	}
+
+	// バージョンは YYYY-MM-DD 形式なので文字列比較で新旧を判定できる
+	if _, err := time.Parse(time.DateOnly, requested); err != nil {
+		return "", unsupported
+	}
+	for i := len(supportedProtocolVersions) - 1; i >= 0; i-- {
+		if v := supportedProtocolVersions[i]; v <= requested {
+			return v, nil
+		}
+	}
+	return "", unsupported
+}
+
+// features は交渉済みのプロトコルバージョンで利用できる機能を返します
+func (s *Server) features() protocolFeatures {
+	return protocolFeatureTable[s.protocolVersion]
+}
+
+// serverCapabilities は交渉済みのプロトコルバージョンで広告する機能を返します。
+// バージョンに依存する機能は features() を確認してから追加します
+func (s *Server) serverCapabilities() ServerCapabilities {
+	caps := ServerCapabilities{
+		Logging: &LoggingCapability{},
+		Prompts: &PromptsCapability{},
+		Resources: &ResourcesCapability{
+			Subscribe: true,
+		},
+		Tools: &ToolsCapability{
+			ListChanged: true,
+		},
+	}
+	if s.features().completions {
+		caps.Completions = &CompletionsCapability{}
+	}
+	return caps
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+		wantErr   bool
+	}{
+		{"2024-11-05", "2024-11-05", false},
+		{"2025-03-26", "2025-03-26", false},
+		{"2025-01-01", "2024-11-05", false},
+		{"2099-12-31", "2025-06-18", false},
+		{"2024-01-01", "", true},
+		{"latest", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := negotiateProtocolVersion(tt.requested)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error, got %q", tt.requested, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.requested, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.requested, tt.want, got)
+		}
+	}
+}
+
+func TestInitializeUnsupportedVersion(t *testing.T) {
+	s := newTestServer()
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2023-01-01"}}`)
+
+	var resp Response
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrorInvalidParams {
+		t.Fatalf("expected invalid params error, got %s", out)
+	}
+	if !strings.Contains(out, `"supported":["2024-11-05","2025-03-26","2025-06-18"]`) {
+		t.Errorf("expected supported versions in error data, got %s", out)
+	}
+	if s.state != stateUninitialized {
+		t.Errorf("expected state to stay %s, got %s", stateUninitialized, s.state)
+	}
+}
+
+func TestToolAnnotationsGatedByVersion(t *testing.T) {
+	for version, want := range map[string]bool{"2024-11-05": false, "2025-03-26": true} {
+		s := newTestServer()
+		roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+version+`"}}`)
+		out := roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+		if got := strings.Contains(out, `"annotations"`); got != want {
+			t.Errorf("%s: expected annotations present=%v, got %s", version, want, out)
+		}
+	}
+}